@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/config"
+)
+
+// flagSpec describes one command-line flag.
+type flagSpec struct {
+	name  string // long name, used as --name
+	short string // optional one-letter alias, used as -s
+	kind  config.Kind
+	value string // value placeholder for help, e.g. "file"
+	help  string
+	// env is the environment variable the flag overrides, if any.
+	env string
+	// complete names the completion of the flag's value: compFile,
+	// compProvider, compModel or a space-separated list of choices.
+	complete string
+}
+
+// Dynamic value completions; see completion.go.
+const (
+	compFile     = "<file>"
+	compProvider = "<provider>"
+	compModel    = "<model>"
+)
+
+// command is a node of the CLI command tree.
+type command struct {
+	name    string
+	aliases []string
+	args    string // positional arguments for usage lines, e.g. "[command]"
+	summary string
+	flags   []flagSpec
+	// maxArgs is the number of positional arguments accepted; -1 for any.
+	maxArgs int
+	// hidden commands are left out of help and completion.
+	hidden bool
+	// choices completes positional arguments of commands without subcommands.
+	choices []string
+	// files completes positional arguments as file names.
+	files  bool
+	sub    []*command
+	parent *command
+	// run executes the command; nil for commands that only group subcommands.
+	run func(ctx context.Context, inv *invocation)
+}
+
+// commands returns the CLI command tree. Every command also accepts the
+// global flags (see globalFlags).
+func commands() *command {
+	root := &command{
+		name:    "aic",
+		summary: "AI-assisted git commit message generator",
+		flags: []flagSpec{
+			{name: "system", short: "s", kind: config.KindString, value: "text", help: "Extra instruction for the model, merged after .aic.json instructions"},
+			{name: "hook", kind: config.KindString, value: "file", help: "Hook mode: write selected message to file and exit", complete: compFile},
+			{name: "amend", kind: config.KindBool, help: "Suggest a message for the last commit plus the staged changes and amend it"},
+		},
+		run: runSuggest,
+	}
+	cache := &command{name: "cache", summary: "Manage the on-disk response cache"}
+	cache.add(&command{name: "clear", summary: "Delete cached responses and diff summaries", run: runCacheClear})
+	global := flagSpec{name: "global", kind: config.KindBool, help: "Use the init.templateDir hooks that git init and clone copy into new repositories"}
+	hooks := &command{name: "hook", summary: "Install, remove or inspect the Git hooks that run aic"}
+	hooks.add(
+		&command{
+			name:    "install",
+			summary: "Install the prepare-commit-msg hook, keeping any existing hook",
+			flags:   []flagSpec{global, {name: "lint", kind: config.KindBool, help: "Also install the commit-msg hook that runs aic lint"}},
+			run:     runHookInstall,
+		},
+		&command{name: "uninstall", summary: "Remove the aic hooks and restore the hooks they replaced", flags: []flagSpec{global}, run: runHookUninstall},
+		&command{name: "status", summary: "Show which aic hooks are installed", flags: []flagSpec{global}, run: runHookStatus},
+		&command{
+			name:    "prepare-commit-msg",
+			args:    "<file> [source] [sha]",
+			maxArgs: 3,
+			hidden:  true,
+			summary: "Run as Git's prepare-commit-msg hook",
+			run:     runHookPrepareCommitMsg,
+		},
+	)
+	root.add(
+		&command{
+			name:    "analyze",
+			aliases: []string{"analyse"},
+			summary: "Infer repo commit style and write .aic.json",
+			flags:   []flagSpec{{name: "limit", kind: config.KindInt, value: "N", help: "Number of recent commit subjects to analyze [default: 1000]"}},
+			run:     runAnalyze,
+		},
+		cache,
+		&command{name: "config", summary: "Show the effective configuration and where each value comes from", run: runConfig},
+		hooks,
+		&command{
+			name:    "split",
+			summary: "Plan staged changes as several commits and create them",
+			flags:   []flagSpec{{name: "dry-run", kind: config.KindBool, help: "Print the plan without committing"}},
+			run:     runSplit,
+		},
+		&command{
+			name:    "lint",
+			args:    "<file|->",
+			maxArgs: 1,
+			files:   true,
+			summary: "Check a commit message against the repo's commit rules",
+			flags:   []flagSpec{{name: "suggest-fix", kind: config.KindBool, help: "Ask the provider for a rewrite that follows the rules"}},
+			run:     runLint,
+		},
+		&command{name: "completion", args: "<bash|zsh|fish>", maxArgs: 1, summary: "Print a shell completion script", choices: []string{"bash", "zsh", "fish"}, run: runCompletion},
+		&command{name: "help", args: "[command]", maxArgs: -1, summary: "Show help for a command", run: runHelp},
+		&command{name: "__complete", args: "<provider|model> [provider]", maxArgs: 2, hidden: true, run: runComplete},
+	)
+	root.find("help").choices = root.candidates()
+	return root
+}
+
+// globalFlags returns the flags every command accepts: help, version and one
+// flag per core environment variable (AIC_MODEL becomes --model), except for
+// API keys, which are only read from the environment.
+func globalFlags() []flagSpec {
+	flags := []flagSpec{
+		{name: "help", short: "h", kind: config.KindBool, help: "Show help for the command and exit"},
+		{name: "version", short: "v", kind: config.KindBool, help: "Show version and exit"},
+	}
+	for _, v := range config.CoreVars() {
+		if config.Secret(v.Name) {
+			continue
+		}
+		f := flagSpec{name: flagName(v.Name), kind: v.Kind, help: v.Help, env: v.Name}
+		switch v.Name {
+		case config.EnvAICProvider:
+			f.complete = compProvider
+		case config.EnvAICModel:
+			f.complete = compModel
+		case config.EnvAICSecrets:
+			f.complete = "redact abort"
+		}
+		flags = append(flags, f)
+	}
+	return flags
+}
+
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(env, "AIC_")), "_", "-")
+}
+
+func (c *command) add(subs ...*command) {
+	for _, s := range subs {
+		s.parent = c
+		c.sub = append(c.sub, s)
+	}
+}
+
+// path is the command line that invokes c, e.g. "aic cache clear".
+func (c *command) path() string {
+	if c.parent == nil {
+		return c.name
+	}
+	return c.parent.path() + " " + c.name
+}
+
+func (c *command) root() *command {
+	if c.parent == nil {
+		return c
+	}
+	return c.parent.root()
+}
+
+func (c *command) find(name string) *command {
+	for _, s := range c.sub {
+		if s.name == name {
+			return s
+		}
+		for _, a := range s.aliases {
+			if a == name {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the flag of c named name (long) or its one-letter alias.
+func (c *command) lookup(name string, short bool) (flagSpec, bool) {
+	for _, f := range append(c.flags, globalFlags()...) {
+		if (!short && f.name == name) || (short && f.short != "" && f.short == name) {
+			return f, true
+		}
+	}
+	return flagSpec{}, false
+}
+
+// owner returns the first subcommand below c that has the flag name, or nil.
+func (c *command) owner(name string, short bool) *command {
+	for _, s := range c.sub {
+		for _, f := range s.flags {
+			if (!short && f.name == name) || (short && f.short != "" && f.short == name) {
+				return s
+			}
+		}
+		if o := s.owner(name, short); o != nil {
+			return o
+		}
+	}
+	return nil
+}
+
+// invocation is a parsed command line.
+type invocation struct {
+	cmd *command
+	// flags holds the values of the flags given, by long name; bool flags
+	// are stored as "1" or "0".
+	flags map[string]string
+	args  []string
+}
+
+func (inv *invocation) String(name string) string { return inv.flags[name] }
+
+func (inv *invocation) Bool(name string) bool { return inv.flags[name] == "1" }
+
+// Int returns the value of an int flag, or def when it was not given.
+func (inv *invocation) Int(name string, def int) int {
+	n, err := strconv.Atoi(inv.flags[name])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// usageError reports a malformed command line for cmd.
+type usageError struct {
+	cmd *command
+	msg string
+}
+
+func (e *usageError) Error() string { return e.msg }
+
+// parseArgs resolves args against the command tree rooted at root. Global
+// flags may appear anywhere; the flags of a subcommand must follow its name
+// ("aic analyze --limit 5", not "aic --limit 5 analyze"). Flags take their
+// value as --flag=value or --flag value; bool flags only take the first form.
+// A "--" ends flag parsing.
+func parseArgs(root *command, args []string) (*invocation, error) {
+	inv := &invocation{cmd: root, flags: map[string]string{}}
+	given := map[string]string{} // long name -> spelling, to validate against the final command
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			inv.args = append(inv.args, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if len(inv.args) == 0 {
+				if sub := inv.cmd.find(arg); sub != nil {
+					inv.cmd = sub
+					continue
+				}
+			}
+			inv.args = append(inv.args, arg)
+			continue
+		}
+		short := !strings.HasPrefix(arg, "--")
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		spelling, _, _ := strings.Cut(arg, "=")
+		// Flags are resolved against every command on the line so far; the
+		// final command is checked once all subcommands are known.
+		var f flagSpec
+		var ok bool
+		for c := inv.cmd; c != nil && !ok; c = c.parent {
+			f, ok = c.lookup(name, short)
+		}
+		if !ok {
+			if owner := inv.cmd.owner(name, short); owner != nil {
+				return nil, &usageError{inv.cmd, fmt.Sprintf("flag %s belongs to '%s'; put it after the command name", spelling, owner.path())}
+			}
+			return nil, &usageError{inv.cmd, "unknown flag " + spelling}
+		}
+		switch {
+		case f.kind == config.KindBool:
+			if !hasValue {
+				value = "true"
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, &usageError{inv.cmd, fmt.Sprintf("invalid value %q for %s: want true or false", value, spelling)}
+			}
+			value = "0"
+			if b {
+				value = "1"
+			}
+		case !hasValue:
+			if i+1 >= len(args) || isFlag(args[i+1]) {
+				return nil, &usageError{inv.cmd, fmt.Sprintf("flag %s needs a value (use %s=<value> for values starting with -)", spelling, spelling)}
+			}
+			i++
+			value = args[i]
+		}
+		if f.kind == config.KindInt {
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, &usageError{inv.cmd, fmt.Sprintf("invalid value %q for %s: want a number", value, spelling)}
+			}
+		}
+		inv.flags[f.name] = value
+		given[f.name] = spelling
+	}
+	for name, spelling := range given {
+		if _, ok := inv.cmd.lookup(name, false); !ok {
+			return nil, &usageError{inv.cmd, fmt.Sprintf("flag %s is not supported by '%s'", spelling, inv.cmd.path())}
+		}
+	}
+	if len(inv.cmd.sub) > 0 && len(inv.args) > 0 {
+		return nil, &usageError{inv.cmd, fmt.Sprintf("unknown command %q for '%s'", inv.args[0], inv.cmd.path())}
+	}
+	if inv.cmd.maxArgs >= 0 && len(inv.args) > inv.cmd.maxArgs {
+		return nil, &usageError{inv.cmd, fmt.Sprintf("unexpected argument %q for '%s'", inv.args[inv.cmd.maxArgs], inv.cmd.path())}
+	}
+	return inv, nil
+}
+
+// isFlag reports whether arg looks like a flag rather than a value; negative
+// numbers count as values.
+func isFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") || arg == "-" {
+		return false
+	}
+	_, err := strconv.Atoi(arg)
+	return err != nil
+}
+
+// applyEnv exports the values of env-backed flags so that they take
+// precedence over the environment, which in turn wins over .aic.json.
+func (inv *invocation) applyEnv() {
+	for _, f := range globalFlags() {
+		if v, ok := inv.flags[f.name]; ok && f.env != "" {
+			os.Setenv(f.env, v)
+		}
+	}
+	if inv.Bool(flagName(config.EnvAICNoColor)) {
+		cli.DisableColors()
+	}
+}
+
+func runHelp(ctx context.Context, inv *invocation) {
+	cmd := inv.cmd.root()
+	for _, name := range inv.args {
+		sub := cmd.find(name)
+		if sub == nil {
+			fmt.Fprintf(os.Stderr, "aic: unknown command %q for '%s'\n", name, cmd.path())
+			os.Exit(2)
+		}
+		cmd = sub
+	}
+	fmt.Print(helpFor(cmd))
+}
+
+// helpFor renders the help of cmd from the command tree: usage, subcommands,
+// flags and, for the root command, the global flags and provider settings.
+func helpFor(cmd *command) string {
+	var b strings.Builder
+	section := func(title string) {
+		b.WriteString(fmt.Sprintf("%s%s%s:\n", cli.ColorBold, title, cli.ColorReset))
+	}
+	isRoot := cmd.parent == nil
+	if isRoot {
+		b.WriteString(fmt.Sprintf("%s%s aic%s – %s%s%s\n\n", cli.ColorBold, cli.ColorCyan, cli.ColorReset, cli.ColorMagenta, cmd.summary, cli.ColorReset))
+	} else {
+		b.WriteString(fmt.Sprintf("%s%s %s%s – %s%s%s\n\n", cli.ColorBold, cli.ColorCyan, cmd.path(), cli.ColorReset, cli.ColorMagenta, cmd.summary, cli.ColorReset))
+	}
+
+	section("Usage")
+	if cmd.run != nil {
+		usage := "  " + cmd.path() + " [flags]"
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		b.WriteString(usage + "\n")
+	}
+	if len(cmd.sub) > 0 {
+		b.WriteString("  " + cmd.path() + " <command> [flags]\n")
+	}
+	b.WriteString("\n")
+
+	if isRoot {
+		section("Description")
+		b.WriteString("  Generates conventional Git commit messages based on your staged changes.\n")
+		b.WriteString("  It requests suggestions from an AI model, lets you choose one, then offers to commit.\n")
+		b.WriteString("  Flags override environment variables, which override .aic.json.\n\n")
+	}
+
+	if len(cmd.sub) > 0 {
+		section("Commands")
+		rows := make([][3]string, 0, len(cmd.sub))
+		for _, s := range cmd.sub {
+			if s.hidden {
+				continue
+			}
+			name := s.name
+			if s.args != "" {
+				name += " " + s.args
+			}
+			rows = append(rows, [3]string{name, "", s.summary})
+		}
+		writeRows(&b, rows)
+		b.WriteString("\n")
+	}
+
+	flags := cmd.flags
+	if isRoot {
+		flags = append(flags, globalFlags()...)
+	} else {
+		flags = append(flags, globalFlags()[:2]...)
+	}
+	section("Flags")
+	rows := make([][3]string, 0, len(flags))
+	for _, f := range flags {
+		rows = append(rows, [3]string{f.usage(), f.env, f.help})
+	}
+	writeRows(&b, rows)
+	b.WriteString("\n")
+
+	if !isRoot {
+		b.WriteString(fmt.Sprintf("  %sAlso accepts the global flags (e.g. --model, --provider, --no-color); see 'aic --help'.%s\n", cli.ColorDim, cli.ColorReset))
+		return b.String()
+	}
+
+	section("Environment")
+	var env [][3]string
+	// API keys have no flag, so they are listed here.
+	for _, v := range config.CoreVars() {
+		if config.Secret(v.Name) {
+			env = append(env, [3]string{v.Name, "", v.Help})
+		}
+	}
+	for _, groups := range [][][2]string{config.HelpEnvRowsCustom(), config.HelpEnvRowsAzure(), config.HelpEnvRowsOllama()} {
+		for _, r := range groups {
+			env = append(env, [3]string{r[0], "", r[1]})
+		}
+	}
+	writeRows(&b, env)
+	b.WriteString("\n")
+	section("Example")
+	b.WriteString("  aic -s \"Refactor auth logic\"\n")
+	b.WriteString("  aic --provider=ollama --model llama3 --body\n")
+	return b.String()
+}
+
+// usage renders the flag for help, e.g. "-s, --system <text>".
+func (f flagSpec) usage() string {
+	s := "--" + f.name
+	if f.short != "" {
+		s = "-" + f.short + ", " + s
+	}
+	switch {
+	case f.value != "":
+		s += " <" + f.value + ">"
+	case f.kind == config.KindInt:
+		s += " <n>"
+	case f.kind == config.KindString:
+		s += " <value>"
+	}
+	return s
+}
+
+// writeRows prints aligned rows of name, environment variable and
+// description, highlighting required settings.
+func writeRows(b *strings.Builder, rows [][3]string) {
+	width := [2]int{}
+	for _, r := range rows {
+		width[0] = max(width[0], len(r[0]))
+		width[1] = max(width[1], len(r[1]))
+	}
+	for _, r := range rows {
+		color := cli.ColorCyan
+		if strings.Contains(r[2], "required") {
+			color = cli.ColorRed
+		}
+		b.WriteString(fmt.Sprintf("  %s%s%s%s  ", cli.ColorBold, r[0], cli.ColorReset, strings.Repeat(" ", width[0]-len(r[0]))))
+		if width[1] > 0 {
+			b.WriteString(fmt.Sprintf("%s%s%s%s  ", cli.ColorDim, r[1], cli.ColorReset, strings.Repeat(" ", width[1]-len(r[1]))))
+		}
+		b.WriteString(fmt.Sprintf("%s%s%s\n", color, r[2], cli.ColorReset))
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/provider"
+)
+
+// Completion scripts are generated from the command tree, like the help
+// output. Provider names and models are looked up at completion time through
+// the hidden "aic __complete" command, so they follow the registry and what
+// local servers offer. aic has no named configuration profiles (settings
+// come from flags, the environment and .aic.json), so there are none to
+// complete.
+
+// modelLookupTimeout bounds listing models from a custom or ollama server
+// while the user waits for a completion.
+const modelLookupTimeout = 2 * time.Second
+
+func runCompletion(ctx context.Context, inv *invocation) {
+	root := inv.cmd.root()
+	shell := ""
+	if len(inv.args) > 0 {
+		shell = inv.args[0]
+	}
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion(root))
+	case "zsh":
+		fmt.Print(zshCompletion(root))
+	case "fish":
+		fmt.Print(fishCompletion(root))
+	default:
+		fmt.Fprintf(os.Stderr, "aic: expected bash, zsh or fish\nRun 'aic completion --help' for usage.\n")
+		os.Exit(2)
+	}
+}
+
+// runComplete prints the values completed for --provider ("provider") or
+// --model ("model [provider]"), one per line. Without a provider the
+// configured one is used.
+func runComplete(ctx context.Context, inv *invocation) {
+	if len(inv.args) == 0 {
+		return
+	}
+	var values []string
+	switch inv.args[0] {
+	case "provider":
+		values = provider.Names()
+	case "model":
+		name := ""
+		if len(inv.args) > 1 {
+			// A fallback chain completes the models of its first provider.
+			name, _, _ = strings.Cut(inv.args[1], ",")
+		}
+		if name == "" {
+			if cfg, err := commit.LoadConfig(""); err == nil {
+				name = cfg.Provider
+			}
+		}
+		ctx, cancel := context.WithTimeout(ctx, modelLookupTimeout)
+		defer cancel()
+		values = provider.Models(ctx, name)
+	}
+	for _, v := range values {
+		fmt.Println(v)
+	}
+}
+
+// walk calls fn for c and every visible command below it, depth first.
+func (c *command) walk(fn func(*command)) {
+	fn(c)
+	for _, s := range c.sub {
+		if !s.hidden {
+			s.walk(fn)
+		}
+	}
+}
+
+// candidates returns what completes as the next positional argument of c:
+// its visible subcommands, or its choices.
+func (c *command) candidates() []string {
+	var out []string
+	for _, s := range c.sub {
+		if !s.hidden {
+			out = append(out, s.name)
+		}
+	}
+	if len(out) == 0 {
+		out = c.choices
+	}
+	return out
+}
+
+// compID names c in the scripts, e.g. "aic_cache_clear".
+func compID(c *command) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(c.path())
+}
+
+// transitions maps "parent:word" to the command the word selects, covering
+// aliases, in a stable order.
+func transitions(root *command) [][2]string {
+	var out [][2]string
+	root.walk(func(c *command) {
+		for _, s := range c.sub {
+			if s.hidden {
+				continue
+			}
+			for _, name := range append([]string{s.name}, s.aliases...) {
+				out = append(out, [2]string{compID(c) + ":" + name, compID(s)})
+			}
+		}
+	})
+	return out
+}
+
+// valueFlags returns every flag in the tree that takes a value, sorted by
+// name, with its spellings ("-s", "--system").
+func valueFlags(root *command) []flagSpec {
+	seen := map[string]bool{}
+	var out []flagSpec
+	add := func(flags []flagSpec) {
+		for _, f := range flags {
+			if f.kind == config.KindBool || seen[f.name] {
+				continue
+			}
+			seen[f.name] = true
+			out = append(out, f)
+		}
+	}
+	add(globalFlags())
+	root.walk(func(c *command) { add(c.flags) })
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// spellings returns the command-line forms of f.
+func (f flagSpec) spellings() []string {
+	if f.short != "" {
+		return []string{"-" + f.short, "--" + f.name}
+	}
+	return []string{"--" + f.name}
+}
+
+func flagWords(flags []flagSpec) string {
+	var words []string
+	for _, f := range flags {
+		words = append(words, f.spellings()...)
+	}
+	return strings.Join(words, " ")
+}
+
+func bashCompletion(root *command) string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for aic (generated by "aic completion bash")
+# Load with: source <(aic completion bash)
+_aic() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev= flag= cmdpath=aic provider= i w
+    (( COMP_CWORD > 0 )) && prev=${COMP_WORDS[COMP_CWORD-1]}
+    # Readline splits --flag=value into "--flag" "=" "value".
+    if [[ $cur == = ]]; then
+        flag=$prev cur=
+    elif [[ $prev == = ]]; then
+        flag=${COMP_WORDS[COMP_CWORD-2]}
+    else
+        flag=$prev
+    fi
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        w=${COMP_WORDS[i]}
+        if [[ ${COMP_WORDS[i+1]} == = ]]; then
+            [[ $w == --provider ]] && provider=${COMP_WORDS[i+2]}
+            ((i += 2))
+            continue
+        fi
+        case $w in
+`)
+	fmt.Fprintf(&b, "            %s)\n", strings.ReplaceAll(flagWords(valueFlags(root)), " ", "|"))
+	b.WriteString(`                [[ $w == --provider ]] && provider=${COMP_WORDS[i+1]}
+                ((i++))
+                continue
+                ;;
+            -*) continue ;;
+        esac
+        case $cmdpath:$w in
+`)
+	for _, t := range transitions(root) {
+		fmt.Fprintf(&b, "            %s) cmdpath=%s ;;\n", t[0], t[1])
+	}
+	b.WriteString(`        esac
+    done
+    case $flag in
+`)
+	for _, f := range valueFlags(root) {
+		pattern := strings.Join(f.spellings(), "|")
+		switch f.complete {
+		case compFile:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", pattern)
+		case compProvider:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"$(aic __complete provider 2>/dev/null)\" -- \"$cur\")); return ;;\n", pattern)
+		case compModel:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"$(aic __complete model \"$provider\" 2>/dev/null)\" -- \"$cur\")); return ;;\n", pattern)
+		case "":
+			fmt.Fprintf(&b, "        %s) return ;;\n", pattern)
+		default:
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W %s -- \"$cur\")); return ;;\n", pattern, shellQuote(f.complete))
+		}
+	}
+	fmt.Fprintf(&b, `    esac
+    local words= files= flags=%s
+    case $cmdpath in
+`, shellQuote(flagWords(globalFlags())))
+	root.walk(func(c *command) {
+		var set []string
+		if words := c.candidates(); len(words) > 0 {
+			set = append(set, "words="+shellQuote(strings.Join(words, " ")))
+		}
+		if len(c.flags) > 0 {
+			set = append(set, "flags+="+shellQuote(" "+flagWords(c.flags)))
+		}
+		if c.files {
+			set = append(set, "files=1")
+		}
+		if len(set) > 0 {
+			fmt.Fprintf(&b, "        %s) %s ;;\n", compID(c), strings.Join(set, " "))
+		}
+	})
+	b.WriteString(`    esac
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ -n $files ]]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    else
+        COMPREPLY=($(compgen -W "$words" -- "$cur"))
+    fi
+}
+complete -F _aic aic
+`)
+	return b.String()
+}
+
+// shellQuote single-quotes s for sh, zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// describe renders "name:description" entries for zsh's _describe.
+func describe(name, help string) string {
+	return shellQuote(strings.ReplaceAll(name, ":", `\:`) + ":" + help)
+}
+
+func zshCompletion(root *command) string {
+	var b strings.Builder
+	b.WriteString(`#compdef aic
+# zsh completion for aic (generated by "aic completion zsh")
+# Load with: source <(aic completion zsh), or save it as _aic in your $fpath.
+_aic() {
+  local cmdpath=aic provider= cur=${words[CURRENT]} flag=${words[CURRENT-1]} i w
+  local -a cmds flags
+  for ((i = 2; i < CURRENT; i++)); do
+    w=${words[i]}
+    case $w in
+      --provider=*) provider=${w#*=}; continue ;;
+`)
+	fmt.Fprintf(&b, "      %s)\n", strings.ReplaceAll(flagWords(valueFlags(root)), " ", "|"))
+	b.WriteString(`        [[ $w == --provider ]] && provider=${words[i+1]}
+        (( i++ ))
+        continue
+        ;;
+      -*) continue ;;
+    esac
+    case $cmdpath:$w in
+`)
+	for _, t := range transitions(root) {
+		fmt.Fprintf(&b, "      %s) cmdpath=%s ;;\n", t[0], t[1])
+	}
+	b.WriteString(`    esac
+  done
+  if [[ $cur == -*=* ]]; then
+    flag=${cur%%=*}
+    compset -P '*='
+  fi
+  case $flag in
+`)
+	for _, f := range valueFlags(root) {
+		pattern := strings.Join(f.spellings(), "|")
+		switch f.complete {
+		case compFile:
+			fmt.Fprintf(&b, "    %s) _files; return ;;\n", pattern)
+		case compProvider:
+			fmt.Fprintf(&b, "    %s) compadd -- ${(f)\"$(aic __complete provider 2>/dev/null)\"}; return ;;\n", pattern)
+		case compModel:
+			fmt.Fprintf(&b, "    %s) compadd -- ${(f)\"$(aic __complete model $provider 2>/dev/null)\"}; return ;;\n", pattern)
+		case "":
+			fmt.Fprintf(&b, "    %s) _message value; return ;;\n", pattern)
+		default:
+			fmt.Fprintf(&b, "    %s) compadd -- %s; return ;;\n", pattern, f.complete)
+		}
+	}
+	b.WriteString("  esac\n  flags=(\n")
+	for _, f := range globalFlags() {
+		for _, s := range f.spellings() {
+			fmt.Fprintf(&b, "    %s\n", describe(s, f.help))
+		}
+	}
+	b.WriteString("  )\n  case $cmdpath in\n")
+	root.walk(func(c *command) {
+		var entries []string
+		for _, s := range c.sub {
+			if !s.hidden {
+				entries = append(entries, describe(s.name, s.summary))
+			}
+		}
+		if len(entries) == 0 {
+			for _, choice := range c.choices {
+				entries = append(entries, shellQuote(choice))
+			}
+		}
+		var flags []string
+		for _, f := range c.flags {
+			for _, s := range f.spellings() {
+				flags = append(flags, describe(s, f.help))
+			}
+		}
+		if len(entries) == 0 && len(flags) == 0 && !c.files {
+			return
+		}
+		fmt.Fprintf(&b, "    %s)\n", compID(c))
+		if c.files {
+			b.WriteString("      [[ $cur != -* ]] && { _files; return }\n")
+		}
+		if len(entries) > 0 {
+			fmt.Fprintf(&b, "      cmds=(%s)\n", strings.Join(entries, " "))
+		}
+		if len(flags) > 0 {
+			fmt.Fprintf(&b, "      flags+=(%s)\n", strings.Join(flags, " "))
+		}
+		b.WriteString("      ;;\n")
+	})
+	b.WriteString(`  esac
+  if [[ $cur == -* ]]; then
+    _describe -t flags flag flags
+  else
+    _describe -t commands command cmds
+  fi
+}
+if [[ $funcstack[1] == _aic ]]; then
+  _aic "$@"
+else
+  compdef _aic aic
+fi
+`)
+	return b.String()
+}
+
+func fishCompletion(root *command) string {
+	var b strings.Builder
+	b.WriteString(`# fish completion for aic (generated by "aic completion fish")
+# Load with: aic completion fish | source
+function __aic_cmdpath
+    set -l cmdpath aic
+    set -l skip 0
+    for w in (commandline -opc)[2..-1]
+        if test $skip = 1
+            set skip 0
+            continue
+        end
+        switch $w
+`)
+	fmt.Fprintf(&b, "            case %s\n", flagWords(valueFlags(root)))
+	b.WriteString(`                set skip 1
+                continue
+            case '-*'
+                continue
+        end
+        switch "$cmdpath:$w"
+`)
+	for _, t := range transitions(root) {
+		fmt.Fprintf(&b, "            case %s\n                set cmdpath %s\n", t[0], t[1])
+	}
+	b.WriteString(`        end
+    end
+    echo $cmdpath
+end
+
+function __aic_at
+    test (__aic_cmdpath) = $argv[1]
+end
+
+# __aic_provider prints the --provider value given on the command line.
+function __aic_provider
+    set -l tokens (commandline -opc)
+    for i in (seq (count $tokens))
+        switch $tokens[$i]
+            case '--provider=*'
+                string replace -- --provider= '' $tokens[$i]
+                return
+            case --provider
+                set -q tokens[(math $i + 1)]; and echo $tokens[(math $i + 1)]
+                return
+        end
+    end
+end
+
+complete -c aic -f
+`)
+	fishFlag := func(cond string, f flagSpec) {
+		line := "complete -c aic"
+		if cond != "" {
+			line += " -n " + shellQuote(cond)
+		}
+		if f.short != "" {
+			line += " -s " + f.short
+		}
+		line += " -l " + f.name
+		switch {
+		case f.complete == compFile:
+			line += " -r -F"
+		case f.complete == compProvider:
+			line += " -x -a " + shellQuote("(aic __complete provider)")
+		case f.complete == compModel:
+			line += " -x -a " + shellQuote("(aic __complete model (__aic_provider))")
+		case f.complete != "":
+			line += " -x -a " + shellQuote(f.complete)
+		case f.kind != config.KindBool:
+			line += " -x"
+		}
+		b.WriteString(line + " -d " + shellQuote(f.help) + "\n")
+	}
+	for _, f := range globalFlags() {
+		fishFlag("", f)
+	}
+	root.walk(func(c *command) {
+		cond := "__aic_at " + compID(c)
+		for _, s := range c.sub {
+			if !s.hidden {
+				fmt.Fprintf(&b, "complete -c aic -n %s -a %s -d %s\n", shellQuote(cond), s.name, shellQuote(s.summary))
+			}
+		}
+		if len(c.sub) == 0 && len(c.choices) > 0 {
+			fmt.Fprintf(&b, "complete -c aic -n %s -a %s\n", shellQuote(cond), shellQuote(strings.Join(c.choices, " ")))
+		}
+		if c.files {
+			fmt.Fprintf(&b, "complete -c aic -n %s -F\n", shellQuote(cond))
+		}
+		for _, f := range c.flags {
+			fishFlag(cond, f)
+		}
+	})
+	return b.String()
+}
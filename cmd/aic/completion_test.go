@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestCompletionCoversCommandTree guards against drift: every visible
+// command and flag must appear in every generated script.
+func TestCompletionCoversCommandTree(t *testing.T) {
+	root := commands()
+	var words []string
+	root.walk(func(c *command) {
+		if c != root {
+			words = append(words, c.name)
+		}
+		for _, f := range c.flags {
+			words = append(words, "--"+f.name)
+		}
+	})
+	for _, f := range globalFlags() {
+		words = append(words, "--"+f.name)
+	}
+	for shell, script := range map[string]string{"bash": bashCompletion(root), "zsh": zshCompletion(root), "fish": fishCompletion(root)} {
+		for _, w := range words {
+			if shell == "fish" {
+				w = strings.TrimPrefix(w, "--")
+			}
+			if !strings.Contains(script, w) {
+				t.Errorf("%s completion misses %q", shell, w)
+			}
+		}
+		if !strings.Contains(script, "__complete provider") || !strings.Contains(script, "__complete model") {
+			t.Errorf("%s completion does not look up providers and models", shell)
+		}
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	// Complete with a stub "aic" that answers __complete like the real one.
+	script := bashCompletion(commands()) + `
+aic() { case "$2" in provider) printf '%s\n' openai ollama ;; model) echo "model-for-$3" ;; esac; }
+t() { COMP_WORDS=("$@"); COMP_CWORD=$(( ${#COMP_WORDS[@]} - 1 )); COMPREPLY=(); _aic; echo "${COMPREPLY[*]}"; }
+t aic ""
+t aic cache ""
+t aic --provider = o
+t aic --provider ollama --model ""
+t aic -s split ""
+t aic split --dr
+t aic --secrets ""
+`
+	out, err := exec.Command("bash", "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash: %v\n%s", err, out)
+	}
+	want := []string{
+		"analyze cache config hook split lint completion help",
+		"clear",
+		"openai ollama",
+		"model-for-ollama",
+		"analyze cache config hook split lint completion help",
+		"--dry-run",
+		"redact abort",
+	}
+	if got := strings.Split(strings.TrimRight(string(out), "\n"), "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("completions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
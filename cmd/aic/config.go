@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/provider"
+)
+
+// Sources of a setting, as shown by aic config.
+const (
+	srcRepo    = ".aic.json"
+	srcHome    = "~/.aic.json"
+	srcDefault = "default"
+)
+
+// setting is one row of aic config: the effective value and where it came from.
+type setting struct {
+	name, value, source string
+}
+
+// runConfig prints the effective configuration after merging flags,
+// environment variables and the repo and home .aic.json files, with the
+// source of each value.
+func runConfig(ctx context.Context, inv *invocation) {
+	cfg, err := commit.LoadConfig("")
+	if err != nil {
+		fatal(err)
+	}
+	rc, uc := config.LoadRepoConfig(), config.LoadUserConfig()
+	var rows []setting
+
+	src := envSource(inv, config.EnvAICProvider)
+	switch {
+	case src != "":
+	case len(uc.Providers) > 0:
+		src = srcHome
+	case len(rc.Providers) > 0:
+		src = srcRepo
+	default:
+		src = "detected from API keys"
+	}
+	rows = append(rows, setting{"provider", cfg.Provider, src})
+	if len(cfg.Fallbacks) > 0 {
+		rows = append(rows, setting{"fallbacks", strings.Join(cfg.Fallbacks, ", "), src})
+	}
+	if info, ok := provider.Lookup(cfg.Provider); ok && info.KeyEnv != "" {
+		rows = append(rows, setting{"api key", maskKey(config.Get(info.KeyEnv)), or(envSource(inv, info.KeyEnv), info.KeyEnv)})
+	}
+	rows = append(rows, setting{"model", or(cfg.Model, "(picked by the server)"), or(envSource(inv, config.EnvAICModel), srcDefault+" for "+cfg.Provider)})
+
+	src = envSource(inv, config.EnvAICSuggestions)
+	if src == "" && config.Bool(config.EnvAICNonInteractive) {
+		src = config.EnvAICNonInteractive
+	}
+	rows = append(rows, setting{"suggestions", strconv.Itoa(cfg.Suggestions), or(src, srcDefault)})
+
+	src = envSource(inv, config.EnvAICBody)
+	switch {
+	case src != "":
+	case rc.Body:
+		src = srcRepo
+	case uc.Body:
+		src = srcHome
+	}
+	rows = append(rows, setting{"body", strconv.FormatBool(cfg.Body), or(src, srcDefault)})
+
+	rows = append(rows, setting{"instructions", or(firstRunes(cfg.SystemAddition, 60), "(none)"), sources(rc.Instructions != "", uc.Instructions != "")})
+
+	lintSrc := srcDefault
+	if rc.Lint != nil {
+		lintSrc = srcRepo
+	} else if uc.Lint != nil {
+		lintSrc = srcHome
+	}
+	rows = append(rows, setting{"lint", fmt.Sprintf("types %s; header <= %d chars", strings.Join(cfg.Lint.AllowedTypes(), ","), cfg.Lint.MaxHeader()), lintSrc})
+
+	for _, list := range []struct {
+		name     string
+		rc, uc   []string
+		fallback string
+	}{
+		{"exclude", rc.Exclude, uc.Exclude, "(built-in lockfiles and generated files)"},
+		{"include", rc.Include, uc.Include, "(none)"},
+		{"redact", rc.Redact, uc.Redact, "(built-in detectors)"},
+	} {
+		all := append(append([]string{}, list.rc...), list.uc...)
+		rows = append(rows, setting{list.name, or(strings.Join(all, ", "), list.fallback), sources(len(list.rc) > 0, len(list.uc) > 0)})
+	}
+	if len(cfg.ContextWindows) > 0 {
+		models := make([]string, 0, len(cfg.ContextWindows))
+		for m, w := range cfg.ContextWindows {
+			models = append(models, m+"="+strconv.Itoa(w))
+		}
+		sort.Strings(models)
+		rows = append(rows, setting{"context windows", strings.Join(models, ", "), sources(len(rc.ContextWindows) > 0, len(uc.ContextWindows) > 0)})
+	}
+	if cfg.Provider == "azure" || slices.Contains(cfg.Fallbacks, "azure") {
+		az := config.LoadAzure()
+		rows = append(rows,
+			setting{"azure endpoint", or(az.Endpoint, "(not set)"), azureSource(inv, config.EnvAzureOpenAIEndpoint, nil, uc.Azure, func(a *config.Azure) string { return a.Endpoint })},
+			setting{"azure deployment", or(az.Deployment, "(the model)"), azureSource(inv, config.EnvAzureOpenAIDeployment, rc.Azure, uc.Azure, func(a *config.Azure) string { return a.Deployment })},
+			setting{"azure api version", or(az.APIVersion, "(client default)"), azureSource(inv, config.EnvAzureOpenAIAPIVersion, rc.Azure, uc.Azure, func(a *config.Azure) string { return a.APIVersion })},
+		)
+	}
+
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r.name))
+	}
+	fmt.Printf("%s%s Effective configuration:%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
+	for _, r := range rows {
+		fmt.Printf("  %-*s  %s %s(%s)%s\n", width, r.name, r.value, cli.ColorDim, r.source, cli.ColorReset)
+	}
+	if config.Bool(config.EnvAICDisableRepoConfig) {
+		fmt.Printf("%s%s The repo .aic.json is ignored (%s).%s\n", cli.ColorYellow, cli.IconInfo, config.EnvAICDisableRepoConfig, cli.ColorReset)
+	}
+}
+
+// envSource returns the flag or environment variable that sets env var name
+// ("--model" or "AIC_MODEL"), or "" when neither does.
+func envSource(inv *invocation, name string) string {
+	if _, ok := inv.flags[flagName(name)]; ok {
+		return "--" + flagName(name)
+	}
+	if strings.TrimSpace(os.Getenv(name)) != "" {
+		return name
+	}
+	return ""
+}
+
+// azureSource is envSource for an azure setting, falling back to the .aic.json
+// that sets it.
+func azureSource(inv *invocation, env string, rc, uc *config.Azure, field func(*config.Azure) string) string {
+	if src := envSource(inv, env); src != "" {
+		return src
+	}
+	if uc != nil && field(uc) != "" {
+		return srcHome
+	}
+	if rc != nil && field(rc) != "" {
+		return srcRepo
+	}
+	return srcDefault
+}
+
+// sources names the files contributing to a merged setting.
+func sources(repo, home bool) string {
+	switch {
+	case repo && home:
+		return srcRepo + " + " + srcHome
+	case repo:
+		return srcRepo
+	case home:
+		return srcHome
+	}
+	return srcDefault
+}
+
+// maskKey shows whether an API key is set without printing it.
+func maskKey(key string) string {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "(not set)"
+	}
+	if len(key) < 12 {
+		return "set"
+	}
+	return "set (..." + key[len(key)-4:] + ")"
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func firstRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
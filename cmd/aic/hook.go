@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/hook"
+	"github.com/diesi/aic/internal/lint"
+)
+
+// hookDir returns the directory the hook commands act on: the repository's
+// hooks, or with --global the template directory.
+func hookDir(inv *invocation, create bool) hook.Dir {
+	var dir hook.Dir
+	var err error
+	if inv.Bool("global") {
+		dir, err = hook.GlobalDir(create)
+	} else {
+		dir, err = hook.RepoDir()
+	}
+	if err != nil {
+		fatal(err)
+	}
+	return dir
+}
+
+func runHookInstall(ctx context.Context, inv *invocation) {
+	names := []string{hook.PrepareCommitMsg}
+	if inv.Bool("lint") {
+		names = append(names, hook.CommitMsg)
+	}
+	dir := hookDir(inv, true)
+	installed, err := dir.Install(names)
+	for _, st := range installed {
+		fmt.Printf("%s%s Installed %s%s %s(%s)%s\n", cli.ColorGreen, cli.IconSuccess, st.Name, cli.ColorReset, cli.ColorDim, st.Path, cli.ColorReset)
+		if st.Chained != "" {
+			fmt.Printf("  %sThe existing hook was kept as %s and runs first.%s\n", cli.ColorDim, filepath.Base(st.Chained), cli.ColorReset)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if dir.Global {
+		fmt.Printf("%s%s New clones and repositories get these hooks; run 'git init' in an existing repository to add them there.%s\n", cli.ColorGray, cli.IconInfo, cli.ColorReset)
+	}
+	if _, err := exec.LookPath("aic"); err != nil {
+		fmt.Printf("%s%s aic is not on PATH; the hooks do nothing until it is.%s\n", cli.ColorYellow, cli.IconInfo, cli.ColorReset)
+	}
+}
+
+func runHookUninstall(ctx context.Context, inv *invocation) {
+	dir := hookDir(inv, false)
+	removed, err := dir.Uninstall()
+	for _, st := range removed {
+		fmt.Printf("%s%s Removed %s%s %s(%s)%s\n", cli.ColorGreen, cli.IconSuccess, st.Name, cli.ColorReset, cli.ColorDim, st.Path, cli.ColorReset)
+		if st.Chained != "" {
+			fmt.Printf("  %sRestored the hook that was there before.%s\n", cli.ColorDim, cli.ColorReset)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if len(removed) == 0 {
+		fmt.Printf("%s%s No aic hooks in %s%s\n", cli.ColorGray, cli.IconInfo, dir.Path, cli.ColorReset)
+	}
+}
+
+func runHookStatus(ctx context.Context, inv *invocation) {
+	dir := hookDir(inv, false)
+	fmt.Printf("%s%s Hooks directory:%s %s %s(%s)%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset, dir.Path, cli.ColorDim, dir.Source, cli.ColorReset)
+	for _, st := range dir.Inspect() {
+		color := cli.ColorDim
+		if st.State == hook.Installed {
+			color = cli.ColorGreen
+		}
+		line := st.State.String()
+		if st.Outdated {
+			line += ", outdated (run 'aic hook install' to update)"
+		}
+		if st.Chained != "" {
+			line += ", runs " + filepath.Base(st.Chained) + " first"
+		}
+		fmt.Printf("  %-20s %s%s%s\n", st.Name, color, line, cli.ColorReset)
+	}
+	if path, err := exec.LookPath("aic"); err == nil {
+		fmt.Printf("  %-20s %s\n", "aic on PATH", path)
+	} else {
+		fmt.Printf("  %-20s %snot found; the hooks do nothing until it is%s\n", "aic on PATH", cli.ColorYellow, cli.ColorReset)
+	}
+}
+
+// runHookPrepareCommitMsg is the prepare-commit-msg hook: it puts a
+// suggestion into the message file git prepared, above git's comments. It
+// never fails the commit; problems are reported and the file is left alone.
+func runHookPrepareCommitMsg(ctx context.Context, inv *invocation) {
+	if len(inv.args) == 0 {
+		fmt.Fprintf(os.Stderr, "aic: expected the commit message file\nRun 'aic hook prepare-commit-msg --help' for usage.\n")
+		os.Exit(2)
+	}
+	if config.Bool(config.EnvAICSkipHook) {
+		return
+	}
+	path, source, sha := inv.args[0], "", ""
+	if len(inv.args) > 1 {
+		source = inv.args[1]
+	}
+	if len(inv.args) > 2 {
+		sha = inv.args[2]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		hookWarn(err)
+		return
+	}
+	commentChar := git.CommentChar()
+	action := hook.Decide(source, sha, lint.Clean(string(data), commentChar), hook.EditorShown())
+	if action == hook.First && source == "message" && hook.GitAmends() {
+		// Git reports -m and -F the same with or without --amend, so the
+		// suggestion would describe the staged changes only.
+		fmt.Fprintf(os.Stderr, "%s[aic] cannot generate the message of 'git commit --amend -m aic'; run 'aic --amend', or 'git commit --amend' without -m, instead.%s\n", cli.ColorRed, cli.ColorReset)
+		os.Exit(hook.ExitAbort)
+	}
+	switch action {
+	case hook.Skip:
+		return
+	case hook.Select:
+		if !attachTerminal() {
+			os.Setenv(config.EnvAICNonInteractive, "1")
+		}
+	case hook.First:
+		os.Setenv(config.EnvAICNonInteractive, "1")
+	}
+	cfg, err := commit.LoadConfig("")
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	if cfg.Situation, err = commit.DetectSituation(hook.IsAmend(source, sha)); err != nil {
+		hookWarn(err)
+		return
+	}
+	msg, err := suggest(ctx, cfg)
+	if err != nil {
+		hookWarn(err)
+		return
+	}
+	if err := hook.WriteMessage(path, msg, commentChar); err != nil {
+		hookWarn(err)
+		return
+	}
+	if action == hook.First {
+		subject, _, _ := strings.Cut(msg, "\n")
+		fmt.Printf("[aic] %s\n", subject)
+	}
+}
+
+// attachTerminal points stdin at the terminal when git runs the hook without
+// one, so that the selector can read keys. It reports whether a terminal is
+// available.
+func attachTerminal() bool {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return true
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	os.Stdin = tty
+	return true
+}
+
+func hookWarn(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s[aic] no message generated: %v%s\n", cli.ColorYellow, err, cli.ColorReset)
+}
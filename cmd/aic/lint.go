@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/lint"
+)
+
+// runLint checks a hand-written message against the commit rules of
+// .aic.json. It exits 1 when rules are broken, so it can serve as a
+// commit-msg hook.
+func runLint(ctx context.Context, inv *invocation) {
+	if len(inv.args) == 0 {
+		fmt.Fprintf(os.Stderr, "aic: expected a message file, or - for stdin\nRun 'aic lint --help' for usage.\n")
+		os.Exit(2)
+	}
+	name := inv.args[0]
+	var data []byte
+	var err error
+	if name == "-" {
+		name = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	// git aborts empty messages itself, and its own messages are left alone.
+	msg := lint.Clean(string(data), git.CommentChar())
+	if msg == "" || lint.Exempt(msg) {
+		return
+	}
+	rules := commit.LoadLintRules()
+	violations := rules.Check(msg)
+	if len(violations) == 0 {
+		return
+	}
+	subject, _, _ := strings.Cut(msg, "\n")
+	rulesWord := "rules"
+	if len(violations) == 1 {
+		rulesWord = "rule"
+	}
+	fmt.Fprintf(os.Stderr, "%s%s %s: the commit message breaks %d %s:%s\n", cli.ColorRed, cli.IconError, name, len(violations), rulesWord, cli.ColorReset)
+	fmt.Fprintf(os.Stderr, "  %s%s%s\n", cli.ColorDim, subject, cli.ColorReset)
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "  %s- %s:%s %s\n", cli.ColorYellow, v.Rule, cli.ColorReset, v.Message)
+	}
+	if inv.Bool("suggest-fix") {
+		cfg, err := commit.LoadConfig("")
+		if err != nil {
+			fatal(err)
+		}
+		cfg.Lint = rules
+		fix, err := commit.SuggestFix(ctx, cfg, cfg.APIKey(), msg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%s Could not suggest a fix: %v%s\n", cli.ColorYellow, cli.IconInfo, err, cli.ColorReset)
+		} else {
+			printFix(fix)
+		}
+	} else if fix, left := rules.Fix(msg); len(left) == 0 {
+		printFix(fix)
+	} else {
+		fmt.Fprintf(os.Stderr, "%sRun 'aic lint --suggest-fix %s' to have the provider rewrite it.%s\n", cli.ColorDim, inv.args[0], cli.ColorReset)
+	}
+	os.Exit(1)
+}
+
+func printFix(fix string) {
+	fmt.Fprintf(os.Stderr, "%s%s Suggested fix:%s\n", cli.ColorGreen, cli.IconSuccess, cli.ColorReset)
+	fmt.Fprintf(os.Stderr, "  %s%s%s\n", cli.ColorGreen, strings.ReplaceAll(fix, "\n", "\n  "), cli.ColorReset)
+}
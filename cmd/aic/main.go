@@ -1,225 +1,297 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/diesi/aic/internal/analyze"
+	"github.com/diesi/aic/internal/cache"
 	"github.com/diesi/aic/internal/cli"
 	"github.com/diesi/aic/internal/commit"
 	"github.com/diesi/aic/internal/config"
 	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/hook"
+	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/split"
 	"github.com/diesi/aic/internal/version"
-	"strconv"
 )
 
 func main() {
 	// Environment variables are used directly; no .env file loading.
-	var systemAddition string
-	var hookFile string
-	args := os.Args[1:]
-
-	// Subcommand: analyze
-	if len(args) > 0 && (args[0] == "analyze" || args[0] == "analyse") {
-		runAnalyze(args[1:])
+	root := commands()
+	inv, err := parseArgs(root, os.Args[1:])
+	if err != nil {
+		var uerr *usageError
+		errors.As(err, &uerr)
+		fmt.Fprintf(os.Stderr, "aic: %v\nRun '%s --help' for usage.\n", err, uerr.cmd.path())
+		os.Exit(2)
+	}
+	inv.applyEnv()
+	if inv.Bool("help") {
+		fmt.Print(helpFor(inv.cmd))
 		return
 	}
-
-	// Soft warning for unknown/unused AIC_* variables to catch typos/misconfig
-	config.WarnUnknownAICEnv()
-
-	// Simple flag parsing
-	for i, arg := range args {
-		if arg == "-h" || arg == "--help" || arg == "help" {
-			fmt.Print(buildHelp())
-			return
-		}
-		if arg == "--version" || arg == "-v" {
-			fmt.Printf("aic %s\n", version.Get())
-			return
-		}
-		if arg == "--no-color" {
-			cli.DisableColors()
-			// remove the flag from further consideration
-			continue
-		}
-		if arg == "--hook" {
-			if i+1 < len(args) {
-				hookFile = args[i+1]
-			}
-			continue
-		}
-		if arg == "-s" {
-			if i+1 < len(args) {
-				systemAddition = args[i+1]
-				// basic: assumes value isn't another flag
-			}
-		}
+	if inv.Bool("version") {
+		fmt.Printf("aic %s\n", version.Get())
+		return
 	}
-
-	cfg, err := commit.LoadConfig(systemAddition)
-	if err != nil {
-		fatal(err)
+	if inv.cmd.run == nil {
+		fmt.Fprint(os.Stderr, helpFor(inv.cmd))
+		os.Exit(2)
 	}
-
-	// Show which staged files are included in the diff (for transparency)
-	if files, err := git.StagedFiles(); err == nil && len(files) > 0 {
-		fmt.Printf("%s%s Staged changes:%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
-		for _, f := range files {
-			fmt.Printf("  %s- %s%s\n", cli.ColorYellow, f, cli.ColorReset)
-		}
+	if inv.cmd == root {
+		// Soft warning for unknown/unused AIC_* variables to catch typos/misconfig
+		config.WarnUnknownAICEnv()
 	}
 
-	stop := cli.Spinner(fmt.Sprintf("Requesting %d suggestions from %s", cfg.Suggestions, cfg.Model))
-	var apiKey string
-	switch cfg.Provider {
-	case "claude":
-		apiKey = config.Get(config.EnvClaudeAPIKey)
-	case "gemini":
-		apiKey = config.Get(config.EnvGeminiAPIKey)
-	case "custom":
-		apiKey = config.Get(config.EnvCustomAPIKey) // may be empty for local servers
-	default:
-		apiKey = config.Get(config.EnvOpenAIAPIKey)
-	}
-	suggestions, err := commit.GenerateSuggestions(cfg, apiKey)
-	stop(err == nil)
+	// Ctrl+C / SIGTERM cancel provider requests in flight and restore the terminal.
+	ctx, cancel := interruptContext()
+	defer cancel()
+	inv.cmd.run(ctx, inv)
+}
+
+// runSuggest is the default command: suggest messages for the staged changes,
+// let the user pick one and commit it (or write it to the --hook file).
+func runSuggest(ctx context.Context, inv *invocation) {
+	hookFile := inv.String("hook")
+	cfg, err := commit.LoadConfig(inv.String("system"))
 	if err != nil {
-		if isInvalidKeyErr(err) {
-			switch cfg.Provider {
-			case "claude":
-				fmt.Fprintln(os.Stderr, "Hint: Ensure your real CLAUDE_API_KEY is exported (export CLAUDE_API_KEY=sk-...)")
-			case "gemini":
-				fmt.Fprintln(os.Stderr, "Hint: Ensure your real GEMINI_API_KEY is exported (export GEMINI_API_KEY=sk-...)")
-			default:
-				fmt.Fprintln(os.Stderr, "Hint: Ensure your real OPENAI_API_KEY is exported (export OPENAI_API_KEY=sk-...)")
-			}
-		}
 		fatal(err)
 	}
-	msg, err := commit.PromptUserSelect(suggestions)
+	amend := inv.Bool("amend")
+	if cfg.Situation, err = commit.DetectSituation(amend); err != nil {
+		fatal(err)
+	}
+	msg, err := suggest(ctx, cfg)
 	if err != nil {
 		fatal(err)
 	}
 	// If invoked as a Git hook, write the message to the given file and exit.
 	if hookFile != "" {
-		if err := os.WriteFile(hookFile, []byte(msg+"\n"), 0644); err != nil {
+		if err := hook.WriteMessage(hookFile, msg, git.CommentChar()); err != nil {
 			fatal(fmt.Errorf("failed to write hook message file: %w", err))
 		}
 		return
 	}
-	if err := commit.OfferCommit(msg); err != nil {
+	offer := commit.OfferCommit
+	if amend {
+		offer = commit.OfferAmend
+	}
+	if err := offer(msg); err != nil {
 		fatal(err)
 	}
 }
 
-func runAnalyze(args []string) {
-    // Defaults
+// suggest lists the staged files, asks for suggestions and returns the one
+// the user picks. A merge, squash or amend (cfg.Situation) is shown first.
+func suggest(ctx context.Context, cfg commit.Config) (string, error) {
+	if s := cfg.Situation.Describe(); s != "" {
+		fmt.Printf("%s%s %s%s\n", cli.ColorGray, cli.IconInfo, s, cli.ColorReset)
+	}
+	if cfg.Situation.Kind == commit.Amend {
+		fmt.Printf("%s%s Previous message:%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
+		fmt.Printf("  %s%s%s\n", cli.ColorDim, strings.ReplaceAll(cfg.Situation.Previous, "\n", "\n  "), cli.ColorReset)
+	}
+	// Show which staged files are included in the diff (for transparency)
+	if files, err := git.StagedFilesFrom(cfg.Situation.Base); err == nil && len(files) > 0 {
+		fmt.Printf("%s%s Staged changes:%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
+		filter := cfg.DiffFilter()
+		for _, f := range files {
+			if filter.Excluded(f) {
+				// Only a "+added -deleted" stat line is sent for this file.
+				fmt.Printf("  %s- %s%s %s(stats only)%s\n", cli.ColorYellow, f, cli.ColorReset, cli.ColorDim, cli.ColorReset)
+				continue
+			}
+			fmt.Printf("  %s- %s%s\n", cli.ColorYellow, f, cli.ColorReset)
+		}
+	}
+	// Warn about likely secrets before anything is sent; they are redacted either way.
+	if findings, err := commit.ScanSecrets(cfg); err == nil {
+		if err := commit.ConfirmSecrets(findings); err != nil {
+			return "", err
+		}
+	}
+
+	apiKey := cfg.APIKey()
+	if commit.StreamingEnabled() {
+		// Suggestions are drawn into the selector as they arrive; the user may pick early.
+		return commit.PromptUserSelectStream(ctx, commit.StreamSuggestions(ctx, cfg, apiKey))
+	}
+	stop := cli.Spinner(fmt.Sprintf("Requesting %d suggestions from %s", cfg.Suggestions, cfg.Model))
+	suggestions, source, err := commit.GenerateSuggestions(ctx, cfg, apiKey)
+	stop(err == nil)
+	if err != nil {
+		return "", err
+	}
+	return commit.PromptUserSelect(ctx, cfg, apiKey, suggestions, source)
+}
+
+func runAnalyze(ctx context.Context, inv *invocation) {
     limit := 1000
-    // Allow simple flag: --limit N
-    for i := 0; i < len(args); i++ {
-        if args[i] == "--limit" && i+1 < len(args) {
-            if n, err := strconv.Atoi(args[i+1]); err == nil && n > 0 {
-                limit = n
-            }
-            i++
-        }
+    if n := inv.Int("limit", limit); n > 0 {
+        limit = n
     }
     // Build a config without extra instructions to avoid biasing analysis
     cfg, err := commit.LoadConfig("")
     if err != nil {
         fatal(err)
     }
-    var apiKey string
-    switch cfg.Provider {
-    case "claude":
-        apiKey = config.Get(config.EnvClaudeAPIKey)
-    case "gemini":
-        apiKey = config.Get(config.EnvGeminiAPIKey)
-    case "custom":
-        apiKey = config.Get(config.EnvCustomAPIKey)
-    default:
-        apiKey = config.Get(config.EnvOpenAIAPIKey)
-    }
-    res, err := analyze.Analyze(limit, cfg, apiKey)
+    apiKey := cfg.APIKey()
+    res, err := analyze.Analyze(ctx, limit, cfg, apiKey)
     if err != nil {
         fatal(err)
     }
     if err := config.SaveRepoInstructions(res.Instructions); err != nil {
         fatal(err)
     }
+    wroteLint, err := config.SaveRepoLint(res.Lint)
+    if err != nil {
+        fatal(err)
+    }
     fmt.Printf("%s%s Wrote repo .aic.json%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
     fmt.Printf("  %sAnalyzed %d commit subjects and generated style instructions.%s\n", cli.ColorDim, res.SampleTotal, cli.ColorReset)
+    if wroteLint {
+        fmt.Printf("  %sInferred commit rules for aic lint (\"lint\" in .aic.json).%s\n", cli.ColorDim, cli.ColorReset)
+    }
+}
+
+func runCacheClear(ctx context.Context, inv *invocation) {
+	if err := cache.Clear(); err != nil {
+		fatal(err)
+	}
+	root, _ := cache.Root()
+	fmt.Printf("%s%s Cleared response cache%s %s(%s)%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset, cli.ColorDim, root, cli.ColorReset)
 }
 
-func buildHelp() string {
-	// Core env rows come from config, then CLI flags, then custom-provider env rows.
-	rows := make([][2]string, 0, 32)
-	rows = append(rows, config.HelpEnvRowsCore()...)
-	rows = append(rows,
-		[2]string{"--version / -v", "Show version and exit"},
-		[2]string{"--no-color", "Disable colored output (alias: AIC_NO_COLOR=1)"},
-		[2]string{"--hook <file>", "Hook mode: write selected message to file and exit"},
-		[2]string{"analyze [--limit N]", "Infer repo commit style and write .aic.json"},
-	)
-	rows = append(rows, config.HelpEnvRowsCustom()...)
-	maxVar := 0
-	for _, r := range rows {
-		if len(r[0]) > maxVar {
-			maxVar = len(r[0])
+func runSplit(ctx context.Context, inv *invocation) {
+	dryRun := inv.Bool("dry-run")
+	cfg, err := commit.LoadConfig("")
+	if err != nil {
+		fatal(err)
+	}
+	patch, err := git.StagedPatch()
+	if err != nil {
+		fatal(err)
+	}
+	units := split.Units(patch)
+	if len(units) == 0 {
+		fatal(errors.New("no staged changes to split"))
+	}
+	if findings, err := commit.ScanSecrets(cfg); err == nil {
+		if err := commit.ConfirmSecrets(findings); err != nil {
+			fatal(err)
 		}
 	}
-	var b strings.Builder
-	b.WriteString(fmt.Sprintf("%s%s aic%s – %sAI-assisted git commit message generator%s\n\n", cli.ColorBold, cli.ColorCyan, cli.ColorReset, cli.ColorMagenta, cli.ColorReset))
-	b.WriteString(fmt.Sprintf("%sUsage%s:\n", cli.ColorBold, cli.ColorReset))
-	b.WriteString("  aic [-s \"extra instruction\"] [--version] [--no-color]\n\n")
-	b.WriteString(fmt.Sprintf("%sDescription%s:\n", cli.ColorBold, cli.ColorReset))
-    b.WriteString("  Generates conventional Git commit messages based on your staged changes.\n")
-    b.WriteString("  It requests suggestions from an AI model, lets you choose one, then offers to commit.\n")
-    b.WriteString("  Also includes 'aic analyze' to infer repo style and write .aic.json presets.\n\n")
-	b.WriteString(fmt.Sprintf("%sArguments & Environment%s:\n", cli.ColorBold, cli.ColorReset))
-	for _, r := range rows {
-		pad := strings.Repeat(" ", maxVar-len(r[0]))
-		color := cli.ColorCyan
-		if strings.Contains(r[1], "required") {
-			color = cli.ColorRed
+	stop := cli.Spinner(fmt.Sprintf("Planning commits for %d change units via %s", len(units), cfg.Model))
+	plan, err := split.Propose(ctx, cfg, cfg.APIKey(), units)
+	stop(err == nil)
+	if err != nil {
+		fatal(err)
+	}
+	printPlan(plan)
+	if dryRun {
+		return
+	}
+	if config.Bool(config.EnvAICNonInteractive) {
+		if !config.Bool(config.EnvAICAutoCommit) {
+			fmt.Printf("Non-interactive mode: skipping commits (set AIC_AUTO_COMMIT=1 to enable).\n")
+			return
+		}
+	} else {
+		fmt.Printf("\n%s%s Create these %d commits?%s %s[Y|n|e]%s %s[default: Y, e: edit messages]%s: %s",
+			cli.ColorBold, cli.IconPrompt, len(plan.Groups), cli.ColorReset, cli.ColorYellow, cli.ColorReset, cli.ColorDim, cli.ColorReset, cli.ColorCyan)
+		var choice string
+		fmt.Scanln(&choice)
+		fmt.Printf("%s", cli.ColorReset)
+		switch strings.ToLower(choice) {
+		case "", "y":
+		case "e":
+			for i, g := range plan.Groups {
+				// List what this commit takes, not everything staged.
+				labels := make([]string, len(g.Units))
+				for j, id := range g.Units {
+					labels[j] = plan.Unit(id).Label()
+				}
+				msg, err := commit.EditMessageFor(g.Message, labels)
+				if err != nil {
+					fatal(err)
+				}
+				plan.Groups[i].Message = msg
+			}
+			printPlan(plan)
+		default:
+			return
 		}
-		b.WriteString(fmt.Sprintf("  %s%s%s%s  %s%s%s\n", cli.ColorBold, r[0], cli.ColorReset, pad, color, r[1], cli.ColorReset))
 	}
-	b.WriteString("\n")
-	b.WriteString(fmt.Sprintf("%sExample%s:\n", cli.ColorBold, cli.ColorReset))
-	b.WriteString("  aic -s \"Refactor auth logic\"\n")
-	return b.String()
+	n, err := split.Apply(plan, git.Commit)
+	if err != nil {
+		fatal(fmt.Errorf("split stopped after %d of %d commits; the original index was restored: %w", n, len(plan.Groups), err))
+	}
+	fmt.Printf("%s%s Created %d commits%s\n", cli.ColorGray, cli.ColorBold, n, cli.ColorReset)
 }
-func fatal(err error) {
-	// Provide nicer categorized errors
-	banner := fmt.Sprintf("%s%s %sERROR%s", cli.ColorBold, cli.ColorRed, cli.IconError, cli.ColorReset)
-	hintLines := []string{}
-	msg := err.Error()
-	lower := strings.ToLower(msg)
-	switch {
-	case strings.Contains(lower, "no staged changes"):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Stage changes first, e.g.: %sgit add -p%s", cli.ColorYellow, cli.IconInfo, cli.ColorReset, cli.ColorGreen, cli.ColorReset))
-	case strings.Contains(lower, "not a git repository"):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Run %sgit init%s or cd into a repo.", cli.ColorYellow, cli.IconInfo, cli.ColorReset, cli.ColorGreen, cli.ColorReset))
-	case strings.Contains(lower, "missing openai_api_key"):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Export your key: %sexport OPENAI_API_KEY=sk-***%s", cli.ColorYellow, cli.IconInfo, cli.ColorReset, cli.ColorGreen, cli.ColorReset))
-	case strings.Contains(lower, "missing claude_api_key"):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Export your key: %sexport CLAUDE_API_KEY=sk-***%s", cli.ColorYellow, cli.IconInfo, cli.ColorReset, cli.ColorGreen, cli.ColorReset))
-	case strings.Contains(lower, "missing gemini_api_key"):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Export your key: %sexport GEMINI_API_KEY=sk-***%s", cli.ColorYellow, cli.IconInfo, cli.ColorReset, cli.ColorGreen, cli.ColorReset))
-	case isRateLimitErr(lower):
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Rate limits; wait or lower suggestions (AIC_SUGGESTIONS=3).", cli.ColorYellow, cli.IconInfo, cli.ColorReset))
+
+// printPlan shows each planned commit with the files and hunks it takes.
+func printPlan(plan split.Plan) {
+	fmt.Printf("%s%s %sProposed commits:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, cli.ColorReset)
+	for i, g := range plan.Groups {
+		subject, body, _ := strings.Cut(g.Message, "\n")
+		fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorGreen, subject, cli.ColorReset)
+		if body = strings.TrimSpace(body); body != "" {
+			fmt.Printf("%s      %s%s\n", cli.ColorDim, strings.ReplaceAll(body, "\n", "\n      "), cli.ColorReset)
+		}
+		for _, id := range g.Units {
+			fmt.Printf("      %s- %s%s\n", cli.ColorCyan, plan.Unit(id).Label(), cli.ColorReset)
+		}
 	}
+}
+
+func buildHelp() string { return helpFor(commands()) }
 
-	// Add generic retry suggestion for transient network errors
-	if strings.Contains(lower, "timeout") || strings.Contains(lower, "temporarily") || strings.Contains(lower, "connection refused") {
-		hintLines = append(hintLines, fmt.Sprintf("%s%s%s Network issue – retry shortly.", cli.ColorYellow, cli.IconInfo, cli.ColorReset))
+// interruptContext returns a context that is canceled on the first SIGINT/SIGTERM.
+// On interrupt the terminal is restored immediately; if the program has not exited
+// shortly afterwards (e.g., blocked reading a key) or a second signal arrives, it
+// exits with status 130.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+			return
+		}
+		cancel()
+		commit.RestoreTerminal()
+		fmt.Fprintf(os.Stderr, "%s\n%s%s Canceled.%s\n", cli.ColorReset, cli.ColorYellow, cli.IconInfo, cli.ColorReset)
+		select {
+		case <-sigs:
+		case <-time.After(2 * time.Second):
+		}
+		os.Exit(130)
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
 	}
+}
+
+func fatal(err error) {
+	// Interrupted by the user: the signal handler already restored the terminal and reported it.
+	if errors.Is(err, context.Canceled) {
+		commit.RestoreTerminal()
+		os.Exit(130)
+	}
+	banner := fmt.Sprintf("%s%s %sERROR%s", cli.ColorBold, cli.ColorRed, cli.IconError, cli.ColorReset)
+	hintLines := errorHints(err)
 	ts := time.Now().Format("15:04:05")
-	fmt.Fprintf(os.Stderr, "%s %s%s%s\n  %s%v%s\n", banner, cli.ColorDim, ts, cli.ColorReset, cli.ColorRed, msg, cli.ColorReset)
+	fmt.Fprintf(os.Stderr, "%s %s%s%s\n  %s%v%s\n", banner, cli.ColorDim, ts, cli.ColorReset, cli.ColorRed, err.Error(), cli.ColorReset)
 	if len(hintLines) > 0 {
 		for _, h := range hintLines {
 			fmt.Fprintln(os.Stderr, "  "+h)
@@ -232,18 +304,48 @@ func fatal(err error) {
 	os.Exit(1)
 }
 
-func isInvalidKeyErr(err error) bool {
-	if err == nil {
-		return false
+// errorHints returns categorized hints for err. Provider failures are matched on
+// their typed category; local failures (git state) on their message.
+func errorHints(err error) []string {
+	hint := func(text string) string {
+		return fmt.Sprintf("%s%s%s %s", cli.ColorYellow, cli.IconInfo, cli.ColorReset, text)
 	}
-	s := err.Error()
-	return strings.Contains(strings.ToLower(s), "invalid_api_key") || strings.Contains(strings.ToLower(s), "incorrect api key")
-}
-
-func isRateLimitErr(s string) bool {
-	if s == "" {
-		return false
+	var perr *provider.Error
+	if errors.As(err, &perr) {
+		keyEnv := provider.KeyEnv(perr.Provider)
+		switch perr.Category {
+		case provider.CategoryAuth:
+			if perr.Status == 0 {
+				return []string{hint(fmt.Sprintf("Export your key: %sexport %s=sk-***%s", cli.ColorGreen, keyEnv, cli.ColorReset))}
+			}
+			return []string{hint(fmt.Sprintf("Ensure your real %s is exported (%sexport %s=sk-...%s)", keyEnv, cli.ColorGreen, keyEnv, cli.ColorReset))}
+		case provider.CategoryRateLimit:
+			return []string{hint("Rate limits; wait or lower suggestions (AIC_SUGGESTIONS=3).")}
+		case provider.CategoryQuota:
+			return []string{hint(fmt.Sprintf("Quota or credits exhausted for %s; check your plan/billing or switch provider (AIC_PROVIDER).", perr.Provider))}
+		case provider.CategoryContextLength:
+			return []string{hint("Staged diff is too large for this model; stage fewer files or pick a model with a larger context (AIC_MODEL).")}
+		case provider.CategoryModelNotFound:
+			return []string{hint("Model not available for this key/provider; set a valid model via AIC_MODEL.")}
+		case provider.CategoryNetwork:
+			if perr.Provider == "ollama" {
+				return []string{hint(fmt.Sprintf("Cannot reach Ollama; start it with %sollama serve%s or set OLLAMA_HOST.", cli.ColorGreen, cli.ColorReset))}
+			}
+			return []string{hint("Network issue – retry shortly.")}
+		case provider.CategoryServer:
+			return []string{hint(fmt.Sprintf("%s is overloaded or failing server-side – retry shortly.", perr.Provider))}
+		}
+		return nil
+	}
+	if errors.Is(err, commit.ErrSecrets) {
+		return []string{hint(fmt.Sprintf("Unstage the affected files (%sgit restore --staged <file>%s) or list them in .aicignore.", cli.ColorGreen, cli.ColorReset))}
+	}
+	lower := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(lower, "no staged changes"):
+		return []string{hint(fmt.Sprintf("Stage changes first, e.g.: %sgit add -p%s", cli.ColorGreen, cli.ColorReset))}
+	case strings.Contains(lower, "not a git repository"):
+		return []string{hint(fmt.Sprintf("Run %sgit init%s or cd into a repo.", cli.ColorGreen, cli.ColorReset))}
 	}
-	s = strings.ToLower(s)
-	return strings.Contains(s, "rate limit") || strings.Contains(s, "too many requests")
+	return nil
 }
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/provider"
 )
 
 func TestHelpContainsNewFlags(t *testing.T) {
@@ -13,3 +20,138 @@ func TestHelpContainsNewFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorHintsByCategory(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{provider.MissingKeyError("claude", "CLAUDE_API_KEY"), "export CLAUDE_API_KEY=sk-***"},
+		{&provider.Error{Provider: "gemini", Status: 400, Category: provider.CategoryAuth}, "Ensure your real GEMINI_API_KEY"},
+		{&provider.Error{Provider: "openai", Status: 429, Category: provider.CategoryRateLimit}, "Rate limits"},
+		{&provider.Error{Provider: "openai", Status: 429, Category: provider.CategoryQuota}, "Quota or credits"},
+		{&provider.Error{Provider: "openai", Status: 400, Category: provider.CategoryContextLength}, "too large"},
+		{&provider.Error{Provider: "openai", Status: 404, Category: provider.CategoryModelNotFound}, "AIC_MODEL"},
+		{&provider.Error{Provider: "custom", Category: provider.CategoryNetwork, Err: errors.New("refused")}, "Network issue"},
+		{fmt.Errorf("generate: %w", &provider.Error{Provider: "claude", Status: 529, Category: provider.CategoryServer}), "claude is overloaded"},
+		{errors.New("no staged changes"), "git add -p"},
+	}
+	for _, c := range cases {
+		hints := strings.Join(errorHints(c.err), "\n")
+		if !strings.Contains(hints, c.want) {
+			t.Errorf("%v: hints %q missing %q", c.err, hints, c.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cases := []struct {
+		args  []string
+		cmd   string
+		flags map[string]string
+		rest  []string
+		err   string
+	}{
+		{args: nil, cmd: "aic", flags: map[string]string{}},
+		{args: []string{"-s", "focus on auth", "--body"}, cmd: "aic", flags: map[string]string{"system": "focus on auth", "body": "1"}},
+		{args: []string{"--system=--not-a-flag", "--body=false"}, cmd: "aic", flags: map[string]string{"system": "--not-a-flag", "body": "0"}},
+		{args: []string{"--hook", ".git/COMMIT_EDITMSG", "--model=llama3"}, cmd: "aic", flags: map[string]string{"hook": ".git/COMMIT_EDITMSG", "model": "llama3"}},
+		{args: []string{"analyse", "--limit", "50"}, cmd: "aic analyze", flags: map[string]string{"limit": "50"}},
+		{args: []string{"--provider", "ollama", "split", "--dry-run"}, cmd: "aic split", flags: map[string]string{"provider": "ollama", "dry-run": "1"}},
+		{args: []string{"cache", "clear"}, cmd: "aic cache clear", flags: map[string]string{}},
+		{args: []string{"help", "cache", "clear"}, cmd: "aic help", flags: map[string]string{}, rest: []string{"cache", "clear"}},
+		{args: []string{"--retry-max-time", "-1"}, cmd: "aic", flags: map[string]string{"retry-max-time": "-1"}},
+		{args: []string{"--nope"}, err: "unknown flag --nope"},
+		{args: []string{"-s", "--body"}, err: "flag -s needs a value"},
+		{args: []string{"-s"}, err: "flag -s needs a value"},
+		{args: []string{"--suggestions=many"}, err: `invalid value "many" for --suggestions`},
+		{args: []string{"--body=maybe"}, err: `invalid value "maybe" for --body`},
+		{args: []string{"-s", "x", "analyze"}, err: "flag -s is not supported by 'aic analyze'"},
+		{args: []string{"--limit", "5", "analyze"}, err: "flag --limit belongs to 'aic analyze'; put it after the command name"},
+		{args: []string{"--openai-api-key=sk-x"}, err: "unknown flag --openai-api-key"},
+		{args: []string{"split", "extra"}, err: `unexpected argument "extra" for 'aic split'`},
+		{args: []string{"cache", "purge"}, err: `unknown command "purge" for 'aic cache'`},
+	}
+	for _, c := range cases {
+		inv, err := parseArgs(commands(), c.args)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: err = %v, want %q", c.args, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.args, err)
+			continue
+		}
+		if inv.cmd.path() != c.cmd || !reflect.DeepEqual(inv.flags, c.flags) || !reflect.DeepEqual(inv.args, c.rest) {
+			t.Errorf("%q: got %s %v %q, want %s %v %q", c.args, inv.cmd.path(), inv.flags, inv.args, c.cmd, c.flags, c.rest)
+		}
+	}
+}
+
+func TestFlagsOverrideEnv(t *testing.T) {
+	t.Setenv("AIC_MODEL", "from-env")
+	t.Setenv("AIC_BODY", "1")
+	t.Setenv("AIC_PROVIDER", "")
+	inv, err := parseArgs(commands(), []string{"--model", "from-flag", "--body=false", "--provider=ollama"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.applyEnv()
+	for env, want := range map[string]string{"AIC_MODEL": "from-flag", "AIC_BODY": "0", "AIC_PROVIDER": "ollama"} {
+		if got := os.Getenv(env); got != want {
+			t.Errorf("%s = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestEveryCoreEnvHasAFlag(t *testing.T) {
+	h := buildHelp()
+	for _, v := range config.CoreVars() {
+		_, ok := commands().lookup(flagName(v.Name), false)
+		if config.Secret(v.Name) {
+			if ok || !strings.Contains(h, v.Name) {
+				t.Errorf("%s must be listed in the help but have no flag", v.Name)
+			}
+			continue
+		}
+		if !ok || !strings.Contains(h, "--"+flagName(v.Name)) {
+			t.Errorf("no flag for %s", v.Name)
+		}
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	inv, err := parseArgs(commands(), []string{"analyze", "--help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := helpFor(inv.cmd)
+	for _, want := range []string{"aic analyze [flags]", "--limit <N>", "--help"} {
+		if !strings.Contains(h, want) {
+			t.Errorf("analyze help missing %q:\n%s", want, h)
+		}
+	}
+}
+
+func TestConfigSources(t *testing.T) {
+	t.Setenv("AIC_MODEL", "gpt-4o")
+	inv := &invocation{flags: map[string]string{"provider": "ollama"}}
+	if got := envSource(inv, config.EnvAICProvider); got != "--provider" {
+		t.Fatalf("flag source = %q", got)
+	}
+	if got := envSource(inv, config.EnvAICModel); got != "AIC_MODEL" {
+		t.Fatalf("env source = %q", got)
+	}
+	t.Setenv("AIC_BODY", "")
+	if got := envSource(inv, config.EnvAICBody); got != "" {
+		t.Fatalf("unset source = %q", got)
+	}
+	if got := sources(true, true); got != ".aic.json + ~/.aic.json" {
+		t.Fatalf("sources = %q", got)
+	}
+	if got := maskKey("sk-0123456789abcdef"); got != "set (...cdef)" || strings.Contains(maskKey("short"), "short") {
+		t.Fatalf("maskKey leaks or misformats: %q", got)
+	}
+}
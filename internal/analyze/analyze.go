@@ -2,37 +2,96 @@ package analyze
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/lint"
 	"github.com/diesi/aic/internal/openai"
-	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
 )
 
 // Result summarizes AI-generated instructions and sample count.
 type Result struct {
 	Instructions string
 	SampleTotal  int
+	// Lint holds the commit rules inferred from the subjects; nil when the
+	// history does not follow Conventional Commits.
+	Lint *lint.Rules
 }
 
-var ccRe = regexp.MustCompile(`^([a-z]+)(\([^\)]+\))?:\s+(.+)$`)
+var ccRe = regexp.MustCompile(`^([a-z]+)(\([^\)]+\))?!?:\s+(.+)$`)
 
 // Analyze collects recent commit subjects and asks the configured AI provider to
 // synthesize clear, prescriptive commit-style instructions for this repository.
-// limit defines how many commits to inspect.
-func Analyze(limit int, cfg commit.Config, apiKey string) (Result, error) {
+// limit defines how many commits to inspect. The provider call is bound to ctx.
+func Analyze(ctx context.Context, limit int, cfg commit.Config, apiKey string) (Result, error) {
 	subjects, err := collectSubjects(limit)
 	if err != nil {
 		return Result{}, err
 	}
-	instr, err := generateInstructions(cfg, apiKey, subjects)
+	instr, err := generateInstructions(ctx, cfg, apiKey, subjects)
 	if err != nil {
 		return Result{}, err
 	}
-	return Result{Instructions: instr, SampleTotal: len(subjects)}, nil
+	return Result{Instructions: instr, SampleTotal: len(subjects), Lint: InferRules(subjects)}, nil
+}
+
+// InferRules derives lint rules from commit subjects when at least 80% of
+// them follow Conventional Commits, and returns nil otherwise. Types the repo
+// uses at least twice are allowed besides the defaults; a scope or an
+// upper-case description is required when 90% of the subjects have one, and
+// the header limit is raised when more than 5% exceed the default.
+func InferRules(subjects []string) *lint.Rules {
+	var conventional, scoped, upper, long int
+	counts := map[string]int{}
+	for _, s := range subjects {
+		m := ccRe.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		conventional++
+		counts[m[1]]++
+		if m[2] != "" {
+			scoped++
+		}
+		if first, _ := utf8.DecodeRuneInString(m[3]); unicode.IsUpper(first) {
+			upper++
+		}
+		if utf8.RuneCountInString(s) > lint.DefaultMaxLength {
+			long++
+		}
+	}
+	if conventional == 0 || conventional*10 < len(subjects)*8 {
+		return nil
+	}
+	rules := &lint.Rules{}
+	var extra []string
+	for typ, n := range counts {
+		if n >= 2 && !slices.Contains(lint.DefaultTypes, typ) {
+			extra = append(extra, typ)
+		}
+	}
+	if len(extra) > 0 {
+		slices.Sort(extra)
+		rules.Types = append(slices.Clone(lint.DefaultTypes), extra...)
+	}
+	rules.RequireScope = scoped*10 >= conventional*9
+	if upper*10 >= conventional*9 {
+		rules.Case = lint.CaseUpper
+	}
+	if long*20 > conventional {
+		rules.MaxLength = 100
+	}
+	return rules
 }
 
 // collectSubjects returns recent non-merge commit subjects (one per line, trimmed).
@@ -62,22 +121,15 @@ func collectSubjects(limit int) ([]string, error) {
 
 // generateInstructions prompts the AI model to output a single, concise
 // instruction string for commit style based on the given subjects.
-func generateInstructions(cfg commit.Config, apiKey string, subjects []string) (string, error) {
+func generateInstructions(ctx context.Context, cfg commit.Config, apiKey string, subjects []string) (string, error) {
 	if len(subjects) == 0 {
 		// With no commits, fall back to a generic instruction set
 		return "Use Conventional Commits (feat|fix|docs|refactor|chore|test|perf|build|ci|style). Imperative mood, subject <=72 chars, scope optional, no trailing period.", nil
 	}
 
-	var p provider.Provider
-	switch cfg.Provider {
-	case "claude":
-		p = provider.NewClaude(apiKey)
-	case "gemini":
-		p = provider.NewGemini(apiKey)
-	case "custom":
-		p = provider.NewCustom(apiKey)
-	default:
-		p = provider.NewOpenAI(apiKey)
+	p, err := cfg.NewProvider(apiKey)
+	if err != nil {
+		return "", err
 	}
 
     // Prepare the prompt. Ask for a single, compact instruction set for .aic.json.
@@ -86,17 +138,24 @@ func generateInstructions(cfg commit.Config, apiKey string, subjects []string) (
         "Also infer the dominant natural language of the subjects (e.g., English, Spanish, German) and include a brief directive to write messages in that language (e.g., 'Write messages in English.'). " +
         "Output only the final instruction text suitable for a config file; do not include examples, lists, or the analyzed messages."
 	// Join subjects in a compact block. We only pass subjects, not bodies.
+	// Subjects are newest first; drop the oldest ones that do not fit the model.
+	window := commit.ContextWindow(cfg, cfg.Provider, cfg.Model)
+	budget := tokens.Budget{Window: window, Output: tokens.InstructionsOutput}
+	subjects = fitSubjects(subjects, budget.Input()-tokens.Estimate(system)-32)
 	user := "Recent commit subjects (one per line):\n" + strings.Join(subjects, "\n")
 
 	temp := float32(0.3)
 	req := openai.ChatCompletionRequest{
 		Model:       cfg.Model,
 		Messages:    []openai.Message{{Role: "system", Content: system}, {Role: "user", Content: user}},
-		MaxTokens:   280,
+		MaxTokens:   budget.Output,
 		N:           1,
 		Temperature: &temp,
 	}
-	resp, err := p.Chat(req)
+	if config.Bool(config.EnvAICDebug) {
+		fmt.Fprintf(os.Stderr, "[aic][debug] analyze tokens: input~%d (%d subjects), output<=%d, window %d\n", tokens.Estimate(system)+tokens.Estimate(user), len(subjects), req.MaxTokens, window)
+	}
+	resp, err := p.Chat(ctx, req)
 	if err != nil {
 		return "", err
 	}
@@ -112,3 +171,16 @@ func generateInstructions(cfg commit.Config, apiKey string, subjects []string) (
 	}
 	return out, nil
 }
+
+// fitSubjects returns the leading subjects whose lines fit in limit tokens,
+// keeping at least one.
+func fitSubjects(subjects []string, limit int) []string {
+	used := 0
+	for i, s := range subjects {
+		used += tokens.Estimate(s + "\n")
+		if used > limit && i > 0 {
+			return subjects[:i]
+		}
+	}
+	return subjects
+}
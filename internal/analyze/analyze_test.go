@@ -0,0 +1,32 @@
+package analyze
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/lint"
+)
+
+func TestInferRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		subjects []string
+		want     *lint.Rules
+	}{
+		{"not conventional", []string{"Add login", "Fix typo", "feat: add x"}, nil},
+		{"defaults", []string{"feat(api): add x", "fix: handle nil", "docs: update readme"}, &lint.Rules{}},
+		{"scoped", []string{"feat(api): add x", "fix(db)!: drop column", "docs(readme): update"}, &lint.Rules{RequireScope: true}},
+		{"upper case", []string{"feat: Add x", "fix: Handle nil"}, &lint.Rules{Case: lint.CaseUpper}},
+		{"custom types", []string{"deps: bump x", "deps: bump y", "once: odd", "feat: add x"},
+			&lint.Rules{Types: append(append([]string{}, lint.DefaultTypes...), "deps")}},
+		{"long", []string{"feat: " + strings.Repeat("x", 80), "fix: y"}, &lint.Rules{MaxLength: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InferRules(tt.subjects); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("InferRules = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
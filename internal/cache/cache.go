@@ -0,0 +1,213 @@
+// Package cache stores provider responses on disk so repeated runs on the same
+// input (e.g. after cancelling, or from the prepare-commit-msg hook) do not pay
+// for the same request twice.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/diesi/aic/internal/config"
+)
+
+const (
+	defaultTTL      = 24 * time.Hour
+	defaultMaxBytes = 20 << 20
+)
+
+var disabled atomic.Bool
+
+// Disable turns caching off for the rest of the process (--no-cache).
+func Disable() { disabled.Store(true) }
+
+// Cache is a directory of JSON entries with a time-to-live and a total size
+// limit. Expired entries are dropped on read and on write; when the size limit
+// is exceeded the least recently used entries are evicted first.
+type Cache struct {
+	Dir      string
+	TTL      time.Duration
+	MaxBytes int64
+
+	now func() time.Time
+}
+
+// entry is the on-disk envelope of a cached value.
+type entry struct {
+	Created time.Time       `json:"created"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// Root returns the directory holding all aic caches (under the user cache dir).
+func Root() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aic"), nil
+}
+
+// Open returns the named cache (e.g. "responses") configured from the
+// environment. It returns nil when caching is disabled (--no-cache,
+// AIC_NO_CACHE=1) or no cache directory is available; a nil *Cache is valid
+// and never hits.
+func Open(name string) *Cache {
+	if disabled.Load() || config.Bool(config.EnvAICNoCache) {
+		return nil
+	}
+	root, err := Root()
+	if err != nil {
+		return nil
+	}
+	ttl := defaultTTL
+	if h := config.IntInRange(config.EnvAICCacheTTL, 0, 1, 24*365); h > 0 {
+		ttl = time.Duration(h) * time.Hour
+	}
+	maxBytes := int64(defaultMaxBytes)
+	if mb := config.IntInRange(config.EnvAICCacheMaxMB, 0, 1, 10240); mb > 0 {
+		maxBytes = int64(mb) << 20
+	}
+	return &Cache{Dir: filepath.Join(root, name), TTL: ttl, MaxBytes: maxBytes}
+}
+
+// Clear removes every aic cache.
+func Clear() error {
+	root, err := Root()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(root)
+}
+
+// Key hashes parts into a cache key. Parts are length-prefixed so that
+// ("ab", "c") and ("a", "bc") produce different keys.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(strconv.Itoa(len(p))))
+		h.Write([]byte{':'})
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Cache) path(key string) string { return filepath.Join(c.Dir, key+".json") }
+
+// Get decodes the entry stored under key into v and reports whether a fresh
+// entry was found.
+func (c *Cache) Get(key string, v any) bool {
+	if c == nil {
+		return false
+	}
+	p := c.path(key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || c.clock().Sub(e.Created) > c.TTL {
+		_ = os.Remove(p)
+		return false
+	}
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		return false
+	}
+	// Record the access for least-recently-used eviction.
+	now := c.clock()
+	_ = os.Chtimes(p, now, now)
+	return true
+}
+
+// Put stores v under key and evicts entries beyond the TTL and size limit.
+func (c *Cache) Put(key string, v any) error {
+	if c == nil {
+		return nil
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Created: c.clock(), Value: value})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if err := errors.Join(werr, cerr); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	now := c.clock()
+	_ = os.Chtimes(c.path(key), now, now)
+	c.evict()
+	return nil
+}
+
+// evict removes expired entries, then the least recently used ones until the
+// cache fits in MaxBytes.
+func (c *Cache) evict() {
+	ents, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var files []file
+	var total int64
+	now := c.clock()
+	for _, de := range ents {
+		info, err := de.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		p := filepath.Join(c.Dir, de.Name())
+		// Hits refresh the modification time, so an entry untouched for a
+		// whole TTL is necessarily expired.
+		if now.Sub(info.ModTime()) > c.TTL {
+			_ = os.Remove(p)
+			continue
+		}
+		files = append(files, file{path: p, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+	if total <= c.MaxBytes {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+	for _, f := range files {
+		if total <= c.MaxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
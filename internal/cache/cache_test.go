@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, maxBytes int64) (*Cache, *time.Time) {
+	t.Helper()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := &Cache{Dir: t.TempDir(), TTL: time.Hour, MaxBytes: maxBytes}
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestGetPutRoundTrip(t *testing.T) {
+	c, _ := newTestCache(t, 1<<20)
+	type value struct{ Choices []string }
+	if err := c.Put("k", value{Choices: []string{"feat: a"}}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var got value
+	if !c.Get("k", &got) || len(got.Choices) != 1 || got.Choices[0] != "feat: a" {
+		t.Fatalf("unexpected cache result: %+v", got)
+	}
+	if c.Get("missing", &got) {
+		t.Fatal("expected miss for unknown key")
+	}
+}
+
+func TestGetExpiresAfterTTL(t *testing.T) {
+	c, now := newTestCache(t, 1<<20)
+	if err := c.Put("k", "v"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	*now = now.Add(2 * time.Hour)
+	var got string
+	if c.Get("k", &got) {
+		t.Fatal("expected expired entry to miss")
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir, "k.json")); !os.IsNotExist(err) {
+		t.Fatalf("expired entry should be removed, stat err=%v", err)
+	}
+}
+
+func TestPutEvictsLeastRecentlyUsed(t *testing.T) {
+	c, now := newTestCache(t, 1<<20)
+	big := strings.Repeat("x", 1000)
+	for _, k := range []string{"a", "b"} {
+		if err := c.Put(k, big); err != nil {
+			t.Fatalf("put %s: %v", k, err)
+		}
+		*now = now.Add(time.Minute)
+	}
+	// Room for two entries; touching "a" makes "b" the eviction candidate.
+	c.MaxBytes = 2500
+	var got string
+	if !c.Get("a", &got) {
+		t.Fatal("expected hit for a")
+	}
+	*now = now.Add(time.Minute)
+	if err := c.Put("c", big); err != nil {
+		t.Fatalf("put c: %v", err)
+	}
+	if !c.Get("a", &got) || !c.Get("c", &got) {
+		t.Fatal("recently used entries should survive")
+	}
+	if c.Get("b", &got) {
+		t.Fatal("least recently used entry should be evicted")
+	}
+}
+
+func TestNilCacheAndDisable(t *testing.T) {
+	var c *Cache
+	if c.Get("k", new(string)) || c.Put("k", "v") != nil {
+		t.Fatal("nil cache must be a no-op")
+	}
+	t.Setenv("AIC_NO_CACHE", "1")
+	if Open("responses") != nil {
+		t.Fatal("AIC_NO_CACHE should disable the cache")
+	}
+}
+
+func TestKeySeparatesParts(t *testing.T) {
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Fatal("keys must not collide across part boundaries")
+	}
+	if Key("openai", "m", "diff") != Key("openai", "m", "diff") {
+		t.Fatal("keys must be deterministic")
+	}
+}
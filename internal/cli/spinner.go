@@ -6,6 +6,9 @@ import (
 	"time"
 )
 
+// SpinnerFrames are the animation frames used by Spinner and other progress indicators.
+var SpinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
 // Spinner shows an animated spinner until the returned finalize func is called.
 // Call the returned function with success=true on success, or false on failure.
 // Example:
@@ -14,7 +17,7 @@ import (
 //	result, err := work()
 //	stop(err == nil)
 func Spinner(msg string) func(success bool) {
-	frames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	frames := SpinnerFrames
 	done := make(chan struct{})
 	go func() {
 		i := 0
@@ -0,0 +1,47 @@
+package commit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+// maxDiffTokens caps the raw diff sent with a suggestions request so large
+// context windows do not turn into large bills; bigger diffs are summarized.
+const maxDiffTokens = 8000
+
+// ContextWindow returns the context window used to budget prompts for model
+// on provider name. For Ollama, OLLAMA_NUM_CTX is the window actually loaded.
+func ContextWindow(cfg Config, name, model string) int {
+	if name == "ollama" {
+		if n := config.IntInRange(config.EnvOllamaNumCtx, 0, 1, 1<<20); n > 0 {
+			return n
+		}
+	}
+	return cfg.ContextWindows.ContextWindow(model)
+}
+
+// estimateInput returns the estimated prompt tokens of req.
+func estimateInput(req openai.ChatCompletionRequest) int {
+	n := 0
+	for _, m := range req.Messages {
+		// A few tokens of framing per message.
+		n += tokens.Estimate(m.Content) + 4
+	}
+	return n
+}
+
+// debugTokens prints the estimated token usage of req under AIC_DEBUG.
+func debugTokens(what string, req openai.ChatCompletionRequest, window int) {
+	if !config.Bool(config.EnvAICDebug) {
+		return
+	}
+	choices := req.N
+	if choices < 1 {
+		choices = 1
+	}
+	fmt.Fprintf(os.Stderr, "[aic][debug] %s tokens: input~%d, output<=%d x%d, window %d\n", what, estimateInput(req), req.MaxTokens, choices, window)
+}
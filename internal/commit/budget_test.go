@@ -0,0 +1,91 @@
+package commit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+func TestLoadConfigContextWindowOverrides(t *testing.T) {
+	td := t.TempDir()
+	t.Setenv("AIC_DISABLE_REPO_CONFIG", "1")
+	t.Setenv("HOME", td)
+	contents := `{"context_windows": {"llama3": 8192, "*": 4096}}`
+	if err := os.WriteFile(filepath.Join(td, ".aic.json"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if got := ContextWindow(cfg, "custom", "llama3:latest"); got != 8192 {
+		t.Fatalf("llama3 window = %d, want 8192", got)
+	}
+	if got := ContextWindow(cfg, "custom", ""); got != 4096 {
+		t.Fatalf("default window = %d, want 4096", got)
+	}
+	t.Setenv("OLLAMA_NUM_CTX", "16384")
+	if got := ContextWindow(cfg, "ollama", "llama3:latest"); got != 16384 {
+		t.Fatalf("ollama window = %d, want OLLAMA_NUM_CTX", got)
+	}
+}
+
+// recordingProvider records suggestion requests and answers summaries with a
+// fixed text.
+type recordingProvider struct {
+	mu   sync.Mutex
+	reqs []openai.ChatCompletionRequest
+}
+
+func (p *recordingProvider) Chat(_ context.Context, req openai.ChatCompletionRequest) (*provider.CompletionResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reqs = append(p.reqs, req)
+	if req.Messages[0].Content == summarySystemPrompt || req.Messages[0].Content == reduceSystemPrompt {
+		return &provider.CompletionResponse{Choices: []string{"- files: many changes"}}, nil
+	}
+	return &provider.CompletionResponse{Choices: []string{"feat: add things"}}, nil
+}
+
+func (p *recordingProvider) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, _ provider.DeltaFunc) (*provider.CompletionResponse, error) {
+	return p.Chat(ctx, req)
+}
+
+func TestSuggestWithBudgetsPromptFromContextWindow(t *testing.T) {
+	t.Setenv("AIC_NO_CACHE", "1")
+	t.Setenv("OLLAMA_NUM_CTX", "")
+	cfg := Config{Suggestions: 8, ContextWindows: tokens.Overrides{"tiny": 4096}}
+	h := providerHop{name: "custom", model: "tiny"}
+
+	small := syntheticDiff(1, 5)
+	p := &recordingProvider{}
+	if _, err := suggestWith(context.Background(), p, h, cfg, small, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.reqs) != 1 || p.reqs[0].Messages[1].Content != small {
+		t.Fatalf("expected the small diff to be sent as is in one request")
+	}
+	if got, want := p.reqs[0].MaxTokens, tokens.SuggestionOutput(8); got != want {
+		t.Fatalf("MaxTokens = %d, want %d", got, want)
+	}
+
+	large := syntheticDiff(50, 20)
+	p = &recordingProvider{}
+	if _, err := suggestWith(context.Background(), p, h, cfg, large, nil); err != nil {
+		t.Fatal(err)
+	}
+	last := p.reqs[len(p.reqs)-1]
+	if !strings.Contains(last.Messages[1].Content, "DIFF SUMMARY") {
+		t.Fatalf("expected a summarized prompt for a diff over budget")
+	}
+	if in := estimateInput(last); in+last.MaxTokens > 4096 {
+		t.Fatalf("prompt of ~%d tokens plus %d output exceeds the 4096 window", in, last.MaxTokens)
+	}
+}
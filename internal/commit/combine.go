@@ -1,21 +1,27 @@
 package commit
 
 import (
+    "context"
     "errors"
     "fmt"
     "os"
     "strings"
 
-    "github.com/diesi/aic/internal/cli"
     "github.com/diesi/aic/internal/config"
     "github.com/diesi/aic/internal/openai"
-    "github.com/diesi/aic/internal/provider"
+    "github.com/diesi/aic/internal/tokens"
 )
 
 // GenerateCombinedSuggestions asks the AI to combine multiple commit messages
 // into a fresh set of consolidated suggestions. It returns up to cfg.Suggestions
 // items, formatted one message per choice with no numbering or bullets.
-func GenerateCombinedSuggestions(cfg Config, apiKey string, selected []string) ([]string, error) {
+func GenerateCombinedSuggestions(ctx context.Context, cfg Config, apiKey string, selected []string) ([]string, error) {
+	return combineWith(ctx, cfg, primaryHop(cfg, apiKey), selected)
+}
+
+// combineWith implements GenerateCombinedSuggestions with the provider of
+// hop h, e.g. the fallback that produced the selected messages.
+func combineWith(ctx context.Context, cfg Config, h providerHop, selected []string) ([]string, error) {
 	if len(selected) < 2 {
 		return nil, errors.New("need at least two messages to combine")
 	}
@@ -33,28 +39,22 @@ func GenerateCombinedSuggestions(cfg Config, apiKey string, selected []string) (
 		}
 		return out, nil
 	}
-	if apiKey == "" {
-		switch cfg.Provider {
-		case "claude":
-			return nil, errors.New("missing CLAUDE_API_KEY")
-		case "gemini":
-			return nil, errors.New("missing GEMINI_API_KEY")
-		default:
-			return nil, errors.New("missing OPENAI_API_KEY")
-		}
-	}
-	var p provider.Provider
-	switch cfg.Provider {
-	case "claude":
-		p = provider.NewClaude(apiKey)
-	case "gemini":
-		p = provider.NewGemini(apiKey)
-	default:
-		p = provider.NewOpenAI(apiKey)
+	p, err := h.provider()
+	if err != nil {
+		return nil, err
 	}
     systemMsg := "You are a helpful assistant that synthesizes multiple draft commit messages into improved conventional commit suggestions. " +
-        "Given several commit messages that may overlap, produce distinct, concise, high-quality alternatives (max 30 tokens each). " +
+        "Given several commit messages that may overlap, produce distinct, concise, high-quality alternatives. " +
+        "Rules: one line per message, " + cfg.HeaderRules() + ". " +
         "No line breaks; return ONLY the commit messages, one per choice, with no numbering or bullets."
+	// Messages with a body are combined in body mode even if it was enabled
+	// by a flag this config did not see.
+	cfg.Body = cfg.Body || hasBody(selected)
+	if cfg.Body {
+		systemMsg = "You are a helpful assistant that synthesizes multiple draft commit messages (subject plus body bullets) into improved conventional commit suggestions. " +
+			"Given several messages that may overlap, produce distinct, high-quality alternatives: a subject (" + cfg.HeaderRules() + ") and 1-5 short body bullets covering the combined changes. " +
+			"Output: one JSON object per line, exactly {\"subject\": \"...\", \"body\": [\"...\"]}, and nothing else."
+	}
     if cfg.SystemAddition != "" {
         systemMsg += " Additional user instructions: " + cfg.SystemAddition
     }
@@ -62,47 +62,33 @@ func GenerateCombinedSuggestions(cfg Config, apiKey string, selected []string) (
         fmt.Fprintln(os.Stderr, "[aic][debug] system prompt for combine:")
         fmt.Fprintln(os.Stderr, systemMsg)
     }
-	userContent := "Combine and refine these commit messages into consolidated alternatives:\n\n" + strings.Join(selected, "\n")
+	sep := "\n"
+	if cfg.Body {
+		sep = "\n\n---\n\n"
+	}
+	userContent := "Combine and refine these commit messages into consolidated alternatives:\n\n" + strings.Join(selected, sep)
 
 	temp := float32(0.4)
-	resp, err := p.Chat(openai.ChatCompletionRequest{
-		Model:       cfg.Model,
+	maxTokens := tokens.SuggestionOutput(cfg.Suggestions)
+	if cfg.Body {
+		maxTokens = tokens.BodySuggestionOutput(cfg.Suggestions)
+	}
+	req := openai.ChatCompletionRequest{
+		Model:       h.model,
 		Messages:    []openai.Message{{Role: "system", Content: systemMsg}, {Role: "user", Content: userContent}},
-		MaxTokens:   256,
+		MaxTokens:   maxTokens,
 		N:           cfg.Suggestions,
 		Temperature: &temp,
-	})
+	}
+	debugTokens("combine", req, ContextWindow(cfg, h.name, h.model))
+	resp, err := p.Chat(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	if len(resp.Choices) == 0 {
 		return nil, errors.New("no choices returned")
 	}
-	suggestions := make([]string, 0, len(resp.Choices))
-	for _, msg := range resp.Choices {
-		msg = strings.TrimSpace(msg)
-		if msg == "" {
-			continue
-		}
-		lines := []string{msg}
-		if strings.Contains(msg, "\n") {
-			lines = []string{}
-			for _, line := range strings.Split(msg, "\n") {
-				line = strings.TrimSpace(line)
-				if line == "" {
-					continue
-				}
-				lines = append(lines, line)
-			}
-		}
-		for _, ln := range lines {
-			ln = cli.StripLeadingListMarker(ln)
-			if ln == "" {
-				continue
-			}
-			suggestions = append(suggestions, ln)
-		}
-	}
+	suggestions := cfg.parseChoices(resp.Choices)
 	if len(suggestions) == 0 {
 		errMsg := "empty suggestions after combining"
 		if config.Bool(config.EnvAICDebug) && resp != nil && resp.Raw != "" {
@@ -0,0 +1,137 @@
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/lint"
+	"github.com/diesi/aic/internal/provider"
+)
+
+// fakeServer answers chat requests in the wire format of provider name and
+// records the credential each request carried.
+func fakeServer(t *testing.T, name, content string, credential *string) *httptest.Server {
+	t.Helper()
+	quoted := fmt.Sprintf("%q", content)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch name {
+		case "claude":
+			*credential = r.Header.Get("x-api-key")
+			fmt.Fprintf(w, `{"content":[{"type":"text","text":%s}]}`, quoted)
+		case "gemini":
+			*credential = r.URL.Query().Get("key")
+			fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[{"text":%s}]}}]}`, quoted)
+		case "ollama":
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%s},"done":true}`, quoted)
+		case "azure":
+			*credential = r.Header.Get("api-key")
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s}}]}`, quoted)
+		default:
+			*credential = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%s}}]}`, quoted)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// pointAt routes provider name to srv: via its environment variables where
+// it has them, otherwise by registering a constructor with srv as base URL.
+func pointAt(t *testing.T, name string, srv *httptest.Server) {
+	t.Helper()
+	info, _ := provider.Lookup(name)
+	switch name {
+	case "openai":
+		info.New = func(k string) provider.Provider {
+			p := provider.NewOpenAI(k)
+			p.Client.BaseURL = srv.URL
+			return p
+		}
+	case "claude":
+		info.New = func(k string) provider.Provider {
+			p := provider.NewClaude(k)
+			p.BaseURL = srv.URL
+			return p
+		}
+	case "gemini":
+		info.New = func(k string) provider.Provider {
+			p := provider.NewGemini(k)
+			p.BaseURL = srv.URL
+			return p
+		}
+	case "azure":
+		t.Setenv("AZURE_OPENAI_ENDPOINT", srv.URL)
+	case "custom":
+		t.Setenv("CUSTOM_BASE_URL", srv.URL)
+	case "ollama":
+		t.Setenv("OLLAMA_HOST", srv.URL)
+	}
+	orig := provider.Register(info)
+	t.Cleanup(func() { provider.Register(orig) })
+}
+
+func TestGenerateCombinedSuggestionsEveryProvider(t *testing.T) {
+	for _, name := range provider.Names() {
+		t.Run(name, func(t *testing.T) {
+			var credential string
+			srv := fakeServer(t, name, "feat: combined one\nfix: combined two", &credential)
+			pointAt(t, name, srv)
+			keyEnv := provider.KeyEnv(name)
+			if keyEnv != "" {
+				t.Setenv(keyEnv, "key-from-env")
+			}
+
+			// The selector passes the key it resolved at startup; an empty key
+			// must be resolved from the environment just the same.
+			cfg := Config{Provider: name, Model: "test-model", Suggestions: 2}
+			got, err := GenerateCombinedSuggestions(context.Background(), cfg, "", []string{"feat: a", "feat: b"})
+			if err != nil {
+				t.Fatalf("combine via %s: %v", name, err)
+			}
+			if strings.Join(got, "|") != "feat: combined one|fix: combined two" {
+				t.Fatalf("unexpected suggestions: %q", got)
+			}
+			if keyEnv != "" && credential != "key-from-env" {
+				t.Fatalf("request carried credential %q, want the %s value", credential, keyEnv)
+			}
+		})
+	}
+}
+
+func TestGenerateCombinedSuggestionsMissingKey(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	_, err := GenerateCombinedSuggestions(context.Background(), Config{Provider: "gemini", Suggestions: 1}, "", []string{"a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "GEMINI_API_KEY") {
+		t.Fatalf("expected a missing GEMINI_API_KEY error, got %v", err)
+	}
+}
+
+func TestGenerateCombinedSuggestionsFollowsRules(t *testing.T) {
+	var system string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		system = body.Messages[0]["content"]
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"ops: merge deploy steps\nfeat: not allowed"},"done":true}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	for _, body := range []bool{false, true} {
+		cfg := Config{Provider: "ollama", Model: "llama3", Suggestions: 2, Body: body, Lint: lint.Rules{Types: []string{"ops"}, MaxLength: 60}}
+		got, _ := GenerateCombinedSuggestions(context.Background(), cfg, "", []string{"ops: a", "ops: b"})
+		if !strings.Contains(system, "(ops)") || !strings.Contains(system, "<=60 chars") {
+			t.Fatalf("combine prompt (body %v) ignores the configured rules:\n%s", body, system)
+		}
+		if !body && strings.Join(got, "|") != "ops: merge deploy steps" {
+			t.Fatalf("combined suggestions should pass the rules: %q", got)
+		}
+	}
+}
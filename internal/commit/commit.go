@@ -11,18 +11,31 @@ import (
 
     "github.com/diesi/aic/internal/cli"
     "github.com/diesi/aic/internal/config"
+    "github.com/diesi/aic/internal/git"
 )
 
 // OfferCommit asks to commit or copy to clipboard.
 func OfferCommit(msg string) error {
-    fmt.Printf("\n%sSelected commit message:%s\n  %s%s%s\n", cli.ColorBold, cli.ColorReset, cli.ColorGreen, msg, cli.ColorReset)
+    return offer(msg, git.Commit)
+}
+
+// OfferAmend is OfferCommit for amending the last commit.
+func OfferAmend(msg string) error {
+    return offer(msg, git.Amend)
+}
+
+// offer shows msg and records it with record (git.Commit or git.Amend)
+// once confirmed.
+func offer(msg string, record func(string) error) error {
+    subject, body := splitMessage(msg)
+    fmt.Printf("\n%sSelected commit message:%s\n  %s%s%s\n", cli.ColorBold, cli.ColorReset, cli.ColorGreen, subject, cli.ColorReset)
+    if body != "" {
+        fmt.Printf("\n%s%s%s\n", cli.ColorDim, indentLines(body, "  "), cli.ColorReset)
+    }
     if config.Bool(config.EnvAICNonInteractive) {
         // In CI/test mode, don't attempt to commit unless explicitly allowed
         if config.Bool(config.EnvAICAutoCommit) {
-            cmd := exec.Command("git", "commit", "-m", msg)
-            cmd.Stdout = os.Stdout
-            cmd.Stderr = os.Stderr
-            if err := cmd.Run(); err != nil {
+            if err := record(msg); err != nil {
                 return err
             }
             // Non-interactive mode: do not prompt for push
@@ -31,17 +44,22 @@ func OfferCommit(msg string) error {
         fmt.Printf("Non-interactive mode: skipping commit (set AIC_AUTO_COMMIT=1 to enable).\n")
         return nil
     }
-    fmt.Printf("\n%s%s Commit with this message now?%s %s[Y|n]%s %s[default: Y]%s  %s(Alternative: copy to clipboard)%s: %s", 
+    fmt.Printf("\n%s%s Commit with this message now?%s %s[Y|n|e]%s %s[default: Y, e: edit first]%s  %s(Alternative: copy to clipboard)%s: %s", 
         cli.ColorBold, cli.IconPrompt, cli.ColorReset, 
         cli.ColorYellow, cli.ColorReset, cli.ColorDim, cli.ColorReset,
         cli.ColorGray, cli.ColorReset, cli.ColorCyan)
     var commitChoice string
     fmt.Scanln(&commitChoice)
+    if strings.ToLower(commitChoice) == "e" {
+        // Edit in $EDITOR, then commit the edited message
+        edited, err := EditMessage(msg)
+        if err != nil {
+            return err
+        }
+        msg, commitChoice = edited, "y"
+    }
     if strings.ToLower(commitChoice) == "y" || commitChoice == "" {
-        cmd := exec.Command("git", "commit", "-m", msg)
-        cmd.Stdout = os.Stdout
-        cmd.Stderr = os.Stderr
-        if err := cmd.Run(); err != nil {
+        if err := record(msg); err != nil {
             return err
         }
         // After committing, offer to push to the current branch
@@ -114,6 +132,11 @@ func OfferCommit(msg string) error {
     return nil
 }
 
+// indentLines prefixes every line of s with indent.
+func indentLines(s, indent string) string {
+    return indent + strings.ReplaceAll(s, "\n", "\n"+indent)
+}
+
 // pushCurrentBranch tries to push the current branch to its upstream if configured,
 // otherwise sets upstream to a likely remote (origin or first remote).
 func pushCurrentBranch() error {
@@ -6,35 +6,80 @@ import (
     "strings"
 
     "github.com/diesi/aic/internal/config"
+    "github.com/diesi/aic/internal/git"
+    "github.com/diesi/aic/internal/lint"
+    "github.com/diesi/aic/internal/provider"
+    "github.com/diesi/aic/internal/redact"
+    "github.com/diesi/aic/internal/tokens"
 )
 
-const (
-	defaultOpenAIModel = "gpt-4o-mini"
-	defaultClaudeModel = "claude-3-sonnet-20240229"
-	defaultGeminiModel = "gemini-1.5-flash"
-	defaultSuggestions = 5
-)
-
-func defaultModelFor(providerName string) string {
-	switch providerName {
-	case "claude":
-		return defaultClaudeModel
-	case "gemini":
-		return defaultGeminiModel
-	case "custom":
-		// For custom provider, default to OpenAI-compatible model name; users can override via AIC_MODEL.
-		return defaultOpenAIModel
-	default:
-		return defaultOpenAIModel
-	}
-}
+const defaultSuggestions = 5
 
 // Config holds runtime parameters loaded from env.
 type Config struct {
-	Provider       string
-	Model          string
+	Provider string
+	Model    string
+	// Fallbacks are tried in order, each with its default model, when Provider fails.
+	Fallbacks      []string
 	Suggestions    int
 	SystemAddition string
+	// ContextWindows overrides the built-in context window table per model.
+	ContextWindows tokens.Overrides
+	// Exclude and Include are gitignore-style globs of staged files to reduce
+	// to a stat line, or to always send in full (see DiffFilter).
+	Exclude []string
+	Include []string
+	// Redactor removes likely secrets from diffs; nil applies the built-in
+	// detectors only.
+	Redactor *redact.Redactor
+	// Body asks for a subject plus body bullets instead of single lines.
+	Body bool
+	// Lint holds the rules suggestions must pass; the zero value applies
+	// the defaults.
+	Lint lint.Rules
+	// Situation describes a merge, squash or amend the message is for; see
+	// DetectSituation.
+	Situation Situation
+}
+
+// APIKey returns the API key of c.Provider from the environment ("" for
+// providers that need none).
+func (c Config) APIKey() string { return provider.APIKey(c.Provider) }
+
+// NewProvider returns the implementation of c.Provider from the provider
+// registry. An empty apiKey is resolved from the environment; a missing key
+// is an error unless the provider is a local server.
+func (c Config) NewProvider(apiKey string) (provider.Provider, error) {
+	return provider.New(c.Provider, apiKey)
+}
+
+// DiffFilter returns the filter deciding which staged files are sent to the
+// model in full: git.DefaultExcludes, the repo .aicignore (unless repo config
+// is disabled) and the exclude/include globs.
+func (c Config) DiffFilter() *git.Filter {
+	ignore := ""
+	if !config.Bool(config.EnvAICDisableRepoConfig) {
+		ignore = git.ReadIgnoreFile()
+	}
+	return git.NewFilter(ignore, c.Exclude, c.Include)
+}
+
+// LoadLintRules returns the commit rules of .aic.json without the rest of
+// the configuration, for checking messages written by hand.
+func LoadLintRules() lint.Rules {
+	return lintRules(config.LoadRepoConfig(), config.LoadUserConfig())
+}
+
+// lintRules picks the "lint" object of the repo .aic.json, or else of
+// ~/.aic.json: the rules describe the repo's convention, so the repo wins.
+func lintRules(rc, uc config.UserConfig) lint.Rules {
+	if rc.Lint != nil {
+		return *rc.Lint
+	}
+	if uc.Lint != nil {
+		return *uc.Lint
+	}
+	return lint.Rules{}
 }
 
 func LoadConfig(systemAddition string) (Config, error) {
@@ -63,7 +108,23 @@ func LoadConfig(systemAddition string) (Config, error) {
         fmt.Fprintf(os.Stderr, "[aic][debug] home ~/.aic.json instructions: %q\n", uc.Instructions)
         fmt.Fprintf(os.Stderr, "[aic][debug] merged instructions: %q\n", systemAddition)
     }
-	providerName := strings.ToLower(config.Get(config.EnvAICProvider))
+	// Provider chain precedence: AIC_PROVIDER, then ~/.aic.json, then repo .aic.json.
+	chain := config.ParseProviderList(config.Get(config.EnvAICProvider))
+	if len(chain) == 0 {
+		chain = uc.Providers
+	}
+	if len(chain) == 0 {
+		chain = rc.Providers
+	}
+	for _, name := range chain {
+		if _, ok := provider.Lookup(name); !ok {
+			return Config{}, provider.UnknownError(name)
+		}
+	}
+	providerName := ""
+	if len(chain) > 0 {
+		providerName = chain[0]
+	}
 	if providerName == "" {
 		// Auto-detect provider from available API keys when AIC_PROVIDER is unset.
 		// Priority when multiple are present: OpenAI > Claude > Gemini.
@@ -82,7 +143,32 @@ func LoadConfig(systemAddition string) (Config, error) {
 			providerName = "openai"
 		}
 	}
-	cfg := Config{Provider: providerName, Model: defaultModelFor(providerName), Suggestions: defaultSuggestions, SystemAddition: systemAddition}
+	cfg := Config{Provider: providerName, Model: provider.DefaultModel(providerName), Suggestions: defaultSuggestions, SystemAddition: systemAddition}
+	if len(chain) > 1 {
+		cfg.Fallbacks = chain[1:]
+	}
+	// Context window overrides: ~/.aic.json entries win over repo .aic.json.
+	for _, m := range []map[string]int{rc.ContextWindows, uc.ContextWindows} {
+		for model, window := range m {
+			if cfg.ContextWindows == nil {
+				cfg.ContextWindows = tokens.Overrides{}
+			}
+			cfg.ContextWindows[model] = window
+		}
+	}
+	cfg.Exclude = append(append([]string{}, rc.Exclude...), uc.Exclude...)
+	cfg.Include = append(append([]string{}, rc.Include...), uc.Include...)
+	custom, err := redact.Compile(append(append([]string{}, rc.Redact...), uc.Redact...))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Redactor = redact.New(custom)
+	// Body mode: AIC_BODY (including AIC_BODY=0) overrides either .aic.json.
+	cfg.Body = rc.Body || uc.Body
+	if strings.TrimSpace(config.Get(config.EnvAICBody)) != "" {
+		cfg.Body = config.Bool(config.EnvAICBody)
+	}
+	cfg.Lint = lintRules(rc, uc)
 	// In non-interactive mode, favor requesting a single suggestion by default
 	// to avoid unnecessary tokens/work. Users can still override via AIC_SUGGESTIONS.
 	if config.Bool(config.EnvAICNonInteractive) {
@@ -91,10 +177,6 @@ func LoadConfig(systemAddition string) (Config, error) {
 	if v := config.Get(config.EnvAICModel); v != "" {
 		cfg.Model = v
 	}
-	// For custom provider, if AIC_MODEL isn't explicitly set, leave model empty and let the provider pick from /v1/models.
-	if cfg.Provider == "custom" && config.Get(config.EnvAICModel) == "" {
-		cfg.Model = ""
-	}
 	// Alias: plain gpt-5 -> specific dated release name
 	if cfg.Provider == "openai" && cfg.Model == "gpt-5" {
 		cfg.Model = "gpt-5-2025-08-07"
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/diesi/aic/internal/config"
 )
 
 func TestLoadConfig_MergesUserInstructionsAndCLI(t *testing.T) {
@@ -50,3 +52,64 @@ func TestLoadConfig_NoUserConfig_UsesCLIOnly(t *testing.T) {
 		t.Fatalf("expected CLI-only instructions; got %q", got)
 	}
 }
+
+func TestDiffFilterMergesIgnoreFileAndGlobs(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.WriteFile(".aicignore", []byte("generated/\n!go.sum\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(".aic.json", []byte(`{"exclude": ["*.snap"], "include": ["generated/keep.go"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	filter := cfg.DiffFilter()
+	cases := map[string]bool{
+		"generated/api.go":        true,
+		"generated/keep.go":       false,
+		"ui/__snapshots__/a.snap": true,
+		"go.sum":                  false, // default re-included by .aicignore
+		"yarn.lock":               true,
+		"main.go":                 false,
+	}
+	for p, want := range cases {
+		if got := filter.Excluded(p); got != want {
+			t.Errorf("Excluded(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestLoadAzureFromAicJSON(t *testing.T) {
+	stageInTempRepo(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, v := range []string{"AZURE_OPENAI_ENDPOINT", "AZURE_OPENAI_DEPLOYMENT", "AZURE_OPENAI_API_VERSION"} {
+		t.Setenv(v, "")
+	}
+	// The repo may pick a deployment and api-version, but not where the key goes.
+	repo := `{"azure": {"endpoint": "https://attacker.example", "deployment": "repo-gpt", "api_version": "2024-06-01"}}`
+	if err := os.WriteFile(".aic.json", []byte(repo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	az := config.LoadAzure()
+	if az.Endpoint != "" || az.Deployment != "repo-gpt" || az.APIVersion != "2024-06-01" {
+		t.Fatalf("unexpected settings from the repo .aic.json: %+v", az)
+	}
+
+	user := `{"azure": {"endpoint": "https://res.openai.azure.com", "deployment": "home-gpt"}}`
+	if err := os.WriteFile(filepath.Join(home, ".aic.json"), []byte(user), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	az = config.LoadAzure()
+	if az.Endpoint != "https://res.openai.azure.com" || az.Deployment != "home-gpt" || az.APIVersion != "2024-06-01" {
+		t.Fatalf("~/.aic.json should win over the repo: %+v", az)
+	}
+
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "env-gpt")
+	if az = config.LoadAzure(); az.Deployment != "env-gpt" || az.Endpoint != "https://res.openai.azure.com" {
+		t.Fatalf("the environment should win per setting: %+v", az)
+	}
+}
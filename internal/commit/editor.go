@@ -0,0 +1,111 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/lint"
+)
+
+// ErrEmptyMessage is returned when the edited message is empty once comment
+// lines are removed.
+var ErrEmptyMessage = errors.New("empty commit message after editing; aborting")
+
+// editorCommand resolves the editor the way git does: GIT_EDITOR, then
+// core.editor, then VISUAL (unless TERM=dumb), then EDITOR, then vi.
+func editorCommand() string {
+	// git exports GIT_EDITOR=: to hooks when no editor will be shown
+	// (e.g. commit -m), which must not disable editing from the selector.
+	if v := strings.TrimSpace(os.Getenv("GIT_EDITOR")); v != "" && v != ":" {
+		return v
+	}
+	if out, err := exec.Command("git", "config", "core.editor").Output(); err == nil {
+		if v := strings.TrimSpace(string(out)); v != "" {
+			return v
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("VISUAL")); v != "" && os.Getenv("TERM") != "dumb" {
+		return v
+	}
+	if v := strings.TrimSpace(os.Getenv("EDITOR")); v != "" {
+		return v
+	}
+	return "vi"
+}
+
+// EditMessage opens msg in the user's editor together with a commented
+// summary of the staged files and returns the edited message with comment
+// lines removed.
+func EditMessage(msg string) (string, error) {
+	files, _ := git.StagedFiles()
+	return EditMessageFor(msg, files)
+}
+
+// EditMessageFor is EditMessage for a commit of files rather than of all
+// staged files, e.g. one group of aic split.
+func EditMessageFor(msg string, files []string) (string, error) {
+	f, err := os.CreateTemp("", "aic-COMMIT_EDITMSG-*.txt")
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	cc := git.CommentChar()
+	_, err = f.WriteString(editTemplate(msg, files, cc))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := runEditor(editorCommand(), path); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	edited := lint.Clean(string(data), cc)
+	if edited == "" {
+		return "", ErrEmptyMessage
+	}
+	return edited, nil
+}
+
+// editTemplate is the file shown in the editor: msg followed by git-style
+// comment lines, starting with cc, listing files.
+func editTemplate(msg string, files []string, cc string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(msg) + "\n\n")
+	b.WriteString(cc + " Edit the commit message above. Lines starting with '" + cc + "' are ignored,\n")
+	b.WriteString(cc + " and an empty message aborts the commit.\n")
+	if len(files) > 0 {
+		b.WriteString(cc + "\n" + cc + " Changes to be committed:\n")
+		for _, f := range files {
+			b.WriteString(cc + "\t" + f + "\n")
+		}
+	}
+	return b.String()
+}
+
+// runEditor runs editor (a shell command line, e.g. "code --wait") on path.
+// When stdin is not a terminal, as in a Git hook, the editor is attached to
+// /dev/tty instead.
+func runEditor(editor, path string) error {
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if fi, err := os.Stdin.Stat(); err == nil && (fi.Mode()&os.ModeCharDevice) == 0 {
+		if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+			defer tty.Close()
+			cmd.Stdin, cmd.Stdout = tty, tty
+		}
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
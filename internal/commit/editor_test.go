@@ -0,0 +1,117 @@
+package commit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/gittest"
+)
+
+// fakeEditor writes a shell script that records the file it was given and
+// replaces its content with text, and returns the script path.
+func fakeEditor(t *testing.T, text string) (editor, seen string) {
+	t.Helper()
+	dir := t.TempDir()
+	seen = filepath.Join(dir, "seen.txt")
+	script := filepath.Join(dir, "editor.sh")
+	body := "#!/bin/sh\ncp \"$1\" " + seen + "\ncat > \"$1\" <<'EOF'\n" + text + "EOF\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, seen
+}
+
+func TestEditMessageStripsComments(t *testing.T) {
+	stageInTempRepo(t)
+	editor, seen := fakeEditor(t, "feat: edited subject  \n# a comment\n\n\n- edited body\n#\tmain.go\n")
+	t.Setenv("GIT_EDITOR", editor)
+
+	got, err := EditMessage("feat: original")
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if want := "feat: edited subject\n\n- edited body"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	shown, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(shown), "feat: original\n") || !strings.Contains(string(shown), "#\tmain.go\n") {
+		t.Fatalf("editor got unexpected template:\n%s", shown)
+	}
+}
+
+func TestEditMessageHonoursCommentChar(t *testing.T) {
+	stageInTempRepo(t)
+	gittest.Run(t, "config", "core.commentChar", ";")
+	editor, seen := fakeEditor(t, "fix: edited\n\n#123 stays\n; dropped\n")
+	t.Setenv("GIT_EDITOR", editor)
+	got, err := EditMessage("fix: original")
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if want := "fix: edited\n\n#123 stays"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if shown, _ := os.ReadFile(seen); !strings.Contains(string(shown), ";\tmain.go\n") {
+		t.Fatalf("template should use the comment char:\n%s", shown)
+	}
+}
+
+func TestEditMessageForListsGivenFiles(t *testing.T) {
+	stageInTempRepo(t)
+	editor, seen := fakeEditor(t, "feat: edited\n")
+	t.Setenv("GIT_EDITOR", editor)
+	if _, err := EditMessageFor("feat: original", []string{"api.go (hunk 1/2)"}); err != nil {
+		t.Fatalf("EditMessageFor: %v", err)
+	}
+	shown, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(shown), "#\tapi.go (hunk 1/2)\n") || strings.Contains(string(shown), "main.go") {
+		t.Fatalf("editor should list the given files only:\n%s", shown)
+	}
+}
+
+func TestEditMessageEmptyAborts(t *testing.T) {
+	stageInTempRepo(t)
+	editor, _ := fakeEditor(t, "# only comments\n\n")
+	t.Setenv("GIT_EDITOR", editor)
+	if _, err := EditMessage("feat: x"); !errors.Is(err, ErrEmptyMessage) {
+		t.Fatalf("expected ErrEmptyMessage, got %v", err)
+	}
+}
+
+func TestEditorCommandPrecedence(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("GIT_EDITOR", "")
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	if got := editorCommand(); got != "vi" {
+		t.Fatalf("default editor = %q, want vi", got)
+	}
+	t.Setenv("EDITOR", "nano")
+	t.Setenv("VISUAL", "emacs")
+	t.Setenv("TERM", "xterm")
+	if got := editorCommand(); got != "emacs" {
+		t.Fatalf("VISUAL should win over EDITOR, got %q", got)
+	}
+	gittest.Run(t, "config", "core.editor", "code --wait")
+	if got := editorCommand(); got != "code --wait" {
+		t.Fatalf("core.editor should win over VISUAL, got %q", got)
+	}
+	// git sets GIT_EDITOR=: for hooks under "commit -m"; it must be ignored.
+	t.Setenv("GIT_EDITOR", ":")
+	if got := editorCommand(); got != "code --wait" {
+		t.Fatalf("GIT_EDITOR=: should be ignored, got %q", got)
+	}
+	t.Setenv("GIT_EDITOR", "vim")
+	if got := editorCommand(); got != "vim" {
+		t.Fatalf("GIT_EDITOR should win, got %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/diesi/aic/internal/gittest"
+	"github.com/diesi/aic/internal/provider"
+)
+
+func TestLoadConfigProviderChain(t *testing.T) {
+	t.Setenv("AIC_DISABLE_REPO_CONFIG", "1")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AIC_MODEL", "")
+
+	t.Setenv("AIC_PROVIDER", "OpenAI, claude,custom,claude")
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Provider != "openai" || strings.Join(cfg.Fallbacks, ",") != "claude,custom" {
+		t.Fatalf("unexpected chain: %q %v", cfg.Provider, cfg.Fallbacks)
+	}
+
+	t.Setenv("AIC_PROVIDER", "")
+	if err := os.WriteFile(filepath.Join(home, ".aic.json"), []byte(`{"providers":["gemini","ollama"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Provider != "gemini" || cfg.Model != provider.DefaultGeminiModel || strings.Join(cfg.Fallbacks, ",") != "ollama" {
+		t.Fatalf("unexpected chain from .aic.json: %+v", cfg)
+	}
+
+	t.Setenv("AIC_PROVIDER", "openai,bogus")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Fatalf("expected unknown provider error, got %v", err)
+	}
+}
+
+// stageInTempRepo creates a git repo with one staged file and makes it the
+// working directory for the rest of the test.
+func stageInTempRepo(t *testing.T) {
+	t.Helper()
+	gittest.Repo(t)
+	gittest.Write(t, map[string]string{"main.go": "package main\n"})
+	gittest.Run(t, "add", "main.go")
+}
+
+func TestGenerateSuggestionsFallsThroughChain(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("AIC_RETRY_MAX_ATTEMPTS", "1")
+	t.Setenv("CLAUDE_API_KEY", "")
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"message":"overloaded"}}`)
+	}))
+	defer down.Close()
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3:8b","details":{"family":"llama"}}]}`)
+		case "/api/chat":
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"feat: add main package"},"done":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ollama.Close()
+	t.Setenv("CUSTOM_BASE_URL", down.URL)
+	t.Setenv("OLLAMA_HOST", ollama.URL)
+
+	// custom is down and claude has no key, so ollama should answer.
+	cfg := Config{Provider: "custom", Model: "m", Fallbacks: []string{"claude", "ollama"}, Suggestions: 1}
+	got, source, err := GenerateSuggestions(context.Background(), cfg, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0] != "feat: add main package" {
+		t.Fatalf("unexpected suggestions: %v", got)
+	}
+	if source.String() != "ollama" {
+		t.Fatalf("expected ollama to be reported as source, got %q", source)
+	}
+
+	// Without a fallback the primary's error is returned.
+	cfg.Fallbacks = nil
+	if _, _, err := GenerateSuggestions(context.Background(), cfg, ""); err == nil {
+		t.Fatal("expected error from failing primary")
+	}
+}
+
+func TestCombineAndRefineUseAnsweringFallback(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("AIC_RETRY_MAX_ATTEMPTS", "1")
+
+	var primary int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primary, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"rate limited"}}`)
+	}))
+	defer down.Close()
+	var chats int32
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			fmt.Fprint(w, `{"models":[{"name":"llama3:8b","details":{"family":"llama"}}]}`)
+			return
+		}
+		atomic.AddInt32(&chats, 1)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"feat: add main package"},"done":true}`)
+	}))
+	defer ollama.Close()
+	t.Setenv("CUSTOM_BASE_URL", down.URL)
+	t.Setenv("OLLAMA_HOST", ollama.URL)
+
+	cfg := Config{Provider: "custom", Model: "m", Fallbacks: []string{"ollama"}, Suggestions: 1}
+	_, src, err := GenerateSuggestions(context.Background(), cfg, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := src.answering(cfg, "")
+	if h.name != "ollama" {
+		t.Fatalf("expected ollama to answer, got %+v", h)
+	}
+	atomic.StoreInt32(&primary, 0)
+	if got, err := combineWith(context.Background(), cfg, h, []string{"feat: a", "fix: b"}); err != nil || len(got) != 1 {
+		t.Fatalf("combine: got %v, %v", got, err)
+	}
+	r, err := newRefiner(context.Background(), cfg, h, []string{"feat: a"})
+	if err != nil {
+		t.Fatalf("newRefiner: %v", err)
+	}
+	if _, err := r.Refine(context.Background(), "shorter"); err != nil {
+		t.Fatalf("refine: %v", err)
+	}
+	if primary != 0 || chats < 3 || r.Source() != h.label() {
+		t.Fatalf("combine and refine should go to the fallback: primary %d, fallback %d, source %q", primary, chats, r.Source())
+	}
+	// The zero Source stands for the primary.
+	if got := (Source{}).answering(cfg, "key"); got.name != "custom" || got.apiKey != "key" {
+		t.Fatalf("zero Source answered by %+v", got)
+	}
+}
+
+func TestGenerateSuggestionsReusesCachedResponse(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var chats int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&chats, 1)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"feat: add main package"},"done":true}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	cfg := Config{Provider: "ollama", Model: "llama3", Suggestions: 1}
+	for i := 0; i < 2; i++ {
+		got, _, err := GenerateSuggestions(context.Background(), cfg, "")
+		if err != nil || len(got) != 1 {
+			t.Fatalf("run %d: got %v, %v", i, got, err)
+		}
+	}
+	if chats != 1 {
+		t.Fatalf("expected the second run to hit the cache, got %d requests", chats)
+	}
+
+	t.Setenv("AIC_NO_CACHE", "1")
+	if _, _, err := GenerateSuggestions(context.Background(), cfg, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chats != 2 {
+		t.Fatalf("AIC_NO_CACHE should bypass the cache, got %d requests", chats)
+	}
+}
@@ -1,120 +1,460 @@
 package commit
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 
+	"github.com/diesi/aic/internal/cache"
 	"github.com/diesi/aic/internal/cli"
 	"github.com/diesi/aic/internal/config"
-	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/lint"
 	"github.com/diesi/aic/internal/openai"
 	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
 )
 
 // GenerateSuggestions creates commit message suggestions based on staged diff.
-func GenerateSuggestions(cfg Config, apiKey string) ([]string, error) {
+// Provider calls are bound to ctx; cancelling it aborts any request in flight.
+// Besides the suggestions it returns the provider (and model) that produced
+// them, which may be a fallback when cfg.Fallbacks is set.
+func GenerateSuggestions(ctx context.Context, cfg Config, apiKey string) ([]string, Source, error) {
+	suggestions, h, err := generateSuggestions(ctx, cfg, apiKey, nil)
+	return suggestions, Source{h}, err
+}
+
+// Source is the provider of the fallback chain that produced suggestions.
+// Combining and refining them in the selector goes to the same provider, so
+// they keep working while the primary is down. The zero Source stands for
+// cfg.Provider.
+type Source struct {
+	hop providerHop
+}
+
+// String labels the provider for display, e.g. "ollama (llama3)"; "" for the
+// zero Source.
+func (s Source) String() string { return s.hop.label() }
+
+// answering returns the hop behind s, or the primary of cfg (with apiKey)
+// for the zero Source.
+func (s Source) answering(cfg Config, apiKey string) providerHop {
+	if s.hop.name == "" {
+		return primaryHop(cfg, apiKey)
+	}
+	return s.hop
+}
+
+// providerHop is one provider of the fallback chain.
+type providerHop struct {
+	name   string
+	model  string
+	apiKey string
+}
+
+// label describes the hop for display, e.g. "claude (claude-3-sonnet-20240229)".
+func (h providerHop) label() string {
+	if h.model == "" {
+		return h.name
+	}
+	return h.name + " (" + h.model + ")"
+}
+
+// providerChain returns cfg.Provider followed by its fallbacks. The primary
+// keeps the configured model and apiKey; each fallback uses its provider's
+// default model and API key from the environment.
+func providerChain(cfg Config, apiKey string) []providerHop {
+	hops := []providerHop{primaryHop(cfg, apiKey)}
+	for _, name := range cfg.Fallbacks {
+		if name == cfg.Provider {
+			continue
+		}
+		hops = append(hops, providerHop{name: name, model: provider.DefaultModel(name), apiKey: provider.APIKey(name)})
+	}
+	return hops
+}
+
+// primaryHop is the first hop of the chain: cfg.Provider with its model.
+func primaryHop(cfg Config, apiKey string) providerHop {
+	return providerHop{name: cfg.Provider, model: cfg.Model, apiKey: apiKey}
+}
+
+// provider returns the implementation for h from the provider registry, or
+// the missing-key error if h cannot be used.
+func (h providerHop) provider() (provider.Provider, error) {
+	return provider.New(h.name, h.apiKey)
+}
+
+// generateSuggestions implements GenerateSuggestions. When emit is non-nil the
+// provider response is streamed and each suggestion is passed to emit, along
+// with the hop producing it, as soon as its line is complete.
+// Suggestions of a choice the provider drops later are retracted.
+//
+// Providers are tried in chain order: when one fails (rate limit, outage,
+// missing key, ...) the next is asked, unless suggestions were already
+// emitted (and not retracted) or ctx was canceled.
+func generateSuggestions(ctx context.Context, cfg Config, apiKey string, emit func(h providerHop, u Update)) ([]string, providerHop, error) {
 	if config.Bool(config.EnvAICMock) {
 		mock := []string{"feat: mock change", "fix: mock issue", "chore: update dependencies"}
+		if cfg.Body {
+			for i, m := range mock {
+				mock[i] = m + "\n\n- Mock body explaining the change"
+			}
+		}
 		if cfg.Suggestions > 0 && cfg.Suggestions < len(mock) {
 			mock = mock[:cfg.Suggestions]
 		}
-		return mock, nil
+		mockHop := providerHop{name: "mock"}
+		if emit != nil {
+			for _, m := range mock {
+				emit(mockHop, Update{Suggestion: m})
+			}
+		}
+		return mock, mockHop, nil
 	}
-	if apiKey == "" {
-		switch cfg.Provider {
-		case "claude":
-			return nil, errors.New("missing CLAUDE_API_KEY")
-		case "gemini":
-			return nil, errors.New("missing GEMINI_API_KEY")
-		case "custom":
-			// Custom provider may not require an API key (e.g., local LM Studio)
-			// Proceed without error.
-		default:
-			return nil, errors.New("missing OPENAI_API_KEY")
+	hops := providerChain(cfg, apiKey)
+	var firstErr error
+	usable := 0
+	for _, h := range hops {
+		if _, err := h.provider(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
+		usable++
 	}
-	gitDiff, err := git.StagedDiff()
-	if err != nil {
-		return nil, err
+	if usable == 0 {
+		return nil, providerHop{}, firstErr
 	}
-	if strings.TrimSpace(gitDiff) == "" {
-		return nil, errors.New("no staged changes")
+	gitDiff, findings, err := stagedDiff(cfg)
+	if err != nil {
+		return nil, providerHop{}, err
 	}
-
-	var p provider.Provider
-	switch cfg.Provider {
-	case "claude":
-		p = provider.NewClaude(apiKey)
-	case "gemini":
-		p = provider.NewGemini(apiKey)
-	case "custom":
-		p = provider.NewCustom(apiKey)
-	default:
-		p = provider.NewOpenAI(apiKey)
+	if len(findings) > 0 && AbortOnSecrets() {
+		return nil, providerHop{}, secretsError(findings)
 	}
 
-	originalDiff := gitDiff
-	const hardLimit = 16000
-	var summary string
-	if len(originalDiff) > hardLimit {
-		if s, sumErr := summarizeDiff(p, cfg.Provider, originalDiff); sumErr == nil && strings.TrimSpace(s) != "" {
-			summary = s
-		} else {
-			summary = ""
+	firstErr = nil
+	for i, h := range hops {
+		p, err := h.provider()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
-		if len(gitDiff) > hardLimit {
-			if !utf8.ValidString(gitDiff[:hardLimit]) {
-				cut := hardLimit
-				for cut > 0 && (gitDiff[cut]&0xC0) == 0x80 {
-					cut--
+		emitted := 0
+		var hopEmit func(Update)
+		if emit != nil {
+			hopEmit = func(u Update) {
+				if u.Retract {
+					emitted--
+				} else {
+					emitted++
 				}
-				gitDiff = gitDiff[:cut]
-			} else {
-				gitDiff = gitDiff[:hardLimit]
+				emit(h, u)
 			}
 		}
-        if summary != "" && config.Bool(config.EnvAICDebug) {
-            fmt.Fprintf(os.Stderr, "%s\n[debug] diff summarized (orig=%d chars, shown=%d)\n%s\n", cli.ColorDim, len(originalDiff), len(gitDiff), cli.ColorReset)
-            fmt.Fprintf(os.Stderr, "===== DIFF SUMMARY DEBUG START =====\n%s\n===== DIFF SUMMARY DEBUG END =====\n", summary)
-        }
+		suggestions, err := suggestWith(ctx, p, h, cfg, gitDiff, hopEmit)
+		if err == nil {
+			return suggestions, h, nil
+		}
+		if ctx.Err() != nil || emitted > 0 {
+			return nil, providerHop{}, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if config.Bool(config.EnvAICDebug) && i < len(hops)-1 {
+			fmt.Fprintf(os.Stderr, "[aic][debug] %s failed, trying next provider: %v\n", h.label(), err)
+		}
 	}
+	return nil, providerHop{}, firstErr
+}
 
-	userContent := composeUserContent(originalDiff, gitDiff, summary)
-    systemMsg := "You generate single-line Conventional Commit messages. " +
-        "Rules: one line per message (<=72 chars), imperative mood, no trailing period; " +
-        "start with a type (feat|fix|refactor|docs|chore|test|perf|build|ci|style) and optional scope; " +
-        "do NOT mention the diff/user/files or explain. No numbering, bullets, quotes, emojis, or reasoning. " +
-        "Output: return ONLY the messages, one per choice. " +
-        "Produce exactly " + strconv.Itoa(cfg.Suggestions) + " distinct options prioritizing the most impactful changes."
-	if cfg.SystemAddition != "" {
-		systemMsg += " Additional user instructions: " + cfg.SystemAddition
-	}
+// suggestWith asks provider p (configured as hop h) for suggestions on gitDiff,
+// presented as built by diffPrompt.
+func suggestWith(ctx context.Context, p provider.Provider, h providerHop, cfg Config, gitDiff string, emit func(Update)) ([]string, error) {
+	var err error
+	originalDiff := gitDiff
+	systemMsg := suggestionPrompt(cfg)
 
     if config.Bool(config.EnvAICDebug) {
         fmt.Fprintln(os.Stderr, "[aic][debug] system prompt for suggestions:")
         fmt.Fprintln(os.Stderr, systemMsg)
     }
 
+	userContent, budget := diffPrompt(ctx, p, h, cfg, systemMsg, originalDiff)
+
 	temp := float32(0.25)
-	resp, err := p.Chat(openai.ChatCompletionRequest{
-		Model:       cfg.Model,
+	req := openai.ChatCompletionRequest{
+		Model:       h.model,
 		Messages:    []openai.Message{{Role: "system", Content: systemMsg}, {Role: "user", Content: userContent}},
-		MaxTokens:   256,
+		MaxTokens:   budget.Output,
 		N:           cfg.Suggestions,
 		Temperature: &temp,
-	})
+	}
+	debugTokens("suggestions", req, budget.Window)
+	var resp *provider.CompletionResponse
+	var suggestions []string
+	responses := cache.Open("responses")
+	key := cache.Key(h.name, req.Model, systemMsg, originalDiff, strconv.Itoa(cfg.Suggestions))
+	cached := &provider.CompletionResponse{}
+	hit := responses.Get(key, cached)
+	if hit {
+		if config.Bool(config.EnvAICDebug) {
+			fmt.Fprintf(os.Stderr, "[aic][debug] using cached response for %s\n", h.label())
+		}
+		resp = cached
+		if emit != nil {
+			lines := cfg.streamAssembler(emit)
+			for i, ch := range resp.Choices {
+				lines.add(i, ch+"\n")
+			}
+			if len(lines.out) == 0 {
+				for _, s := range cfg.parseChoices(resp.Choices) {
+					lines.keep(s)
+				}
+			}
+			suggestions = lines.out
+		} else {
+			suggestions = cfg.parseChoices(resp.Choices)
+		}
+	} else if emit != nil {
+		lines := cfg.streamAssembler(emit)
+		resp, err = p.ChatStream(ctx, req, lines.onDelta)
+		if err == nil {
+			lines.flush()
+			suggestions = lines.out
+			// The final response may differ from what was streamed (e.g., a provider fell
+			// back to a regular call, or body-mode JSON was pretty-printed); surface
+			// those suggestions instead.
+			if len(suggestions) == 0 {
+				for _, s := range cfg.parseChoices(resp.Choices) {
+					lines.keep(s)
+				}
+				suggestions = lines.out
+			}
+		}
+	} else {
+		resp, err = p.Chat(ctx, req)
+		if err == nil {
+			suggestions = cfg.parseChoices(resp.Choices)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 	if len(resp.Choices) == 0 {
 		return nil, errors.New("no choices returned")
 	}
-	suggestions := make([]string, 0, len(resp.Choices))
-	for _, msg := range resp.Choices {
+	retried := false
+	if rejected := cfg.parseRawChoices(resp.Choices); len(suggestions) == 0 && len(rejected) > 0 {
+		// Every suggestion broke the lint rules; ask once more, saying why.
+		retry := req
+		retry.Messages = append(req.Messages[:len(req.Messages):len(req.Messages)],
+			openai.Message{Role: "assistant", Content: strings.Join(resp.Choices, "\n")},
+			openai.Message{Role: "user", Content: lintFeedback(cfg, rejected)})
+		if config.Bool(config.EnvAICDebug) {
+			fmt.Fprintf(os.Stderr, "[aic][debug] all %d suggestions failed lint; asking %s again\n", len(rejected), h.label())
+		}
+		again, err := p.Chat(ctx, retry)
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		if err == nil {
+			resp, retried = again, true
+			suggestions = cfg.parseChoices(resp.Choices)
+			if len(suggestions) > cfg.Suggestions {
+				suggestions = suggestions[:cfg.Suggestions]
+			}
+			if emit != nil {
+				for _, sug := range suggestions {
+					emit(Update{Suggestion: sug})
+				}
+			}
+		}
+	}
+	if len(suggestions) == 0 {
+		errMsg := "empty suggestions"
+		if config.Bool(config.EnvAICDebug) && resp != nil && resp.Raw != "" {
+			errMsg = fmt.Sprintf("%s\n\nRaw Response:\n%s", errMsg, resp.Raw)
+		}
+		return nil, errors.New(errMsg)
+	}
+	if !hit || retried {
+		_ = responses.Put(key, resp)
+	}
+	if len(suggestions) > cfg.Suggestions {
+		suggestions = suggestions[:cfg.Suggestions]
+	}
+	return suggestions, nil
+}
+
+// diffPrompt returns the user message presenting gitDiff to hop h together
+// with the output budget it was sized for. The prompt is sized from the
+// model's context window: diffs that fit the budget are sent as is; larger
+// ones are summarized and sent together with as much raw diff as still fits.
+func diffPrompt(ctx context.Context, p provider.Provider, h providerHop, cfg Config, systemMsg, gitDiff string) (string, tokens.Budget) {
+	originalDiff := gitDiff
+	window := ContextWindow(cfg, h.name, h.model)
+	budget := tokens.Budget{Window: window, Output: tokens.SuggestionOutput(cfg.Suggestions)}
+	if cfg.Body {
+		budget.Output = tokens.BodySuggestionOutput(cfg.Suggestions)
+	}
+	diffBudget := min(budget.Input()-tokens.Estimate(systemMsg), maxDiffTokens)
+	var summary string
+	if tokens.Estimate(originalDiff) > diffBudget {
+		if s, sumErr := summarizeDiff(ctx, p, cfg, h.name, originalDiff); sumErr == nil && strings.TrimSpace(s) != "" {
+			summary = s
+		}
+		// Leave room for the summary and the cutoff notes, but always keep
+		// some raw diff for the model to look at.
+		rawBudget := diffBudget - tokens.Estimate(summary) - 64
+		if rawBudget < diffBudget/4 {
+			rawBudget = diffBudget / 4
+		}
+		gitDiff = firstNRunes(gitDiff, tokens.Chars(rawBudget))
+        if summary != "" && config.Bool(config.EnvAICDebug) {
+            fmt.Fprintf(os.Stderr, "%s\n[debug] diff summarized (orig=%d chars, shown=%d)\n%s\n", cli.ColorDim, len(originalDiff), len(gitDiff), cli.ColorReset)
+            fmt.Fprintf(os.Stderr, "===== DIFF SUMMARY DEBUG START =====\n%s\n===== DIFF SUMMARY DEBUG END =====\n", summary)
+        }
+	}
+
+	return composeUserContent(originalDiff, gitDiff, summary), budget
+}
+
+// suggestionPrompt returns the system prompt asking for cfg.Suggestions
+// messages: single lines, or in body mode one JSON object per line with a
+// subject and body bullets.
+func suggestionPrompt(cfg Config) string {
+	var systemMsg string
+	if cfg.Body {
+		systemMsg = "You generate Conventional Commit messages with a subject and a body. " +
+			"Subject rules: " + cfg.HeaderRules() + ". " +
+			"Body: 1-5 short bullet points explaining what changed and why, one sentence each, without bullet markers. " +
+			"Do NOT mention the diff/user or explain your reasoning. No numbering, quotes, emojis, or code fences. " +
+			"Output: one JSON object per line, exactly {\"subject\": \"...\", \"body\": [\"...\"]}, and nothing else. " +
+			"Produce exactly " + strconv.Itoa(cfg.Suggestions) + " distinct options prioritizing the most impactful changes."
+	} else {
+		systemMsg = "You generate single-line Conventional Commit messages. " +
+			"Rules: one line per message, " + cfg.HeaderRules() + "; " +
+			"do NOT mention the diff/user/files or explain. No numbering, bullets, quotes, emojis, or reasoning. " +
+			"Output: return ONLY the messages, one per choice. " +
+			"Produce exactly " + strconv.Itoa(cfg.Suggestions) + " distinct options prioritizing the most impactful changes."
+	}
+	if p := cfg.Situation.prompt(); p != "" {
+		systemMsg += " " + p
+	}
+	if cfg.SystemAddition != "" {
+		systemMsg += " Additional user instructions: " + cfg.SystemAddition
+	}
+	return systemMsg
+}
+
+// HeaderRules describes c.Lint for a system prompt, e.g. "<=72 chars,
+// imperative mood, no trailing period; start with a type (feat|fix) and
+// optional scope".
+func (c Config) HeaderRules() string {
+	scope := "optional scope"
+	if c.Lint.RequireScope {
+		scope = "a scope in parentheses"
+	}
+	rules := "<=" + strconv.Itoa(c.Lint.MaxHeader()) + " chars, imperative mood, no trailing period; " +
+		"start with a type (" + strings.Join(c.Lint.AllowedTypes(), "|") + ") and " + scope
+	if strings.EqualFold(c.Lint.Case, lint.CaseUpper) {
+		rules += "; capitalize the first word after the colon"
+	}
+	if len(c.Lint.BannedWords) > 0 {
+		rules += "; never use the words " + strings.Join(c.Lint.BannedWords, ", ")
+	}
+	return rules
+}
+
+// parseChoices extracts suggestions from raw model choices in cfg's mode,
+// keeping those that pass c.Lint once auto-fixed.
+func (c Config) parseChoices(choices []string) []string {
+	var out []string
+	for _, sug := range c.parseRawChoices(choices) {
+		if sug, ok := c.lintSuggestion(sug); ok {
+			out = append(out, sug)
+		}
+	}
+	return out
+}
+
+// parseRawChoices extracts suggestions without linting them.
+func (c Config) parseRawChoices(choices []string) []string {
+	if c.Body {
+		return parseBodyChoices(choices)
+	}
+	return parseSuggestionChoices(choices)
+}
+
+// lintSuggestion applies the fixes c.Lint can make to sug and reports whether
+// the result passes. Merge subjects in git's format are exempt, as in aic lint.
+func (c Config) lintSuggestion(sug string) (string, bool) {
+	if c.Situation.Kind == Merge && lint.Exempt(sug) {
+		return sug, true
+	}
+	fixed, violations := c.Lint.Fix(sug)
+	if len(violations) > 0 {
+		if config.Bool(config.EnvAICDebug) {
+			fmt.Fprintf(os.Stderr, "[aic][debug] dropped suggestion %q: %s\n", sug, joinViolations(violations))
+		}
+		return "", false
+	}
+	return fixed, true
+}
+
+// lintFeedback asks the model to replace rejected suggestions, naming the
+// rules each one broke.
+func lintFeedback(cfg Config, rejected []string) string {
+	var b strings.Builder
+	b.WriteString("None of these messages follow the commit rules:\n")
+	for _, sug := range rejected {
+		_, violations := cfg.Lint.Fix(sug)
+		subject, _ := splitMessage(sug)
+		fmt.Fprintf(&b, "- %s (%s)\n", subject, joinViolations(violations))
+	}
+	fmt.Fprintf(&b, "Write %d new messages that follow the rules, in the same output format.", cfg.Suggestions)
+	return b.String()
+}
+
+func joinViolations(violations []lint.Violation) string {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// lineAssembler returns a streaming parser for cfg's mode that emits only
+// suggestions passing c.Lint.
+func (c Config) lineAssembler(emit func(string)) *lineAssembler {
+	a := &lineAssembler{limit: c.Suggestions, emit: emit, check: c.lintSuggestion}
+	if c.Body {
+		a.parse = parseBodyLine
+	}
+	return a
+}
+
+// streamAssembler is lineAssembler passing suggestions, and the retraction
+// of those whose choice was dropped, to emit.
+func (c Config) streamAssembler(emit func(Update)) *lineAssembler {
+	a := c.lineAssembler(func(sug string) { emit(Update{Suggestion: sug}) })
+	a.retract = func(sug string) { emit(Update{Suggestion: sug, Retract: true}) }
+	return a
+}
+
+// parseSuggestionChoices splits raw model choices into one suggestion per
+// non-empty line and strips list markers (numbering, bullets).
+func parseSuggestionChoices(choices []string) []string {
+	suggestions := make([]string, 0, len(choices))
+	for _, msg := range choices {
 		msg = strings.TrimSpace(msg)
 		if msg == "" {
 			continue
@@ -138,49 +478,7 @@ func GenerateSuggestions(cfg Config, apiKey string) ([]string, error) {
 			suggestions = append(suggestions, ln)
 		}
 	}
-	if len(suggestions) == 0 {
-		errMsg := "empty suggestions"
-		if config.Bool(config.EnvAICDebug) && resp != nil && resp.Raw != "" {
-			errMsg = fmt.Sprintf("%s\n\nRaw Response:\n%s", errMsg, resp.Raw)
-		}
-		return nil, errors.New(errMsg)
-	}
-	if len(suggestions) > cfg.Suggestions {
-		suggestions = suggestions[:cfg.Suggestions]
-	}
-	return suggestions, nil
-}
-
-// summarizeDiff creates a concise structured summary of a very large diff.
-// It ALWAYS uses the providers default model (defaultModel constant) regardless of user override.
-// The output is intentionally compact: bullet-style high level file change descriptions + notable additions/removals.
-func summarizeDiff(p provider.Provider, providerName, diff string) (string, error) {
-	// Light temperature for determinism
-	temp := float32(0.2)
-	req := openai.ChatCompletionRequest{
-		Model: defaultModelFor(providerName),
-		Messages: []openai.Message{
-			{
-				Role:    "system",
-				Content: "You summarize git diffs. Produce a concise overview: list each file (max 1 line) with nature of change (add/remove/modify/rename) and highlight any: API signature changes, new public functions, deleted functions, dependency/version changes, security related changes, configuration changes. After the list, include a short 'Key Impacts:' section (<=3 bullet lines). No commit messages, no speculation.",
-			},
-			{
-				Role:    "user",
-				Content: firstNRunes(diff, 48000),
-			},
-		},
-		MaxTokens:   384,
-		Temperature: &temp,
-	}
-	resp, err := p.Chat(req)
-	if err != nil {
-		return "", err
-	}
-	if resp == nil || len(resp.Choices) == 0 {
-		return "", errors.New("empty summary response")
-	}
-	out := strings.TrimSpace(resp.Choices[0])
-	return out, nil
+	return suggestions
 }
 
 func min(a, b int) int {
@@ -1,12 +1,15 @@
 package commit
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestGenerateSuggestionsMockModeDefaultTrim(t *testing.T) {
 	t.Setenv("AIC_MOCK", "1")
 	cfg, _ := LoadConfig("")
 	cfg.Suggestions = 2
-	got, err := GenerateSuggestions(cfg, "")
+	got, _, err := GenerateSuggestions(context.Background(), cfg, "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -18,7 +21,7 @@ func TestGenerateSuggestionsMockModeDefaultTrim(t *testing.T) {
 func TestPromptAndOfferNonInteractive(t *testing.T) {
 	t.Setenv("AIC_NON_INTERACTIVE", "1")
 	// PromptUserSelect should pick the first when non-interactive
-	msg, err := PromptUserSelect([]string{"first", "second"})
+	msg, err := PromptUserSelect(context.Background(), Config{}, "", []string{"first", "second"}, Source{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
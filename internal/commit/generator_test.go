@@ -2,6 +2,8 @@ package commit
 
 import (
 	"testing"
+
+	"github.com/diesi/aic/internal/provider"
 )
 
 // NOTE: This test only validates configuration parsing without calling the API.
@@ -31,7 +33,7 @@ func TestLoadConfigBounds(t *testing.T) {
 	if cfg.Suggestions != defaultSuggestions {
 		t.Fatalf("expected default suggestions, got %d", cfg.Suggestions)
 	}
-	if cfg.Model != defaultOpenAIModel {
+	if cfg.Model != provider.DefaultOpenAIModel {
 		t.Fatalf("expected default model, got %s", cfg.Model)
 	}
 }
@@ -0,0 +1,106 @@
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/lint"
+)
+
+func TestGenerateSuggestionsLintsAndReasks(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var requests [][]map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body.Messages)
+		content := "Added main package\nwip: main"
+		if len(requests) > 1 {
+			content = "Feat(main): Add main package.\nwip: still wrong"
+		}
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":true}`, content)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	cfg := Config{Provider: "ollama", Model: "llama3", Suggestions: 2}
+	got, _, err := GenerateSuggestions(context.Background(), cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"feat(main): add main package"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected one re-request, got %d requests", len(requests))
+	}
+	retry := requests[1]
+	if len(retry) != 4 || retry[2]["role"] != "assistant" || !strings.Contains(retry[3]["content"], "wip: main (type-enum") {
+		t.Fatalf("re-request does not explain the violations: %v", retry)
+	}
+}
+
+func TestSuggestionPromptFollowsLintRules(t *testing.T) {
+	prompt := suggestionPrompt(Config{Suggestions: 1, Lint: lint.Rules{
+		Types: []string{"feat", "fix"}, MaxLength: 50, RequireScope: true, BannedWords: []string{"file"},
+	}})
+	for _, want := range []string{"<=50 chars", "(feat|fix)", "a scope in parentheses", "never use the words file"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestSuggestFix(t *testing.T) {
+	t.Setenv("AIC_DISABLE_REPO_CONFIG", "1")
+	var asked string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		asked = body.Messages[1]["content"]
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"`+"```"+`fix(auth): handle expired sessions.`+"```"+`"},"done":true}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+	cfg := Config{Provider: "ollama", Model: "llama3", Lint: lint.Rules{RequireScope: true}}
+
+	got, err := SuggestFix(context.Background(), cfg, "", "Fix: Handle expired sessions.")
+	if err != nil || got != "fix(auth): handle expired sessions" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if !strings.Contains(asked, "scope-empty") {
+		t.Fatalf("request does not name the broken rule: %q", asked)
+	}
+
+	asked = ""
+	got, err = SuggestFix(context.Background(), Config{Provider: "ollama", Model: "llama3"}, "", "Fix: Handle expired sessions.")
+	if err != nil || got != "fix: handle expired sessions" || asked != "" {
+		t.Fatalf("mechanical fix: got %q, %v (asked %q)", got, err, asked)
+	}
+}
+
+func TestUnfence(t *testing.T) {
+	for in, want := range map[string]string{
+		"fix: a":           "fix: a",
+		"`fix: a`":         "fix: a",
+		"```fix: a```":     "fix: a",
+		"```\nfix: a\n```": "fix: a",
+		"```text\nfix: a\n\n- keep `code` here\n```": "fix: a\n\n- keep `code` here",
+		"  ```git\nfix: a\n```\n":                    "fix: a",
+	} {
+		if got := unfence(in); got != want {
+			t.Errorf("unfence(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
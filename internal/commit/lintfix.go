@@ -0,0 +1,85 @@
+package commit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+// SuggestFix returns a rewrite of msg that passes cfg.Lint. Violations that
+// can be fixed mechanically are fixed without a request; otherwise the
+// provider is asked to rewrite msg, keeping its meaning.
+func SuggestFix(ctx context.Context, cfg Config, apiKey, msg string) (string, error) {
+	fixed, violations := cfg.Lint.Fix(msg)
+	if len(violations) == 0 {
+		return fixed, nil
+	}
+	if config.Bool(config.EnvAICMock) {
+		return "chore: rewrite commit message", nil
+	}
+	p, err := cfg.NewProvider(apiKey)
+	if err != nil {
+		return "", err
+	}
+	systemMsg := "You rewrite Git commit messages that break the project's commit rules. " +
+		"Rules: " + cfg.HeaderRules() + ". " +
+		"Keep the meaning and any body (separated from the subject by a blank line); pick the type that fits the described change. " +
+		"Return ONLY the rewritten message, with no quotes, code fences or commentary."
+	if cfg.SystemAddition != "" {
+		systemMsg += " Additional user instructions: " + cfg.SystemAddition
+	}
+	var user strings.Builder
+	fmt.Fprintf(&user, "Message:\n%s\n\nBroken rules:\n", fixed)
+	for _, v := range violations {
+		fmt.Fprintf(&user, "- %s\n", v)
+	}
+	if files, err := git.StagedFiles(); err == nil && len(files) > 0 {
+		fmt.Fprintf(&user, "\nStaged files:\n%s\n", strings.Join(files, "\n"))
+	}
+	temp := float32(0.2)
+	req := openai.ChatCompletionRequest{
+		Model:       cfg.Model,
+		Messages:    []openai.Message{{Role: "system", Content: systemMsg}, {Role: "user", Content: user.String()}},
+		MaxTokens:   tokens.BodySuggestionOutput(1),
+		N:           1,
+		Temperature: &temp,
+	}
+	debugTokens("suggest-fix", req, ContextWindow(cfg, cfg.Provider, cfg.Model))
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no choices returned")
+	}
+	rewrite := unfence(resp.Choices[0])
+	if config.Bool(config.EnvAICDebug) {
+		fmt.Fprintf(os.Stderr, "[aic][debug] suggested rewrite: %q\n", rewrite)
+	}
+	fixed, violations = cfg.Lint.Fix(rewrite)
+	if len(violations) > 0 {
+		return "", fmt.Errorf("the suggested rewrite %q still breaks the rules: %s", rewrite, joinViolations(violations))
+	}
+	return fixed, nil
+}
+
+// unfence removes a code fence around s, with or without a language tag
+// ("```text"), and inline backticks around a one-line reply.
+func unfence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") && strings.Contains(s, "\n") {
+		lines := strings.Split(s, "\n")[1:]
+		if n := len(lines); n > 0 && strings.HasPrefix(strings.TrimSpace(lines[n-1]), "```") {
+			lines = lines[:n-1]
+		}
+		s = strings.Join(lines, "\n")
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "`"))
+}
@@ -0,0 +1,148 @@
+package commit
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+)
+
+// bodyWidth is the column at which body bullets are wrapped.
+const bodyWidth = 72
+
+// bodyMessage is one suggestion in body mode, as returned by the model (one
+// JSON object per line).
+type bodyMessage struct {
+	Subject string   `json:"subject"`
+	Body    []string `json:"body"`
+}
+
+// text renders m as a commit message: the subject, a blank line and the body
+// as wrapped "- " bullets. Without body lines only the subject is returned.
+func (m bodyMessage) text() string {
+	subject := strings.TrimSpace(m.Subject)
+	var bullets []string
+	for _, b := range m.Body {
+		b = cli.StripLeadingListMarker(strings.TrimSpace(b))
+		if b == "" {
+			continue
+		}
+		bullets = append(bullets, wrapBullet(b, bodyWidth))
+	}
+	if len(bullets) == 0 {
+		return subject
+	}
+	return subject + "\n\n" + strings.Join(bullets, "\n")
+}
+
+// FormatMessage renders a commit message from a subject and body bullets
+// the way body-mode suggestions are rendered (bullets wrapped at 72 columns).
+func FormatMessage(subject string, body []string) string {
+	return bodyMessage{Subject: subject, Body: body}.text()
+}
+
+// wrapBullet formats text as a "- " bullet wrapped at width, with
+// continuation lines indented under the text.
+func wrapBullet(text string, width int) string {
+	var lines []string
+	line := "-"
+	for _, w := range strings.Fields(text) {
+		if line != "-" && runeLen(line)+1+runeLen(w) > width {
+			lines = append(lines, line)
+			line = " "
+		}
+		line += " " + w
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+// splitMessage returns the subject (first line) and the body (everything
+// after the first blank line) of msg.
+func splitMessage(msg string) (subject, body string) {
+	msg = strings.TrimSpace(msg)
+	subject, rest, _ := strings.Cut(msg, "\n")
+	return strings.TrimSpace(subject), strings.TrimSpace(rest)
+}
+
+// bodyLine encodes msg as one JSON-lines suggestion, the inverse of
+// parseBodyLine (wrapped bullets are joined again).
+func bodyLine(msg string) string {
+	subject, body := splitMessage(msg)
+	m := bodyMessage{Subject: subject, Body: []string{}}
+	for _, ln := range strings.Split(body, "\n") {
+		switch {
+		case strings.TrimSpace(ln) == "":
+		case strings.HasPrefix(ln, "- ") || len(m.Body) == 0:
+			m.Body = append(m.Body, cli.StripLeadingListMarker(strings.TrimSpace(ln)))
+		default:
+			m.Body[len(m.Body)-1] += " " + strings.TrimSpace(ln)
+		}
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// parseBodyLine decodes one JSON-lines suggestion into its message text.
+// Lines that are not a JSON object with a subject (code fences, chatter) are
+// rejected.
+func parseBodyLine(line string) (string, bool) {
+	line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ","))
+	if !strings.HasPrefix(line, "{") {
+		return "", false
+	}
+	var m bodyMessage
+	if err := json.Unmarshal([]byte(line), &m); err != nil || strings.TrimSpace(m.Subject) == "" {
+		return "", false
+	}
+	return m.text(), true
+}
+
+// parseBodyChoices extracts body-mode suggestions from raw model choices.
+// Each choice is read as JSON lines; a choice that is instead one (possibly
+// pretty-printed) JSON object or array is decoded as a whole.
+func parseBodyChoices(choices []string) []string {
+	var out []string
+	for _, c := range choices {
+		lines := &lineAssembler{parse: parseBodyLine}
+		lines.add(0, c+"\n")
+		if len(lines.out) > 0 {
+			out = append(out, lines.out...)
+			continue
+		}
+		out = append(out, decodeBodyJSON(c)...)
+	}
+	return out
+}
+
+// decodeBodyJSON decodes s as a JSON object, an array of objects or an object
+// with a "messages" array, ignoring surrounding code fences.
+func decodeBodyJSON(s string) []string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "[{"); i > 0 {
+		s = s[i:]
+	}
+	if i := strings.LastIndexAny(s, "]}"); i >= 0 {
+		s = s[:i+1]
+	}
+	var list []bodyMessage
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		var wrapped struct {
+			Messages []bodyMessage `json:"messages"`
+			bodyMessage
+		}
+		if err := json.Unmarshal([]byte(s), &wrapped); err != nil {
+			return nil
+		}
+		list = wrapped.Messages
+		if len(list) == 0 {
+			list = []bodyMessage{wrapped.bodyMessage}
+		}
+	}
+	var out []string
+	for _, m := range list {
+		if strings.TrimSpace(m.Subject) != "" {
+			out = append(out, m.text())
+		}
+	}
+	return out
+}
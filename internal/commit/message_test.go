@@ -0,0 +1,101 @@
+package commit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diesi/aic/internal/gittest"
+)
+
+func TestParseBodyLine(t *testing.T) {
+	got, ok := parseBodyLine(`{"subject": "feat(api): add pagination", "body": ["Add cursor-based pagination to list endpoints so large result sets no longer time out", "- Document the new parameters"]}`)
+	if !ok {
+		t.Fatal("expected a valid body line")
+	}
+	want := "feat(api): add pagination\n\n" +
+		"- Add cursor-based pagination to list endpoints so large result sets no\n" +
+		"  longer time out\n" +
+		"- Document the new parameters"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	for _, bad := range []string{"```json", "feat: plain line", `{"body": ["no subject"]}`} {
+		if _, ok := parseBodyLine(bad); ok {
+			t.Errorf("parseBodyLine(%q) accepted", bad)
+		}
+	}
+}
+
+func TestParseBodyChoicesPrettyPrinted(t *testing.T) {
+	choices := []string{"```json\n[\n  {\"subject\": \"fix: a\", \"body\": [\"one\"]},\n  {\"subject\": \"fix: b\", \"body\": []}\n]\n```"}
+	got := parseBodyChoices(choices)
+	if len(got) != 2 || got[0] != "fix: a\n\n- one" || got[1] != "fix: b" {
+		t.Fatalf("unexpected suggestions: %q", got)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	subject, body := splitMessage("feat: x\n\n- a\n- b\n")
+	if subject != "feat: x" || body != "- a\n- b" {
+		t.Fatalf("got %q / %q", subject, body)
+	}
+	if subject, body := splitMessage("fix: y"); subject != "fix: y" || body != "" {
+		t.Fatalf("got %q / %q", subject, body)
+	}
+}
+
+func TestBodyModeStreamsJSONLines(t *testing.T) {
+	cfg := Config{Body: true, Suggestions: 2}
+	var emitted []string
+	a := cfg.lineAssembler(func(s string) { emitted = append(emitted, s) })
+	a.add(0, "{\"subject\": \"feat: a\", \"bo")
+	a.add(0, "dy\": [\"x\"]}\n{\"subject\": \"feat: b\", \"body\": [\"y\"]}")
+	a.flush()
+	if len(emitted) != 2 || emitted[0] != "feat: a\n\n- x" || emitted[1] != "feat: b\n\n- y" {
+		t.Fatalf("unexpected suggestions: %q", emitted)
+	}
+}
+
+func TestOfferCommitKeepsBody(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("AIC_NON_INTERACTIVE", "1")
+	t.Setenv("AIC_AUTO_COMMIT", "1")
+	msg := "feat: add main\n\n- Add the main package"
+	if err := OfferCommit(msg); err != nil {
+		t.Fatalf("OfferCommit: %v", err)
+	}
+	if got := gittest.Run(t, "log", "-1", "--format=%B"); got != msg {
+		t.Fatalf("committed message %q, want %q", got, msg)
+	}
+}
+
+func TestOfferAmendReplacesLastCommit(t *testing.T) {
+	historyInTempRepo(t)
+	t.Setenv("AIC_NON_INTERACTIVE", "1")
+	t.Setenv("AIC_AUTO_COMMIT", "1")
+	gittest.Write(t, map[string]string{"b.go": "package b\n"})
+	gittest.Run(t, "add", "b.go")
+	if err := OfferAmend("feat: add main and b"); err != nil {
+		t.Fatalf("OfferAmend: %v", err)
+	}
+	if n := gittest.Run(t, "rev-list", "--count", "HEAD"); n != "1" {
+		t.Fatalf("amend should not add a commit, got %s", n)
+	}
+	if got := gittest.Run(t, "log", "-1", "--format=%B"); got != "feat: add main and b" {
+		t.Fatalf("amended message %q", got)
+	}
+	if files := gittest.Run(t, "show", "--format=", "--name-only", "HEAD"); files != "b.go\nmain.go" {
+		t.Fatalf("amended commit has files %q", files)
+	}
+}
+
+func TestGenerateSuggestionsMockBody(t *testing.T) {
+	t.Setenv("AIC_MOCK", "1")
+	got, _, err := GenerateSuggestions(context.Background(), Config{Body: true, Suggestions: 1}, "")
+	if err != nil || len(got) != 1 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, body := splitMessage(got[0]); body == "" {
+		t.Fatalf("expected a body in body mode, got %q", got[0])
+	}
+}
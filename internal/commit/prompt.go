@@ -1,29 +1,34 @@
 package commit
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/diesi/aic/internal/cli"
 	"github.com/diesi/aic/internal/config"
 	xterm "golang.org/x/term"
 )
 
-// PromptUserSelect lets the user choose a suggestion.
-func PromptUserSelect(suggestions []string) (string, error) {
+// PromptUserSelect lets the user choose a suggestion. src is the provider
+// that produced the suggestions: it is shown in the header and answers when
+// suggestions are combined or refined (ctx bounds those calls). cfg and apiKey
+// stand in for the zero Source.
+func PromptUserSelect(ctx context.Context, cfg Config, apiKey string, suggestions []string, src Source) (string, error) {
+	hop, source := src.answering(cfg, apiKey), src.String()
 	// Non-interactive auto-select first suggestion if AIC_NON_INTERACTIVE=1
 	if config.Bool(config.EnvAICNonInteractive) {
 		if len(suggestions) == 0 {
 			return "", errors.New("no suggestions to select")
 		}
-		fmt.Printf("%s\n%sCommit message suggestions (non-interactive mode):%s\n", cli.ColorGray, cli.ColorBold, cli.ColorReset)
-		for i, s := range suggestions {
-			fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorCyan, s, cli.ColorReset)
-		}
+		fmt.Printf("%s\n%s%s (non-interactive mode):%s\n", cli.ColorGray, cli.ColorBold, suggestionsTitle(source), cli.ColorReset)
+		printSuggestionList(suggestions)
 		return suggestions[0], nil
 	}
 
@@ -35,10 +40,8 @@ func PromptUserSelect(suggestions []string) (string, error) {
 
 	// If STDIN is not a TTY (e.g., piped input), fall back to simple Scanln to remain scriptable
 	if fi, err := os.Stdin.Stat(); err == nil && (fi.Mode()&os.ModeCharDevice) == 0 {
-		fmt.Printf("%s%s %sCommit message suggestions:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, cli.ColorReset)
-		for i := 0; i < n; i++ {
-			fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorCyan, suggestions[i], cli.ColorReset)
-		}
+		fmt.Printf("%s%s %s%s:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, suggestionsTitle(source), cli.ColorReset)
+		printSuggestionList(suggestions[:n])
 		// Indicate 0 for tenth if applicable
 		rangeLabel := fmt.Sprintf("1-%d", n)
 		if n == 10 {
@@ -64,10 +67,8 @@ func PromptUserSelect(suggestions []string) (string, error) {
 	restore, err := enableCBreak()
 	if err != nil {
 		// Fallback to Scanln if terminal tweak fails
-		fmt.Printf("%s%s %sCommit message suggestions:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, cli.ColorReset)
-		for i := 0; i < n; i++ {
-			fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorCyan, suggestions[i], cli.ColorReset)
-		}
+		fmt.Printf("%s%s %s%s:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, suggestionsTitle(source), cli.ColorReset)
+		printSuggestionList(suggestions[:n])
 		rangeLabel := fmt.Sprintf("1-%d", n)
 		if n == 10 {
 			rangeLabel = "1-9,0"
@@ -85,10 +86,18 @@ func PromptUserSelect(suggestions []string) (string, error) {
 		}
 		return suggestions[0], nil
 	}
-	defer restore()
+	// restore is replaced whenever cbreak mode is re-entered after a combine
+	// or refine round, so restore whichever session is current on exit.
+	defer func() { restore() }()
 
 	selected := 0
 	checked := map[int]bool{}
+	// expanded shows the body of the highlighted suggestion under its subject.
+	expanded := false
+	// refiner holds the refinement conversation, started on the first 'r'.
+	var refiner *Refiner
+	// lines printed by the last render, cleared before the next one
+	backLines := 0
 	countChecked := func() int {
 		c := 0
 		for _, v := range checked {
@@ -108,7 +117,8 @@ func PromptUserSelect(suggestions []string) (string, error) {
 			maxMsg = 10
 		}
 		// Header (single line, no leading blank line)
-		fmt.Printf("%s%s %sCommit message suggestions:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, cli.ColorReset)
+		fmt.Printf("%s%s %s%s:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, suggestionsTitle(source), cli.ColorReset)
+		lines := 1
 		for i := 0; i < n; i++ {
 			idxLabel := fmt.Sprintf("%d", i+1)
 			if n == 10 && i == 9 {
@@ -127,11 +137,22 @@ func PromptUserSelect(suggestions []string) (string, error) {
 				prefix = fmt.Sprintf("%s> %s", cli.ColorYellow, cli.ColorReset)
 				lineColorStart = cli.ColorGreen + cli.ColorBold
 			}
-			msg := suggestions[i]
-			if runeLen(msg) > maxMsg {
-				msg = truncateRunes(msg, maxMsg)
+			msg, body := splitMessage(suggestions[i])
+			marker := ""
+			if body != "" && !(expanded && i == selected) {
+				marker = fmt.Sprintf(" (+%d lines)", strings.Count(body, "\n")+1)
+			}
+			if runeLen(msg)+runeLen(marker) > maxMsg {
+				msg = truncateRunes(msg, max(maxMsg-runeLen(marker), 10))
+			}
+			fmt.Printf("%s[%s] %s %s%s%s%s%s%s\n", prefix, idxLabel, box, lineColorStart, msg, lineColorEnd, cli.ColorDim, marker, cli.ColorReset)
+			lines++
+			if body != "" && expanded && i == selected {
+				for _, ln := range strings.Split(body, "\n") {
+					fmt.Printf("%s%s%s%s\n", strings.Repeat(" ", visiblePrefix), cli.ColorDim, truncateRunes(ln, maxMsg), cli.ColorReset)
+					lines++
+				}
 			}
-			fmt.Printf("%s[%s] %s %s%s%s\n", prefix, idxLabel, box, lineColorStart, msg, lineColorEnd)
 		}
 		// Instructions
 		extra := ",0"
@@ -142,16 +163,21 @@ func PromptUserSelect(suggestions []string) (string, error) {
 		if countChecked() >= 2 {
 			multi = fmt.Sprintf(" – %d selected; Enter combines", countChecked())
 		}
-        fmt.Printf("%sUse ↑/↓ or j/k, Space to toggle select, numbers to pick (1-9%s), Enter to confirm%s.%s\n", cli.ColorDim, extra, multi, cli.ColorReset)
+		bodyKeys := ""
+		if hasBody(suggestions[:n]) {
+			bodyKeys = ", →/← or l/h to show/hide the body"
+		}
+        fmt.Printf("%sUse ↑/↓ or j/k, Space to toggle select, numbers to pick (1-9%s)%s, e to edit, r to refine, Enter to confirm%s.%s\n", cli.ColorDim, extra, bodyKeys, multi, cli.ColorReset)
+		backLines = lines + 1
 	}
 
 	// Initial render
 	render()
 
-	// Read keys and update selection; act immediately on number press
-	in := make([]byte, 3)
-	// total lines for header + list + instruction
-	backLines := n + 2
+	// Read keys and update selection; act immediately on number press. One
+	// reader serves the whole session so that bytes it buffers while reading
+	// refine feedback are not lost to the next round.
+	stdin := bufio.NewReader(os.Stdin)
 	moveUp := func(lines int) {
 		if lines > 0 {
 			fmt.Printf("\033[%dA", lines)
@@ -160,12 +186,11 @@ func PromptUserSelect(suggestions []string) (string, error) {
 	clearLine := func() { fmt.Printf("\033[2K\r") }
 	for {
 		// Read one byte; handle escape sequences manually
-		_, err := os.Stdin.Read(in[:1])
+		b, err := stdin.ReadByte()
 		if err != nil {
 			// On read error, just return current selection
 			break
 		}
-		b := in[0]
 		if b == 0 {
 			continue
 		}
@@ -184,16 +209,8 @@ func PromptUserSelect(suggestions []string) (string, error) {
 						combined = append(combined, suggestions[i])
 					}
 				}
-				// Load cfg from env for combine step
-				cfg, _ := LoadConfig("")
-				var key string
-				if cfg.Provider == "claude" {
-					key = config.Get(config.EnvClaudeAPIKey)
-				} else {
-					key = config.Get(config.EnvOpenAIAPIKey)
-				}
-				stop := cli.Spinner(fmt.Sprintf("Combining %d selected messages via %s", len(combined), cfg.Model))
-				newSugs, err := GenerateCombinedSuggestions(cfg, key, combined)
+				stop := cli.Spinner(fmt.Sprintf("Combining %d selected messages via %s", len(combined), hop.label()))
+				newSugs, err := combineWith(ctx, cfg, hop, combined)
 				stop(err == nil)
 				if err != nil {
 					return "", err
@@ -203,32 +220,17 @@ func PromptUserSelect(suggestions []string) (string, error) {
 				restore, reErr = enableCBreak()
 				if reErr != nil {
 					// Fallback: simple selection prompt
-					// Print combined suggestions and pick first by default
-					fmt.Printf("%s%s %sCombined suggestions:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, cli.ColorReset)
-					for i, s := range newSugs {
-						fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorCyan, s, cli.ColorReset)
-					}
-					fmt.Printf("\n%s%s Choose a commit message [default: 1]: %s", cli.ColorBold, cli.IconPrompt, cli.ColorCyan)
-					var choiceInput string
-					fmt.Scanln(&choiceInput)
-					fmt.Printf("%s", cli.ColorReset)
-					if choiceInput == "" {
-						return newSugs[0], nil
-					}
-					if v, err := strconv.Atoi(choiceInput); err == nil && v >= 1 && v <= len(newSugs) {
-						return newSugs[v-1], nil
-					}
-					return newSugs[0], nil
+					return scanPick("Combined suggestions", newSugs), nil
 				}
-				// Ensure terminal will be restored on function exit for the new cbreak session too
-				defer restore()
 				// Replace list and reset state, then re-render
 				suggestions = newSugs
+				source = hop.label()
 				n = min(len(suggestions), 10)
 				selected = 0
 				checked = map[int]bool{}
-				// Recompute lines and render
-				backLines = n + 2
+				expanded = false
+				// The refinement conversation was about the previous list.
+				refiner = nil
 				render()
 				continue
 			}
@@ -251,14 +253,52 @@ func PromptUserSelect(suggestions []string) (string, error) {
 			if selected < n-1 {
 				selected++
 			}
+		case 'r': // refine all suggestions with free-text feedback
+			restore()
+			fmt.Printf("\n%s%s Refine suggestions%s %s(e.g. \"make it shorter\"; empty to cancel)%s: %s", cli.ColorBold, cli.IconPrompt, cli.ColorReset, cli.ColorDim, cli.ColorReset, cli.ColorCyan)
+			feedback, _ := stdin.ReadString('\n')
+			fmt.Printf("%s", cli.ColorReset)
+			if feedback = strings.TrimSpace(feedback); feedback != "" {
+				if refiner == nil {
+					var err error
+					if refiner, err = newRefiner(ctx, cfg, hop, suggestions[:n]); err != nil {
+						return "", err
+					}
+				}
+				stop := cli.Spinner("Refining suggestions via " + refiner.Source())
+				newSugs, err := refiner.Refine(ctx, feedback)
+				stop(err == nil)
+				if err != nil {
+					return "", err
+				}
+				suggestions = newSugs
+				source = refiner.Source()
+				n = min(len(suggestions), 10)
+				selected = 0
+				checked = map[int]bool{}
+				expanded = false
+			}
+			var reErr error
+			restore, reErr = enableCBreak()
+			if reErr != nil {
+				return scanPick("Refined suggestions", suggestions[:n]), nil
+			}
+			render()
+			continue
+		case 'e': // edit the highlighted suggestion in $EDITOR
+			restore()
+			return EditMessage(suggestions[selected])
+		case 'l': // show body
+			expanded = true
+		case 'h': // hide body
+			expanded = false
 		case 27: // ESC sequence
 			// Read next two bytes if available for CSI
-			os.Stdin.Read(in[1:2])
-			if in[1] != '[' {
+			if c, _ := stdin.ReadByte(); c != '[' {
 				continue
 			}
-			os.Stdin.Read(in[2:3])
-			switch in[2] {
+			c, _ := stdin.ReadByte()
+			switch c {
 			case 'A': // Up arrow
 				if selected > 0 {
 					selected--
@@ -267,6 +307,10 @@ func PromptUserSelect(suggestions []string) (string, error) {
 				if selected < n-1 {
 					selected++
 				}
+			case 'C': // Right arrow: show body
+				expanded = true
+			case 'D': // Left arrow: hide body
+				expanded = false
 			}
 		default:
 			// Number keys: 1..9 select directly; 0 selects 10th when available
@@ -293,8 +337,246 @@ func PromptUserSelect(suggestions []string) (string, error) {
 	return suggestions[selected], nil
 }
 
+var (
+	// activeRestore holds the restore func of the cbreak session currently in effect, if any.
+	activeRestoreMu sync.Mutex
+	activeRestore   func()
+)
+
+// RestoreTerminal undoes any terminal mode changes made by the interactive selectors.
+// It is safe to call from a signal handler goroutine and when no changes are active.
+func RestoreTerminal() {
+	activeRestoreMu.Lock()
+	r := activeRestore
+	activeRestoreMu.Unlock()
+	if r != nil {
+		r()
+	}
+}
+
+// PromptUserSelectStream shows suggestions as they arrive from s and lets the
+// user pick one before generation has finished (numbers or Enter). Once the
+// stream is complete, the regular PromptUserSelect takes over so that
+// multi-select/combine remains available. Without a TTY it waits for all
+// suggestions and falls back to PromptUserSelect directly.
+func PromptUserSelectStream(ctx context.Context, s *SuggestionStream) (string, error) {
+	fallback := func() (string, error) {
+		sugs, err := s.Wait()
+		if err != nil {
+			return "", err
+		}
+		return PromptUserSelect(ctx, s.cfg, s.apiKey, sugs, s.Source())
+	}
+	if config.Bool(config.EnvAICNonInteractive) {
+		return fallback()
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && (fi.Mode()&os.ModeCharDevice) == 0 {
+		return fallback()
+	}
+	// Poll stdin (return after 100ms without input) so new suggestions can be drawn while waiting for keys.
+	restore, err := enableCBreakMode("0", "1")
+	if err != nil {
+		return fallback()
+	}
+	defer restore()
+
+	suggestions := []string{}
+	selected := 0
+	frame := 0
+	printed := 0
+	clearBlock := func() {
+		if printed > 0 {
+			fmt.Printf("\033[%dA\r\033[J", printed)
+			printed = 0
+		}
+	}
+	render := func() {
+		clearBlock()
+		cols := termCols()
+		maxMsg := cols - 6
+		if maxMsg < 10 {
+			maxMsg = 10
+		}
+		fmt.Printf("%s%s %s%s:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, suggestionsTitle(s.Source().String()), cli.ColorReset)
+		for i, sug := range suggestions {
+			prefix := "  "
+			lineColorStart := cli.ColorCyan
+			if i == selected {
+				prefix = fmt.Sprintf("%s> %s", cli.ColorYellow, cli.ColorReset)
+				lineColorStart = cli.ColorGreen + cli.ColorBold
+			}
+			sug, body := splitMessage(sug)
+			marker := ""
+			if body != "" {
+				marker = fmt.Sprintf(" (+%d lines)", strings.Count(body, "\n")+1)
+			}
+			if runeLen(sug)+runeLen(marker) > maxMsg {
+				sug = truncateRunes(sug, max(maxMsg-runeLen(marker), 10))
+			}
+			fmt.Printf("%s[%d] %s%s%s%s%s%s\n", prefix, (i+1)%10, lineColorStart, sug, cli.ColorReset, cli.ColorDim, marker, cli.ColorReset)
+		}
+		spin := cli.SpinnerFrames[frame%len(cli.SpinnerFrames)]
+		fmt.Printf("%s%s Receiving suggestions (%d/%d) – pick now with numbers or Enter, e to edit%s\n", cli.ColorDim, spin, len(suggestions), s.Expected, cli.ColorReset)
+		printed = len(suggestions) + 2
+	}
+	pick := func(i int) (string, error) {
+		s.Stop()
+		clearBlock()
+		restore()
+		return suggestions[i], nil
+	}
+
+	render()
+	in := make([]byte, 3)
+	for {
+		// Drain any suggestions that arrived since the last tick.
+		finished := false
+	drain:
+		for {
+			select {
+			case u, ok := <-s.C:
+				if !ok {
+					finished = true
+					break drain
+				}
+				// Retracted suggestions disappear with the next render.
+				if u.Retract || len(suggestions) < 10 {
+					suggestions = u.apply(suggestions)
+				}
+				if selected >= len(suggestions) && selected > 0 {
+					selected = len(suggestions) - 1
+				}
+			default:
+				break drain
+			}
+		}
+		if finished {
+			clearBlock()
+			restore()
+			if err := s.Err(); err != nil {
+				if len(suggestions) == 0 || ctx.Err() != nil {
+					return "", err
+				}
+				fmt.Fprintf(os.Stderr, "%s%s Generation stopped early: %v%s\n", cli.ColorYellow, cli.IconInfo, err, cli.ColorReset)
+			}
+			return PromptUserSelect(ctx, s.cfg, s.apiKey, suggestions, s.Source())
+		}
+
+		n, err := os.Stdin.Read(in[:1])
+		if n == 0 || err != nil {
+			// Poll timeout (reported as EOF in this terminal mode): animate and keep waiting.
+			frame++
+			render()
+			continue
+		}
+		switch b := in[0]; b {
+		case 3: // Ctrl+C
+			s.Stop()
+			return "", errors.New("selection canceled")
+		case '\r', '\n':
+			if len(suggestions) > 0 {
+				return pick(selected)
+			}
+		case 'e':
+			if len(suggestions) > 0 {
+				msg, _ := pick(selected)
+				return EditMessage(msg)
+			}
+		case 'k':
+			if selected > 0 {
+				selected--
+			}
+		case 'j':
+			if selected < len(suggestions)-1 {
+				selected++
+			}
+		case 27: // ESC sequence
+			if n, _ := os.Stdin.Read(in[1:2]); n == 0 || in[1] != '[' {
+				break
+			}
+			if n, _ := os.Stdin.Read(in[2:3]); n == 0 {
+				break
+			}
+			switch in[2] {
+			case 'A':
+				if selected > 0 {
+					selected--
+				}
+			case 'B':
+				if selected < len(suggestions)-1 {
+					selected++
+				}
+			}
+		default:
+			if b >= '1' && b <= '9' {
+				if v := int(b - '0'); v <= len(suggestions) {
+					return pick(v - 1)
+				}
+			} else if b == '0' && len(suggestions) == 10 {
+				return pick(9)
+			}
+		}
+		render()
+	}
+}
+
+// scanPick prints suggestions under title and reads a choice with Scanln,
+// defaulting to the first one. It is the fallback when the terminal cannot be
+// switched back to cbreak mode.
+func scanPick(title string, suggestions []string) string {
+	fmt.Printf("%s%s %s%s:%s\n", cli.ColorGray, cli.ColorBold, cli.IconInfo, title, cli.ColorReset)
+	printSuggestionList(suggestions)
+	fmt.Printf("\n%s%s Choose a commit message [default: 1]: %s", cli.ColorBold, cli.IconPrompt, cli.ColorCyan)
+	var choiceInput string
+	fmt.Scanln(&choiceInput)
+	fmt.Printf("%s", cli.ColorReset)
+	if v, err := strconv.Atoi(choiceInput); err == nil && v >= 1 && v <= len(suggestions) {
+		return suggestions[v-1]
+	}
+	return suggestions[0]
+}
+
+// printSuggestionList prints numbered suggestions for the line-based prompts,
+// with any body indented under its subject.
+func printSuggestionList(suggestions []string) {
+	for i, s := range suggestions {
+		subject, body := splitMessage(s)
+		fmt.Printf("  %s[%d]%s %s%s%s\n", cli.ColorYellow, i+1, cli.ColorReset, cli.ColorCyan, subject, cli.ColorReset)
+		if body == "" {
+			continue
+		}
+		for _, ln := range strings.Split(body, "\n") {
+			fmt.Printf("      %s%s%s\n", cli.ColorDim, ln, cli.ColorReset)
+		}
+	}
+}
+
+// hasBody reports whether any suggestion has a body.
+func hasBody(suggestions []string) bool {
+	for _, s := range suggestions {
+		if _, body := splitMessage(s); body != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestionsTitle returns the selector header text, naming source when known.
+func suggestionsTitle(source string) string {
+	if source == "" {
+		return "Commit message suggestions"
+	}
+	return "Commit message suggestions from " + source
+}
+
 // enableCBreak switches terminal to non-canonical, no-echo mode using `stty` and returns a restore func.
 func enableCBreak() (func(), error) {
+	return enableCBreakMode("1", "0")
+}
+
+// enableCBreakMode is enableCBreak with explicit stty "min" and "time" values.
+// min=0 time=N makes reads return after N tenths of a second without input.
+func enableCBreakMode(minBytes, tenths string) (func(), error) {
 	// Save current settings
 	save := exec.Command("stty", "-g")
 	save.Stdin = os.Stdin
@@ -303,21 +585,25 @@ func enableCBreak() (func(), error) {
 		return func() {}, err
 	}
 	// Set to cbreak (non-canonical) and no-echo, return after 1 byte
-	set := exec.Command("stty", "-icanon", "-echo", "min", "1", "time", "0")
+	set := exec.Command("stty", "-icanon", "-echo", "min", minBytes, "time", tenths)
 	set.Stdin = os.Stdin
 	if err := set.Run(); err != nil {
 		return func() {}, err
 	}
-	restored := false
+	var once sync.Once
 	restore := func() {
-		if restored {
-			return
-		}
-		restored = true
-		cmd := exec.Command("stty", strings.TrimSpace(string(state)))
-		cmd.Stdin = os.Stdin
-		_ = cmd.Run()
+		once.Do(func() {
+			activeRestoreMu.Lock()
+			activeRestore = nil
+			activeRestoreMu.Unlock()
+			cmd := exec.Command("stty", strings.TrimSpace(string(state)))
+			cmd.Stdin = os.Stdin
+			_ = cmd.Run()
+		})
 	}
+	activeRestoreMu.Lock()
+	activeRestore = restore
+	activeRestoreMu.Unlock()
 	return restore, nil
 }
 
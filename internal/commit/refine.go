@@ -0,0 +1,138 @@
+package commit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+// Refiner regenerates suggestions from free-text feedback ("make it shorter").
+// It keeps the conversation (staged diff, suggestions so far and every piece
+// of feedback) so that later refinements build on earlier ones.
+type Refiner struct {
+	cfg      Config
+	hop      providerHop
+	p        provider.Provider
+	budget   tokens.Budget
+	messages []openai.Message
+	// current holds the suggestions of the latest round.
+	current []string
+}
+
+// NewRefiner starts a refinement conversation for the staged diff, in which
+// suggestions were the model's first answer, with cfg.Provider.
+func NewRefiner(ctx context.Context, cfg Config, apiKey string, suggestions []string) (*Refiner, error) {
+	return newRefiner(ctx, cfg, primaryHop(cfg, apiKey), suggestions)
+}
+
+// newRefiner implements NewRefiner with the provider of hop h, e.g. the
+// fallback that produced suggestions.
+func newRefiner(ctx context.Context, cfg Config, h providerHop, suggestions []string) (*Refiner, error) {
+	r := &Refiner{cfg: cfg, hop: h, current: suggestions}
+	if config.Bool(config.EnvAICMock) {
+		r.hop = providerHop{name: "mock"}
+		return r, nil
+	}
+	var err error
+	if r.p, err = r.hop.provider(); err != nil {
+		return nil, err
+	}
+	gitDiff, findings, err := stagedDiff(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(findings) > 0 && AbortOnSecrets() {
+		return nil, secretsError(findings)
+	}
+	systemMsg := suggestionPrompt(cfg) +
+		" The user may then give feedback on your suggestions; answer each time with a fresh set of options in exactly the same output format, applying all feedback given so far."
+	userContent, budget := diffPrompt(ctx, r.p, r.hop, cfg, systemMsg, gitDiff)
+	r.budget = budget
+	r.messages = []openai.Message{
+		{Role: "system", Content: systemMsg},
+		{Role: "user", Content: userContent},
+		{Role: "assistant", Content: r.transcript(suggestions)},
+	}
+	return r, nil
+}
+
+// Source labels the provider answering refinements, for the selector header.
+func (r *Refiner) Source() string { return r.hop.label() }
+
+// Refine sends feedback as the next turn of the conversation and returns the
+// regenerated suggestions. A failed round leaves the conversation unchanged.
+func (r *Refiner) Refine(ctx context.Context, feedback string) ([]string, error) {
+	feedback = strings.TrimSpace(feedback)
+	if feedback == "" {
+		return nil, errors.New("empty feedback")
+	}
+	turn := openai.Message{Role: "user", Content: "Revise the commit messages: " + feedback + "\n" +
+		"Reply with exactly " + strconv.Itoa(r.cfg.Suggestions) + " options in the same format as before."}
+	if config.Bool(config.EnvAICMock) {
+		out := make([]string, len(r.current))
+		for i, s := range r.current {
+			subject, body := splitMessage(s)
+			out[i] = strings.TrimSpace(subject + " (" + feedback + ")\n\n" + body)
+		}
+		r.messages = append(r.messages, turn, openai.Message{Role: "assistant", Content: r.transcript(out)})
+		r.current = out
+		return out, nil
+	}
+	messages := r.fit(append(append([]openai.Message{}, r.messages...), turn))
+	temp := float32(0.4)
+	req := openai.ChatCompletionRequest{
+		Model:       r.hop.model,
+		Messages:    messages,
+		MaxTokens:   r.budget.Output,
+		N:           r.cfg.Suggestions,
+		Temperature: &temp,
+	}
+	debugTokens("refine", req, r.budget.Window)
+	resp, err := r.p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := r.cfg.parseChoices(resp.Choices)
+	if len(suggestions) == 0 {
+		errMsg := "empty suggestions after refining"
+		if config.Bool(config.EnvAICDebug) && resp.Raw != "" {
+			errMsg = fmt.Sprintf("%s\n\nRaw Response:\n%s", errMsg, resp.Raw)
+		}
+		return nil, errors.New(errMsg)
+	}
+	if len(suggestions) > r.cfg.Suggestions {
+		suggestions = suggestions[:r.cfg.Suggestions]
+	}
+	r.messages = append(messages, openai.Message{Role: "assistant", Content: r.transcript(suggestions)})
+	r.current = suggestions
+	return suggestions, nil
+}
+
+// fit drops the oldest feedback rounds (never the diff, the first answer or
+// the new feedback) until messages fit the input budget.
+func (r *Refiner) fit(messages []openai.Message) []openai.Message {
+	for len(messages) > 4 && estimateInput(openai.ChatCompletionRequest{Messages: messages}) > r.budget.Input() {
+		messages = append(messages[:3], messages[5:]...)
+	}
+	return messages
+}
+
+// transcript renders suggestions the way the model was asked to answer, so
+// the conversation history stays consistent with the output format.
+func (r *Refiner) transcript(suggestions []string) string {
+	if !r.cfg.Body {
+		return strings.Join(suggestions, "\n")
+	}
+	lines := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		lines[i] = bodyLine(s)
+	}
+	return strings.Join(lines, "\n")
+}
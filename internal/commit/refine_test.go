@@ -0,0 +1,91 @@
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRefinerKeepsConversationAcrossRounds(t *testing.T) {
+	stageInTempRepo(t)
+	t.Setenv("AIC_NO_CACHE", "1")
+	var rounds [][]struct{ Role, Content string }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Role, Content string } `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		rounds = append(rounds, req.Messages)
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":"feat: refined %d"},"done":true}`, len(rounds))
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	cfg := Config{Provider: "ollama", Model: "llama3", Suggestions: 1}
+	r, err := NewRefiner(context.Background(), cfg, "", []string{"feat: add main package"})
+	if err != nil {
+		t.Fatalf("NewRefiner: %v", err)
+	}
+	for i, feedback := range []string{"make it shorter", "mention the entry point"} {
+		got, err := r.Refine(context.Background(), feedback)
+		if want := fmt.Sprintf("feat: refined %d", i+1); err != nil || len(got) != 1 || got[0] != want {
+			t.Fatalf("round %d: got %v, %v", i+1, got, err)
+		}
+	}
+	if len(rounds) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(rounds))
+	}
+	second := rounds[1]
+	roles := make([]string, len(second))
+	for i, m := range second {
+		roles[i] = m.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user,assistant,user" {
+		t.Fatalf("unexpected conversation: %s", got)
+	}
+	if !strings.Contains(second[1].Content, "main.go") {
+		t.Errorf("diff missing from conversation: %q", second[1].Content)
+	}
+	if second[2].Content != "feat: add main package" || second[4].Content != "feat: refined 1" {
+		t.Errorf("previous answers missing: %q, %q", second[2].Content, second[4].Content)
+	}
+	if !strings.Contains(second[3].Content, "make it shorter") || !strings.Contains(second[5].Content, "mention the entry point") {
+		t.Errorf("feedback missing: %q, %q", second[3].Content, second[5].Content)
+	}
+}
+
+func TestRefinerBodyModeTranscript(t *testing.T) {
+	r := &Refiner{cfg: Config{Body: true}}
+	got := r.transcript([]string{"feat: a\n\n- first bullet that is long enough to have been wrapped at seventy-two\n  columns\n- second"})
+	want := `{"subject":"feat: a","body":["first bullet that is long enough to have been wrapped at seventy-two columns","second"]}`
+	if got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+	if back, ok := parseBodyLine(got); !ok || !strings.HasPrefix(back, "feat: a\n\n- first bullet") {
+		t.Fatalf("transcript does not round-trip: %q", back)
+	}
+}
+
+func TestRefinerMock(t *testing.T) {
+	t.Setenv("AIC_MOCK", "1")
+	r, err := NewRefiner(context.Background(), Config{Suggestions: 1}, "", []string{"feat: mock change"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Refine(context.Background(), "shorter"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Refine(context.Background(), "mention tests")
+	if err != nil || len(got) != 1 || got[0] != "feat: mock change (shorter) (mention tests)" {
+		t.Fatalf("later rounds should build on earlier ones, got %v, %v", got, err)
+	}
+	if _, err := r.Refine(context.Background(), "  "); err == nil {
+		t.Fatal("expected an error for empty feedback")
+	}
+}
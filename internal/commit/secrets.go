@@ -0,0 +1,89 @@
+package commit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/redact"
+)
+
+// ErrSecrets is returned when likely secrets are staged and the user (or
+// AIC_SECRETS=abort) chose not to continue.
+var ErrSecrets = errors.New("staged changes contain likely secrets")
+
+// stagedDiff returns the staged diff as it is sent to providers: files
+// excluded by cfg.DiffFilter reduced to stat lines and likely secrets
+// replaced by placeholders. It also returns what was redacted.
+func stagedDiff(cfg Config) (string, []redact.Finding, error) {
+	diff, err := git.StagedDiffFrom(cfg.Situation.Base)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return "", nil, errors.New("no staged changes")
+	}
+	diff, excluded := git.FilterDiff(diff, cfg.DiffFilter())
+	if len(excluded) > 0 && config.Bool(config.EnvAICDebug) {
+		fmt.Fprintf(os.Stderr, "[aic][debug] sending stats only for: %s\n", strings.Join(excluded, ", "))
+	}
+	diff, findings := cfg.Redactor.Diff(diff)
+	return diff, findings, nil
+}
+
+// ScanSecrets returns the likely secrets that will be redacted from the
+// staged diff before it is sent.
+func ScanSecrets(cfg Config) ([]redact.Finding, error) {
+	_, findings, err := stagedDiff(cfg)
+	return findings, err
+}
+
+// AbortOnSecrets reports whether AIC_SECRETS=abort asks to stop instead of
+// sending a redacted diff.
+func AbortOnSecrets() bool {
+	return strings.EqualFold(strings.TrimSpace(config.Get(config.EnvAICSecrets)), "abort")
+}
+
+// ConfirmSecrets warns about redacted secrets and their locations. It returns
+// ErrSecrets when AIC_SECRETS=abort is set or the user declines to continue;
+// without a terminal (or with AIC_NON_INTERACTIVE=1) it continues.
+func ConfirmSecrets(findings []redact.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s%s Likely secrets in staged changes%s %s(replaced by placeholders before sending)%s\n", cli.ColorYellow, cli.ColorBold, cli.ColorReset, cli.ColorDim, cli.ColorReset)
+	for _, f := range findings {
+		fmt.Fprintf(os.Stderr, "  %s- %s%s %s%s%s\n", cli.ColorYellow, f.Location(), cli.ColorReset, cli.ColorDim, f.Detector, cli.ColorReset)
+	}
+	if AbortOnSecrets() {
+		return secretsError(findings)
+	}
+	if config.Bool(config.EnvAICNonInteractive) {
+		return nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || (fi.Mode()&os.ModeCharDevice) == 0 {
+		return nil
+	}
+	fmt.Printf("\n%s%s Continue with these values redacted?%s %s[Y|n]%s %s[default: Y]%s: %s",
+		cli.ColorBold, cli.IconPrompt, cli.ColorReset, cli.ColorYellow, cli.ColorReset, cli.ColorDim, cli.ColorReset, cli.ColorCyan)
+	var choice string
+	fmt.Scanln(&choice)
+	fmt.Printf("%s", cli.ColorReset)
+	if choice == "" || strings.EqualFold(choice, "y") {
+		return nil
+	}
+	return secretsError(findings)
+}
+
+// secretsError wraps ErrSecrets with the locations found.
+func secretsError(findings []redact.Finding) error {
+	locs := make([]string, 0, len(findings))
+	for _, f := range findings {
+		locs = append(locs, f.Location())
+	}
+	return fmt.Errorf("%w: %s", ErrSecrets, strings.Join(locs, ", "))
+}
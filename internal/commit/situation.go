@@ -0,0 +1,201 @@
+package commit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/lint"
+)
+
+// Kind is the kind of commit a message is written for.
+type Kind int
+
+const (
+	// Normal is a commit of the staged changes.
+	Normal Kind = iota
+	// Merge concludes a merge in progress (MERGE_HEAD exists).
+	Merge
+	// Squash records the result of git merge --squash (SQUASH_MSG exists).
+	Squash
+	// Amend replaces HEAD with HEAD plus the staged changes.
+	Amend
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Merge:
+		return "merge"
+	case Squash:
+		return "squash"
+	case Amend:
+		return "amend"
+	}
+	return "normal"
+}
+
+// Situation is what is known about the commit beyond the staged diff.
+type Situation struct {
+	Kind Kind
+	// Previous is the message of the commit being amended, or git's subject
+	// for a merge, e.g. "Merge branch 'dev'".
+	Previous string
+	// Commits are the messages of the commits being merged or squashed,
+	// oldest first.
+	Commits []string
+	// Base is the revision the index is diffed against; "" means HEAD.
+	// Amends use HEAD's parent, or the empty tree for a root commit.
+	Base string
+}
+
+// Per-message and total caps on the commit messages put into the prompt.
+const (
+	maxSituationMessage = 400
+	maxSituationChars   = 6000
+)
+
+// DetectSituation returns the situation of the next commit in the current
+// repository: an amend of HEAD when amend is set, otherwise a merge or a
+// squash merge in progress, or a normal commit.
+func DetectSituation(amend bool) (Situation, error) {
+	if amend {
+		return amendSituation()
+	}
+	if head, ok := git.Resolve("MERGE_HEAD"); ok {
+		s := Situation{Kind: Merge}
+		if text, ok := git.ReadState("MERGE_MSG"); ok {
+			s.Previous = mergeSubject(lint.Clean(text, git.CommentChar()))
+		}
+		if s.Previous == "" {
+			s.Previous = "Merge " + git.NameRev(head)
+		}
+		// Best effort: without HEAD (an unborn branch) there is no range.
+		s.Commits, _ = git.Messages("HEAD.." + head)
+		return s, nil
+	}
+	if text, ok := git.ReadState("SQUASH_MSG"); ok {
+		return Situation{Kind: Squash, Commits: parseSquashMessage(text)}, nil
+	}
+	return Situation{}, nil
+}
+
+func amendSituation() (Situation, error) {
+	if _, ok := git.Resolve("HEAD"); !ok {
+		return Situation{}, errors.New("nothing to amend: the branch has no commits yet")
+	}
+	prev, err := git.Message("HEAD")
+	if err != nil {
+		return Situation{}, err
+	}
+	base, ok := git.Resolve("HEAD~1")
+	if !ok {
+		if base, err = git.EmptyTree(); err != nil {
+			return Situation{}, err
+		}
+	}
+	return Situation{Kind: Amend, Previous: prev, Base: base}, nil
+}
+
+// mergeSubject returns the first line of git's merge message text when it is
+// git's own ("Merge ..."); "" when the user replaced it, e.g. with -m.
+func mergeSubject(text string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if !strings.HasPrefix(first, "Merge ") {
+		return ""
+	}
+	return strings.TrimSpace(first)
+}
+
+// parseSquashMessage returns the commit messages listed in the SQUASH_MSG
+// git merge --squash writes ("commit <id>" headers followed by the message
+// indented by four spaces, newest first), oldest first.
+func parseSquashMessage(text string) []string {
+	var msgs, cur []string
+	inCommit := false
+	flush := func() {
+		if m := strings.TrimSpace(strings.Join(cur, "\n")); inCommit && m != "" {
+			msgs = append(msgs, m)
+		}
+		cur = nil
+	}
+	for _, ln := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(ln, "commit "):
+			flush()
+			inCommit = true
+		case inCommit && strings.HasPrefix(ln, "    "):
+			cur = append(cur, ln[4:])
+		case inCommit && ln == "" && len(cur) > 0:
+			cur = append(cur, "")
+		}
+	}
+	flush()
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs
+}
+
+// Describe returns a one-line description for display; "" for a normal
+// commit.
+func (s Situation) Describe() string {
+	switch s.Kind {
+	case Merge:
+		return s.Previous + " (" + plural(len(s.Commits), "commit") + ")"
+	case Squash:
+		return "Squashing " + plural(len(s.Commits), "commit")
+	case Amend:
+		return "Amending the last commit"
+	}
+	return ""
+}
+
+// prompt returns the system prompt addition explaining s to the model.
+func (s Situation) prompt() string {
+	switch s.Kind {
+	case Merge:
+		p := "This is a merge commit. Start every subject with \"" + s.Previous + ": \" followed by a summary of what the merged branch brings in, instead of a type and scope."
+		if len(s.Commits) > 0 {
+			p += " The merged branch has these commits, oldest first:\n" + commitList(s.Commits)
+		}
+		return p
+	case Squash:
+		p := "These staged changes squash several commits into one. Write one message summarising them as a whole: the commit messages explain why, the diff confirms what. Do not list the commits one by one."
+		if len(s.Commits) > 0 {
+			p += " The squashed commits, oldest first:\n" + commitList(s.Commits)
+		}
+		return p
+	case Amend:
+		return "This message replaces the one of a commit being amended; the diff covers the whole amended commit. " +
+			"Keep what is still accurate in the previous message and cover what changed. The previous message:\n" +
+			"---\n" + firstNRunes(s.Previous, maxSituationChars) + "\n---"
+	}
+	return ""
+}
+
+// commitList formats msgs for the prompt, separated by "---" lines and cut
+// to maxSituationChars, dropping the oldest first.
+func commitList(msgs []string) string {
+	parts := make([]string, 0, len(msgs))
+	total := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := firstNRunes(msgs[i], maxSituationMessage)
+		if total+len(m) > maxSituationChars && len(parts) > 0 {
+			break
+		}
+		total += len(m)
+		parts = append(parts, m)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return "---\n" + strings.Join(parts, "\n---\n") + "\n---"
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
@@ -0,0 +1,154 @@
+package commit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/gittest"
+)
+
+// commitFile writes name and commits it with msg.
+func commitFile(t *testing.T, name, content, msg string) {
+	t.Helper()
+	gittest.Write(t, map[string]string{name: content})
+	gittest.Run(t, "add", name)
+	gittest.Run(t, "commit", "-q", "-m", msg)
+}
+
+// historyInTempRepo is stageInTempRepo plus a first commit on branch main.
+func historyInTempRepo(t *testing.T) {
+	t.Helper()
+	stageInTempRepo(t)
+	gittest.Run(t, "checkout", "-q", "-b", "main")
+	gittest.Run(t, "commit", "-q", "-m", "chore: initial commit")
+}
+
+func TestParseSquashMessage(t *testing.T) {
+	text := "Squashed commit of the following:\n\n" +
+		"commit 2222\nAuthor: a <a@b>\nDate:   today\n\n    fix: five\n\n" +
+		"commit 1111\nAuthor: a <a@b>\nDate:   today\n\n    feat: four\n\n    Body of four.\n    Second line.\n\n" +
+		"# Please enter the commit message\n"
+	want := []string{"feat: four\n\nBody of four.\nSecond line.", "fix: five"}
+	if got := parseSquashMessage(text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseSquashMessage = %q, want %q", got, want)
+	}
+	if got := parseSquashMessage("feat: edited by hand\n"); len(got) != 0 {
+		t.Fatalf("expected no commits, got %q", got)
+	}
+}
+
+func TestDetectSituationAmend(t *testing.T) {
+	historyInTempRepo(t)
+	commitFile(t, "a.go", "package a\n", "feat: add a\n\nFirst version.")
+	gittest.Write(t, map[string]string{"b.go": "package b\n"})
+	gittest.Run(t, "add", "b.go")
+
+	s, err := DetectSituation(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Kind != Amend || s.Previous != "feat: add a\n\nFirst version." || s.Base == "" {
+		t.Fatalf("unexpected situation: %+v", s)
+	}
+	diff, _, err := stagedDiff(Config{Situation: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The amended commit's own change and the staged one, not main.go.
+	if !strings.Contains(diff, "a.go") || !strings.Contains(diff, "b.go") || strings.Contains(diff, "main.go") {
+		t.Fatalf("amend diff should cover a.go and b.go only:\n%s", diff)
+	}
+}
+
+func TestDetectSituationAmendRootCommit(t *testing.T) {
+	historyInTempRepo(t)
+	s, err := DetectSituation(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, _, err := stagedDiff(Config{Situation: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "main.go") {
+		t.Fatalf("root amend should diff against the empty tree:\n%s", diff)
+	}
+}
+
+func TestDetectSituationMergeAndSquash(t *testing.T) {
+	historyInTempRepo(t)
+	gittest.Run(t, "checkout", "-q", "-b", "dev")
+	commitFile(t, "a.go", "package a\n", "feat: add a")
+	commitFile(t, "b.go", "package b\n", "fix: handle b")
+	gittest.Run(t, "checkout", "-q", "main")
+	commitFile(t, "c.go", "package c\n", "docs: add c")
+
+	if s, err := DetectSituation(false); err != nil || s.Kind != Normal {
+		t.Fatalf("expected a normal commit, got %+v, %v", s, err)
+	}
+
+	gittest.Run(t, "merge", "-q", "--no-ff", "--no-commit", "dev")
+	s, err := DetectSituation(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"feat: add a", "fix: handle b"}
+	if s.Kind != Merge || s.Previous != "Merge branch 'dev'" || !reflect.DeepEqual(s.Commits, want) {
+		t.Fatalf("unexpected merge situation: %+v", s)
+	}
+	if got := s.Describe(); got != "Merge branch 'dev' (2 commits)" {
+		t.Fatalf("Describe = %q", got)
+	}
+	gittest.Run(t, "merge", "--abort")
+
+	gittest.Run(t, "merge", "-q", "--squash", "dev")
+	s, err = DetectSituation(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Kind != Squash || !reflect.DeepEqual(s.Commits, want) {
+		t.Fatalf("unexpected squash situation: %+v", s)
+	}
+}
+
+func TestSituationPrompt(t *testing.T) {
+	cfg := Config{Suggestions: 2, Situation: Situation{Kind: Amend, Previous: "fix: old subject"}}
+	if p := suggestionPrompt(cfg); !strings.Contains(p, "amended") || !strings.Contains(p, "fix: old subject") {
+		t.Fatalf("amend prompt lacks the previous message:\n%s", p)
+	}
+	cfg.Situation = Situation{Kind: Squash, Commits: []string{"feat: one", "fix: two"}}
+	if p := suggestionPrompt(cfg); !strings.Contains(p, "feat: one\n---\nfix: two") {
+		t.Fatalf("squash prompt lacks the commits:\n%s", p)
+	}
+	cfg.Situation = Situation{Kind: Merge, Previous: "Merge branch 'dev'"}
+	if p := suggestionPrompt(cfg); !strings.Contains(p, `"Merge branch 'dev': "`) {
+		t.Fatalf("merge prompt lacks git's subject:\n%s", p)
+	}
+	if p := suggestionPrompt(Config{Suggestions: 2}); strings.Contains(p, "---") {
+		t.Fatalf("normal prompt should have no situation:\n%s", p)
+	}
+}
+
+func TestCommitListKeepsNewest(t *testing.T) {
+	msgs := make([]string, 40)
+	for i := range msgs {
+		msgs[i] = strings.Repeat("x", maxSituationMessage)
+	}
+	msgs[len(msgs)-1] = "feat: newest"
+	got := commitList(msgs)
+	if len(got) > maxSituationChars+40*5 || !strings.HasSuffix(got, "feat: newest\n---") {
+		t.Fatalf("commitList should cut the oldest messages, got %d chars", len(got))
+	}
+}
+
+func TestLintSuggestionAcceptsMergeSubjects(t *testing.T) {
+	sug := "Merge branch 'dev': add login and session handling"
+	if _, ok := (Config{}).lintSuggestion(sug); ok {
+		t.Fatal("merge subject outside a merge should fail the rules")
+	}
+	cfg := Config{Situation: Situation{Kind: Merge, Previous: "Merge branch 'dev'"}}
+	if got, ok := cfg.lintSuggestion(sug); !ok || got != sug {
+		t.Fatalf("lintSuggestion = %q, %v", got, ok)
+	}
+}
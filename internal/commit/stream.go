@@ -0,0 +1,241 @@
+package commit
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/diesi/aic/internal/cli"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/provider"
+)
+
+// SuggestionStream delivers commit message suggestions as they are generated.
+// C receives each suggestion once its line is complete, and the withdrawal of
+// suggestions whose provider call failed later on. It is closed when
+// generation ends; Err then reports the outcome.
+type SuggestionStream struct {
+	C <-chan Update
+	// Expected is the number of suggestions requested.
+	Expected int
+
+	done   chan error
+	err    error
+	waited bool
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	source providerHop
+
+	// cfg and apiKey are kept for combining and refining in the selector.
+	cfg    Config
+	apiKey string
+}
+
+// Update is one change to the streamed suggestions.
+type Update struct {
+	Suggestion string
+	// Retract withdraws Suggestion, sent earlier: the call that produced it
+	// failed and its output is not part of the result.
+	Retract bool
+}
+
+// apply returns sugs with u applied.
+func (u Update) apply(sugs []string) []string {
+	if !u.Retract {
+		return append(sugs, u.Suggestion)
+	}
+	for i := len(sugs) - 1; i >= 0; i-- {
+		if sugs[i] == u.Suggestion {
+			return append(sugs[:i], sugs[i+1:]...)
+		}
+	}
+	return sugs
+}
+
+// StreamSuggestions starts generating suggestions for the staged diff in the
+// background, streaming provider output so each suggestion can be shown early.
+func StreamSuggestions(ctx context.Context, cfg Config, apiKey string) *SuggestionStream {
+	ctx, cancel := context.WithCancel(ctx)
+	c := make(chan Update, cfg.Suggestions)
+	s := &SuggestionStream{C: c, Expected: cfg.Suggestions, done: make(chan error, 1), cancel: cancel, cfg: cfg, apiKey: apiKey}
+	go func() {
+		_, source, err := generateSuggestions(ctx, cfg, apiKey, func(source providerHop, u Update) {
+			s.setSource(source)
+			select {
+			case c <- u:
+			case <-ctx.Done():
+			}
+		})
+		if err == nil {
+			s.setSource(source)
+		}
+		close(c)
+		s.done <- err
+	}()
+	return s
+}
+
+// Source returns the provider producing the suggestions, or the zero Source
+// before the first one has arrived.
+func (s *SuggestionStream) Source() Source {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Source{s.source}
+}
+
+func (s *SuggestionStream) setSource(source providerHop) {
+	s.mu.Lock()
+	s.source = source
+	s.mu.Unlock()
+}
+
+// Stop cancels generation (e.g., once the user picked a suggestion early).
+func (s *SuggestionStream) Stop() { s.cancel() }
+
+// Err blocks until generation has finished and returns its error, if any.
+// It must only be called after C has been closed.
+func (s *SuggestionStream) Err() error {
+	if !s.waited {
+		s.err = <-s.done
+		s.waited = true
+	}
+	return s.err
+}
+
+// Wait drains the stream and returns all suggestions.
+func (s *SuggestionStream) Wait() ([]string, error) {
+	var out []string
+	for u := range s.C {
+		out = u.apply(out)
+	}
+	return out, s.Err()
+}
+
+// StreamingEnabled reports whether suggestions should be streamed into the
+// interactive selector: stdin must be a terminal, and neither
+// AIC_NON_INTERACTIVE nor AIC_NO_STREAM may be set.
+func StreamingEnabled() bool {
+	if config.Bool(config.EnvAICNoStream) || config.Bool(config.EnvAICNonInteractive) {
+		return false
+	}
+	fi, err := os.Stdin.Stat()
+	return err == nil && (fi.Mode()&os.ModeCharDevice) != 0
+}
+
+// lineAssembler turns streamed content fragments into suggestions, one per
+// completed non-empty line, applying the same cleanup as parseSuggestionChoices.
+// With parse set (body mode), each line is decoded by parse instead and lines
+// it rejects are dropped.
+type lineAssembler struct {
+	limit int
+	emit  func(string)
+	// retract, when set, is called for each emitted suggestion of a choice
+	// the provider dropped (see provider.Delta).
+	retract func(string)
+	parse   func(line string) (string, bool)
+	// check, when set, fixes each parsed suggestion or rejects it.
+	check func(sug string) (string, bool)
+	buf   map[int]string
+	out   []string
+	// from holds the choice each suggestion in out came from; -1 for kept ones.
+	from []int
+}
+
+// onDelta adds a streamed event from the provider.
+func (a *lineAssembler) onDelta(d provider.Delta) {
+	if d.Dropped {
+		a.drop(d.Choice)
+		return
+	}
+	a.add(d.Choice, d.Text)
+}
+
+func (a *lineAssembler) add(choice int, delta string) {
+	if a.buf == nil {
+		a.buf = map[int]string{}
+	}
+	b := a.buf[choice] + delta
+	for {
+		i := strings.IndexByte(b, '\n')
+		if i < 0 {
+			break
+		}
+		a.push(choice, b[:i])
+		b = b[i+1:]
+	}
+	a.buf[choice] = b
+}
+
+// drop removes the suggestions of choice, retracting those already emitted.
+func (a *lineAssembler) drop(choice int) {
+	delete(a.buf, choice)
+	out, from := a.out[:0], a.from[:0]
+	var dropped []string
+	for i, sug := range a.out {
+		if a.from[i] == choice {
+			dropped = append(dropped, sug)
+			continue
+		}
+		out, from = append(out, sug), append(from, a.from[i])
+	}
+	a.out, a.from = out, from
+	if a.retract != nil {
+		for _, sug := range dropped {
+			a.retract(sug)
+		}
+	}
+}
+
+// flush emits any trailing partial lines, in choice order.
+func (a *lineAssembler) flush() {
+	keys := make([]int, 0, len(a.buf))
+	for k := range a.buf {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for _, k := range keys {
+		a.push(k, a.buf[k])
+		delete(a.buf, k)
+	}
+}
+
+func (a *lineAssembler) push(choice int, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (a.limit > 0 && len(a.out) >= a.limit) {
+		return
+	}
+	if a.parse != nil {
+		var ok bool
+		if line, ok = a.parse(line); !ok {
+			return
+		}
+	} else {
+		line = cli.StripLeadingListMarker(line)
+	}
+	if a.check != nil {
+		var ok bool
+		if line, ok = a.check(line); !ok {
+			return
+		}
+	}
+	a.record(choice, line)
+}
+
+// keep records an already parsed suggestion and passes it to emit.
+func (a *lineAssembler) keep(sug string) {
+	a.record(-1, sug)
+}
+
+func (a *lineAssembler) record(choice int, sug string) {
+	if sug == "" || (a.limit > 0 && len(a.out) >= a.limit) {
+		return
+	}
+	a.out = append(a.out, sug)
+	a.from = append(a.from, choice)
+	if a.emit != nil {
+		a.emit(sug)
+	}
+}
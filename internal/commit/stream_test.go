@@ -0,0 +1,60 @@
+package commit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/diesi/aic/internal/provider"
+)
+
+func TestLineAssemblerEmitsCompletedLines(t *testing.T) {
+	var emitted []string
+	a := &lineAssembler{limit: 3, emit: func(s string) { emitted = append(emitted, s) }}
+	a.add(0, "1. feat: add")
+	a.add(1, "fix: handle nil\n- docs: upd")
+	if len(emitted) != 1 || emitted[0] != "fix: handle nil" {
+		t.Fatalf("expected only the completed line so far, got %v", emitted)
+	}
+	a.add(0, " parser\n")
+	a.add(1, "ate readme")
+	a.flush()
+	want := []string{"fix: handle nil", "feat: add parser", "docs: update readme"}
+	if len(a.out) != len(want) {
+		t.Fatalf("got %v, want %v", a.out, want)
+	}
+	for i := range want {
+		if a.out[i] != want[i] || emitted[i] != want[i] {
+			t.Fatalf("got %v / %v, want %v", a.out, emitted, want)
+		}
+	}
+}
+
+func TestLineAssemblerRetractsDroppedChoice(t *testing.T) {
+	var sugs []string
+	a := &lineAssembler{limit: 3}
+	a.emit = func(s string) { sugs = Update{Suggestion: s}.apply(sugs) }
+	a.retract = func(s string) { sugs = Update{Suggestion: s, Retract: true}.apply(sugs) }
+	a.add(0, "feat: add parser\n")
+	a.add(1, "fix: partial line\nfix: unfin")
+	a.add(2, "docs: update readme\n")
+	a.onDelta(provider.Delta{Choice: 1, Dropped: true})
+	a.add(0, "refactor: split lexer\n")
+	a.flush()
+	want := []string{"feat: add parser", "docs: update readme", "refactor: split lexer"}
+	if !reflect.DeepEqual(a.out, want) || !reflect.DeepEqual(sugs, want) {
+		t.Fatalf("got %v / shown %v, want %v", a.out, sugs, want)
+	}
+}
+
+func TestStreamSuggestionsMockMode(t *testing.T) {
+	t.Setenv("AIC_MOCK", "1")
+	s := StreamSuggestions(context.Background(), Config{Suggestions: 2}, "")
+	got, err := s.Wait()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "feat: mock change" {
+		t.Fatalf("unexpected suggestions: %v", got)
+	}
+}
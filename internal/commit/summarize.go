@@ -0,0 +1,241 @@
+package commit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/diesi/aic/internal/cache"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+const (
+	// maxChunkTokens caps chunk size so large-context models still summarize
+	// a huge diff in parallel pieces rather than one slow call.
+	maxChunkTokens = 24000
+	// minChunkTokens keeps chunks useful on small-context models.
+	minChunkTokens = 1000
+	// summaryOverheadTokens reserves room for the system prompt and the
+	// summary itself when sizing chunks.
+	summaryOverheadTokens = 1024
+)
+
+const summarySystemPrompt = "You summarize git diffs. Produce a concise overview: list each file (max 1 line) with nature of change (add/remove/modify/rename) and highlight any: API signature changes, new public functions, deleted functions, dependency/version changes, security related changes, configuration changes. After the list, include a short 'Key Impacts:' section (<=3 bullet lines). No commit messages, no speculation."
+
+const reduceSystemPrompt = "You merge partial summaries of one large git diff into a single overview. Keep the same format: list each file (max 1 line) with nature of change and notable API/dependency/security/configuration changes; group many similar files into one line when needed. After the list, include one short 'Key Impacts:' section (<=3 bullet lines) for the whole change. No commit messages, no speculation."
+
+// summarizeDiff creates a concise structured summary of a very large diff.
+// It ALWAYS uses the providers default model (defaultModel constant) regardless of user override.
+// The output is intentionally compact: bullet-style high level file change descriptions + notable additions/removals.
+//
+// The diff is split per file (and per hunk for very large files) into chunks
+// sized from the model's context window. Chunks are summarized concurrently and
+// the partial summaries are reduced into one, so no part of the diff is
+// dropped. Chunks that fail to summarize are skipped.
+//
+// Summaries are cached separately from suggestions (keyed by provider, model
+// and diff) so that combine and regenerate rounds reuse them.
+func summarizeDiff(ctx context.Context, p provider.Provider, cfg Config, providerName, diff string) (string, error) {
+	model := provider.DefaultModel(providerName)
+	limit := chunkTokens(ContextWindow(cfg, providerName, model))
+	summaries := cache.Open("summaries")
+	key := cache.Key(providerName, model, strconv.Itoa(limit), diff)
+	var cached string
+	if summaries.Get(key, &cached) {
+		return cached, nil
+	}
+
+	chunks := summaryChunks(diff, limit)
+	if config.Bool(config.EnvAICDebug) {
+		fmt.Fprintf(os.Stderr, "[aic][debug] summarizing diff in %d chunk(s) of <=%d tokens\n", len(chunks), limit)
+	}
+	partials, err := mapSummaries(ctx, p, model, summarySystemPrompt, tokens.SummaryOutput, chunks)
+	if err != nil {
+		return "", err
+	}
+	out, err := reduceSummaries(ctx, p, model, partials, limit)
+	if err != nil {
+		return "", err
+	}
+	if out != "" {
+		_ = summaries.Put(key, out)
+	}
+	return out, nil
+}
+
+// chunkTokens returns the chunk size for summarizing with a model of the given
+// context window: half of it minus room for prompt and output, within sane
+// bounds.
+func chunkTokens(window int) int {
+	n := window/2 - summaryOverheadTokens
+	if n > maxChunkTokens {
+		n = maxChunkTokens
+	}
+	if n < minChunkTokens {
+		n = minChunkTokens
+	}
+	return n
+}
+
+// summaryChunks splits diff into chunks of at most limit estimated tokens.
+// Whole files are packed together where possible; files that do not fit are
+// split by hunk (repeating the file header), and single hunks that still do
+// not fit are truncated.
+func summaryChunks(diff string, limit int) []string {
+	files := git.SplitDiff(diff)
+	if len(files) == 0 {
+		return []string{firstNRunes(diff, tokens.Chars(limit))}
+	}
+	var units []string
+	for _, f := range files {
+		if whole := f.String(); tokens.Estimate(whole) <= limit {
+			units = append(units, whole)
+			continue
+		}
+		headerTokens := tokens.Estimate(f.Header)
+		room := limit - headerTokens - 16
+		if room < 64 {
+			room = 64
+		}
+		part, partTokens := f.Header, headerTokens
+		for _, h := range f.Hunks {
+			if tokens.Estimate(h) > room {
+				h = firstNRunes(h, tokens.Chars(room)) + "\n[hunk truncated]\n"
+			}
+			ht := tokens.Estimate(h)
+			if partTokens > headerTokens && partTokens+ht > limit {
+				units = append(units, part)
+				part, partTokens = f.Header, headerTokens
+			}
+			part += h
+			partTokens += ht
+		}
+		units = append(units, part)
+	}
+	return packChunks(units, limit, "")
+}
+
+// packChunks greedily concatenates units (joined by sep) into chunks of at
+// most limit estimated tokens.
+func packChunks(units []string, limit int, sep string) []string {
+	var chunks []string
+	var cur strings.Builder
+	curTokens := 0
+	for _, u := range units {
+		ut := tokens.Estimate(sep + u)
+		if cur.Len() > 0 && curTokens+ut > limit {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+			curTokens = 0
+		}
+		if cur.Len() > 0 {
+			cur.WriteString(sep)
+		}
+		cur.WriteString(u)
+		curTokens += ut
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+// mapSummaries summarizes chunks with the given system prompt concurrently
+// (bounded by AIC_CONCURRENCY) and returns the non-empty results in chunk
+// order. It fails only if every chunk failed.
+func mapSummaries(ctx context.Context, p provider.Provider, model, system string, maxTokens int, chunks []string) ([]string, error) {
+	results := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, config.IntInRange(config.EnvAICConcurrency, 4, 1, 10))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], errs[i] = summarizeOnce(ctx, p, model, system, chunk, maxTokens)
+		}(i, chunk)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var partials []string
+	var firstErr error
+	for i, r := range results {
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+		if r != "" {
+			partials = append(partials, r)
+		}
+	}
+	if len(partials) == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("empty summary response")
+		}
+		return nil, firstErr
+	}
+	return partials, nil
+}
+
+// reduceSummaries merges partial summaries into one. A single partial is
+// returned as is; groups too large for one call are reduced recursively.
+func reduceSummaries(ctx context.Context, p provider.Provider, model string, partials []string, limit int) (string, error) {
+	if len(partials) == 1 {
+		return partials[0], nil
+	}
+	labeled := make([]string, len(partials))
+	for i, s := range partials {
+		labeled[i] = "Part " + strconv.Itoa(i+1) + ":\n" + s
+	}
+	groups := packChunks(labeled, limit, "\n\n")
+	if len(groups) == 1 {
+		return summarizeOnce(ctx, p, model, reduceSystemPrompt, groups[0], tokens.ReduceOutput)
+	}
+	next, err := mapSummaries(ctx, p, model, reduceSystemPrompt, tokens.ReduceOutput, groups)
+	if err != nil {
+		return "", err
+	}
+	if len(next) >= len(partials) {
+		// No progress (e.g., a single oversized partial); stop here.
+		return strings.Join(next, "\n\n"), nil
+	}
+	return reduceSummaries(ctx, p, model, next, limit)
+}
+
+// summarizeOnce sends one summarization request and returns the trimmed text.
+func summarizeOnce(ctx context.Context, p provider.Provider, model, system, content string, maxTokens int) (string, error) {
+	// Light temperature for determinism
+	temp := float32(0.2)
+	resp, err := p.Chat(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: content},
+		},
+		MaxTokens:   maxTokens,
+		Temperature: &temp,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		return "", errors.New("empty summary response")
+	}
+	return strings.TrimSpace(resp.Choices[0]), nil
+}
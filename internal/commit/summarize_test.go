@@ -0,0 +1,116 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/provider"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+// summaryProvider answers every request with the names of the files mentioned
+// in the user message, so tests can check which files reached a summary.
+type summaryProvider struct {
+	mu      sync.Mutex
+	systems []string
+}
+
+func (p *summaryProvider) Chat(_ context.Context, req openai.ChatCompletionRequest) (*provider.CompletionResponse, error) {
+	p.mu.Lock()
+	p.systems = append(p.systems, req.Messages[0].Content)
+	p.mu.Unlock()
+	var names []string
+	for _, f := range strings.Fields(req.Messages[1].Content) {
+		if strings.HasPrefix(f, "file") && strings.HasSuffix(f, ".go") {
+			names = append(names, f)
+		}
+	}
+	return &provider.CompletionResponse{Choices: []string{strings.Join(names, " ")}}, nil
+}
+
+func (p *summaryProvider) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, _ provider.DeltaFunc) (*provider.CompletionResponse, error) {
+	return p.Chat(ctx, req)
+}
+
+func syntheticDiff(files, linesPerFile int) string {
+	var b strings.Builder
+	for i := 0; i < files; i++ {
+		name := fmt.Sprintf("file%03d.go", i)
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n@@ -1,%d +1,%d @@\n", name, name, name, name, linesPerFile, linesPerFile)
+		for j := 0; j < linesPerFile; j++ {
+			fmt.Fprintf(&b, "+line %d of %s with some padding text\n", j, name)
+		}
+	}
+	return b.String()
+}
+
+func TestSummaryChunksRespectLimitAndKeepFiles(t *testing.T) {
+	diff := syntheticDiff(40, 30)
+	limit := 2000
+	chunks := summaryChunks(diff, limit)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	joined := strings.Join(chunks, "")
+	for _, c := range chunks {
+		if n := tokens.Estimate(c); n > limit {
+			t.Fatalf("chunk of %d tokens exceeds limit %d", n, limit)
+		}
+	}
+	if joined != diff {
+		t.Fatalf("chunks do not reassemble the diff")
+	}
+}
+
+func TestSummaryChunksSplitsLargeFileByHunk(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/big.go b/big.go\n--- a/big.go\n+++ b/big.go\n")
+	for h := 0; h < 6; h++ {
+		fmt.Fprintf(&b, "@@ -%d,1 +%d,1 @@\n%s\n", h*100, h*100, "+"+strings.Repeat("x", 2000))
+	}
+	chunks := summaryChunks(b.String(), 1000)
+	if len(chunks) < 3 {
+		t.Fatalf("expected the file to be split by hunk, got %d chunk(s)", len(chunks))
+	}
+	for _, c := range chunks {
+		if !strings.HasPrefix(c, "diff --git a/big.go b/big.go\n") {
+			t.Fatalf("chunk does not repeat the file header: %q", c[:40])
+		}
+		if n := tokens.Estimate(c); n > 1000 {
+			t.Fatalf("chunk of %d tokens exceeds limit", n)
+		}
+	}
+}
+
+func TestSummarizeDiffMapReduceCoversEveryFile(t *testing.T) {
+	t.Setenv("AIC_NO_CACHE", "1")
+	// Ollama has no default model, so chunks are sized from the default
+	// context window and this ~60k-token diff needs many of them.
+	diff := syntheticDiff(200, 25)
+	p := &summaryProvider{}
+	got, err := summarizeDiff(context.Background(), p, Config{}, "ollama", diff)
+	if err != nil {
+		t.Fatalf("summarizeDiff: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if name := fmt.Sprintf("file%03d.go", i); !strings.Contains(got, name) {
+			t.Fatalf("summary misses %s", name)
+		}
+	}
+	var maps, reduces int
+	for _, s := range p.systems {
+		switch s {
+		case summarySystemPrompt:
+			maps++
+		case reduceSystemPrompt:
+			reduces++
+		}
+	}
+	if maps < 2 || reduces < 1 {
+		t.Fatalf("expected map and reduce calls, got %d map / %d reduce", maps, reduces)
+	}
+}
@@ -21,6 +21,23 @@ const (
 	EnvAICNonInteractive = "AIC_NON_INTERACTIVE"
 	EnvAICAutoCommit     = "AIC_AUTO_COMMIT"
     EnvAICNoColor        = "AIC_NO_COLOR"
+	// Disable streaming suggestions into the interactive selector
+	EnvAICNoStream = "AIC_NO_STREAM"
+	// Retry policy for rate limits / transient failures
+	EnvAICRetryMaxAttempts = "AIC_RETRY_MAX_ATTEMPTS"
+	EnvAICRetryMaxTime     = "AIC_RETRY_MAX_TIME"
+	// Parallel requests when a provider needs one call per suggestion
+	EnvAICConcurrency = "AIC_CONCURRENCY"
+	// On-disk response cache
+	EnvAICNoCache    = "AIC_NO_CACHE"
+	EnvAICCacheTTL   = "AIC_CACHE_TTL"    // hours
+	EnvAICCacheMaxMB = "AIC_CACHE_MAX_MB" // megabytes
+	// What to do when staged changes contain likely secrets: redact (default) or abort
+	EnvAICSecrets = "AIC_SECRETS"
+	// Suggest a subject plus body bullets instead of single lines
+	EnvAICBody = "AIC_BODY"
+	// Make the Git hooks installed by aic hook install do nothing
+	EnvAICSkipHook = "AIC_SKIP_HOOK"
     // Testing/advanced: disable reading repo-local .aic.json
     EnvAICDisableRepoConfig = "AIC_DISABLE_REPO_CONFIG"
 
@@ -38,26 +55,84 @@ const (
 	EnvCustomCompletionsPath     = "CUSTOM_COMPLETIONS_PATH"      // default: /v1/completions
 	EnvCustomEmbeddingsPath      = "CUSTOM_EMBEDDINGS_PATH"       // default: /v1/embeddings
 	EnvCustomModelsPath          = "CUSTOM_MODELS_PATH"           // default: /v1/models
+
+	// Azure OpenAI provider configuration
+	EnvAzureOpenAIAPIKey     = "AZURE_OPENAI_API_KEY"
+	EnvAzureOpenAIEndpoint   = "AZURE_OPENAI_ENDPOINT"    // e.g. https://<resource>.openai.azure.com
+	EnvAzureOpenAIDeployment = "AZURE_OPENAI_DEPLOYMENT"  // default: AIC_MODEL
+	EnvAzureOpenAIAPIVersion = "AZURE_OPENAI_API_VERSION" // default: 2024-10-21
+
+	// Ollama provider configuration
+	EnvOllamaHost      = "OLLAMA_HOST"       // default: http://127.0.0.1:11434
+	EnvOllamaKeepAlive = "OLLAMA_KEEP_ALIVE" // e.g. 5m, 1h, -1 (keep loaded)
+	EnvOllamaNumCtx    = "OLLAMA_NUM_CTX"    // context window (options.num_ctx)
+	EnvOllamaSeed      = "OLLAMA_SEED"       // options.seed for reproducible output
 )
 
+// Kind is the type of value an environment variable holds. The CLI flag
+// derived from a variable parses and validates its value accordingly.
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindBool
+	KindInt
+)
+
+// Var documents an environment variable for help output and flag generation.
+type Var struct {
+	Name string
+	Kind Kind
+	Help string
+}
+
+// CoreVars returns the core environment variables. Each one except the
+// secrets (see Secret) also has a CLI flag equivalent that takes precedence
+// over it. Keep descriptions concise and
+// include "required" where applicable so callers can highlight them.
+func CoreVars() []Var {
+	return []Var{
+		{EnvOpenAIAPIKey, KindString, "(required for provider=openai) OpenAI API key"},
+		{EnvClaudeAPIKey, KindString, "(required for provider=claude) Claude API key"},
+		{EnvGeminiAPIKey, KindString, "(required for provider=gemini) Gemini API key"},
+		{EnvCustomAPIKey, KindString, "(optional for provider=custom) API key if your server requires it"},
+		{EnvAzureOpenAIAPIKey, KindString, "(required for provider=azure) Azure OpenAI API key"},
+		{EnvAICModel, KindString, "(optional) Model [default depends on provider]"},
+		{EnvAICSuggestions, KindInt, "(optional) Suggestions count 1-10 [default: 5; non-interactive: 1]"},
+		{EnvAICBody, KindBool, "(optional) 1 to suggest a subject plus body bullets; 0 overrides .aic.json"},
+		{EnvAICProvider, KindString, "(optional) Provider [openai|claude|gemini|azure|custom|ollama]; comma-separate for a fallback chain, e.g. openai,claude (default: auto-detect from keys; priority openai>claude>gemini)"},
+		{EnvAICDebug, KindBool, "(optional) Set to 1 for raw response debug"},
+		{EnvAICMock, KindBool, "(optional) Set to 1 for mock suggestions (no API call)"},
+		{EnvAICNonInteractive, KindBool, "(optional) 1 to auto-select first suggestion & skip commit"},
+		{EnvAICAutoCommit, KindBool, "(optional) With NON_INTERACTIVE=1, also perform the commit"},
+		{EnvAICNoColor, KindBool, "(optional) Disable colored output"},
+		{EnvAICNoStream, KindBool, "(optional) 1 to wait for all suggestions instead of streaming them in"},
+		{EnvAICRetryMaxAttempts, KindInt, "(optional) Attempts per request on rate limits/5xx/network errors [default: 4]"},
+		{EnvAICRetryMaxTime, KindInt, "(optional) Total retry time budget in seconds [default: 90]"},
+		{EnvAICConcurrency, KindInt, "(optional) Parallel calls when one request per suggestion is needed (claude, custom) 1-10 [default: 4]"},
+		{EnvAICNoCache, KindBool, "(optional) 1 to bypass the response cache"},
+		{EnvAICCacheTTL, KindInt, "(optional) Response cache lifetime in hours [default: 24]"},
+		{EnvAICCacheMaxMB, KindInt, "(optional) Response cache size limit in MB [default: 20]"},
+		{EnvAICSecrets, KindString, "(optional) On likely secrets in the diff: redact (replace, warn, ask) or abort [default: redact]"},
+	}
+}
+
+// Secret reports whether env var name holds a credential, such as
+// OPENAI_API_KEY. Secrets have no CLI flag: a flag value would show up in
+// ps output and shell history.
+func Secret(name string) bool {
+	return strings.HasSuffix(name, "_API_KEY")
+}
+
 // HelpEnvRowsCore returns the core environment variables and their descriptions
-// for display in CLI help output. Keep descriptions concise and include
-// "required" where applicable so callers can highlight them.
+// for display in CLI help output.
 func HelpEnvRowsCore() [][2]string {
-    return [][2]string{
-		{EnvOpenAIAPIKey, "(required for provider=openai) OpenAI API key"},
-		{EnvClaudeAPIKey, "(required for provider=claude) Claude API key"},
-		{EnvGeminiAPIKey, "(required for provider=gemini) Gemini API key"},
-		{EnvCustomAPIKey, "(optional for provider=custom) API key if your server requires it"},
-		{EnvAICModel, "(optional) Model [default depends on provider]"},
-		{EnvAICSuggestions, "(optional) Suggestions count 1-10 [default: 5; non-interactive: 1]"},
-		{EnvAICProvider, "(optional) Provider [openai|claude|gemini|custom] (default: auto-detect from keys; priority openai>claude>gemini)"},
-		{EnvAICDebug, "(optional) Set to 1 for raw response debug"},
-		{EnvAICMock, "(optional) Set to 1 for mock suggestions (no API call)"},
-		{EnvAICNonInteractive, "(optional) 1 to auto-select first suggestion & skip commit"},
-		{EnvAICAutoCommit, "(optional) With NON_INTERACTIVE=1, also perform the commit"},
-        {EnvAICNoColor, "(optional) Disable colored output (same as --no-color)"},
-    }
+	vars := CoreVars()
+	rows := make([][2]string, 0, len(vars))
+	for _, v := range vars {
+		rows = append(rows, [2]string{v.Name, v.Help})
+	}
+	return rows
 }
 
 // HelpEnvRowsCustom returns the custom-provider specific environment variables
@@ -72,6 +147,27 @@ func HelpEnvRowsCustom() [][2]string {
 	}
 }
 
+// HelpEnvRowsAzure returns the azure-provider specific environment variables
+// and their descriptions for CLI help output.
+func HelpEnvRowsAzure() [][2]string {
+	return [][2]string{
+		{EnvAzureOpenAIEndpoint, "(azure) Resource endpoint, e.g. https://<resource>.openai.azure.com"},
+		{EnvAzureOpenAIDeployment, "(azure) Deployment name [default: AIC_MODEL]"},
+		{EnvAzureOpenAIAPIVersion, "(azure) API version [default: 2024-10-21]"},
+	}
+}
+
+// HelpEnvRowsOllama returns the ollama-provider specific environment variables
+// and their descriptions for CLI help output.
+func HelpEnvRowsOllama() [][2]string {
+	return [][2]string{
+		{EnvOllamaHost, "(ollama) Server address [default: http://127.0.0.1:11434]"},
+		{EnvOllamaKeepAlive, "(ollama) How long the model stays loaded, e.g. 5m or -1"},
+		{EnvOllamaNumCtx, "(ollama) Context window size (num_ctx) [default: model setting]"},
+		{EnvOllamaSeed, "(ollama) Sampling seed for reproducible suggestions"},
+	}
+}
+
 // Get returns the raw value for key (empty string if unset).
 func Get(key string) string { return os.Getenv(key) }
 
@@ -118,7 +214,10 @@ func WarnUnknownAICEnv() {
     known := map[string]struct{}{
         EnvAICModel: {}, EnvAICSuggestions: {}, EnvAICMock: {}, EnvAICDebug: {},
         EnvAICNonInteractive: {}, EnvAICAutoCommit: {}, EnvAICNoColor: {},
-        EnvAICProvider: {}, EnvAICDisableRepoConfig: {},
+        EnvAICProvider: {}, EnvAICDisableRepoConfig: {}, EnvAICNoStream: {},
+        EnvAICRetryMaxAttempts: {}, EnvAICRetryMaxTime: {}, EnvAICConcurrency: {},
+        EnvAICNoCache: {}, EnvAICCacheTTL: {}, EnvAICCacheMaxMB: {}, EnvAICSecrets: {},
+        EnvAICBody: {}, EnvAICSkipHook: {},
         // custom provider configuration keys
         EnvCustomBaseURL: {}, EnvCustomChatCompletionsPath: {}, EnvCustomCompletionsPath: {},
         EnvCustomEmbeddingsPath: {}, EnvCustomModelsPath: {}, EnvCustomAPIKey: {},
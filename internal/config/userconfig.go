@@ -7,13 +7,80 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/diesi/aic/internal/lint"
 )
 
 // UserConfig represents optional global configuration loaded from ~/.aic.json
 // Currently supports:
 //   - instructions: string appended to AI system prompts (team style presets)
+//   - providers: provider fallback chain, e.g. ["openai", "claude"] (AIC_PROVIDER overrides)
+//   - context_windows: context window (tokens) per model name or prefix, e.g. {"llama3": 8192}
+//   - exclude/include: gitignore-style globs of staged files reduced to a stat line / always sent in full
+//   - body: true to suggest a subject plus body bullets (AIC_BODY / --body override)
+//   - redact: extra regexes whose matches are redacted from diffs (first capture group if any)
+//   - lint: Conventional Commits rules suggestions must pass (see lint.Rules)
+//   - azure: Azure OpenAI endpoint, deployment and api_version (see LoadAzure)
 type UserConfig struct {
-	Instructions string `json:"instructions"`
+	Instructions   string         `json:"instructions"`
+	Providers      []string       `json:"providers,omitempty"`
+	ContextWindows map[string]int `json:"context_windows,omitempty"`
+	Exclude        []string       `json:"exclude,omitempty"`
+	Include        []string       `json:"include,omitempty"`
+	Redact         []string       `json:"redact,omitempty"`
+	Body           bool           `json:"body,omitempty"`
+	Lint           *lint.Rules    `json:"lint,omitempty"`
+	Azure          *Azure         `json:"azure,omitempty"`
+}
+
+// Azure holds the settings of the azure provider, e.g.
+// {"endpoint": "https://<resource>.openai.azure.com", "deployment": "gpt-4o", "api_version": "2024-10-21"}.
+type Azure struct {
+	Endpoint   string `json:"endpoint,omitempty"`
+	Deployment string `json:"deployment,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// LoadAzure returns the azure provider settings. Each one is taken from its
+// environment variable (AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT,
+// AZURE_OPENAI_API_VERSION), else from ~/.aic.json, else from the repo
+// .aic.json.
+func LoadAzure() Azure {
+	return mergeAzure(LoadRepoConfig().Azure, LoadUserConfig().Azure)
+}
+
+// mergeAzure applies the precedence of LoadAzure to the repo and home
+// settings. The endpoint receives the API key, so a repo cannot set it.
+func mergeAzure(rc, uc *Azure) Azure {
+	var az Azure
+	if rc != nil {
+		az.Deployment, az.APIVersion = rc.Deployment, rc.APIVersion
+	}
+	if uc != nil {
+		az.Endpoint = uc.Endpoint
+		if uc.Deployment != "" {
+			az.Deployment = uc.Deployment
+		}
+		if uc.APIVersion != "" {
+			az.APIVersion = uc.APIVersion
+		}
+	}
+	for _, s := range []struct {
+		key string
+		val *string
+	}{
+		{EnvAzureOpenAIEndpoint, &az.Endpoint},
+		{EnvAzureOpenAIDeployment, &az.Deployment},
+		{EnvAzureOpenAIAPIVersion, &az.APIVersion},
+	} {
+		if v := strings.TrimSpace(Get(s.key)); v != "" {
+			*s.val = v
+		}
+	}
+	az.Endpoint = strings.TrimSpace(az.Endpoint)
+	az.Deployment = strings.TrimSpace(az.Deployment)
+	az.APIVersion = strings.TrimSpace(az.APIVersion)
+	return az
 }
 
 // LoadUserConfig reads ~/.aic.json if present. Returns zero-value on any error.
@@ -36,6 +103,7 @@ func LoadUserConfig() UserConfig {
 		return UserConfig{}
 	}
 	uc.Instructions = strings.TrimSpace(uc.Instructions)
+	uc.Providers = ParseProviderList(strings.Join(uc.Providers, ","))
 	return uc
 }
 
@@ -61,6 +129,7 @@ func LoadRepoConfig() UserConfig {
 		return UserConfig{}
 	}
 	uc.Instructions = strings.TrimSpace(uc.Instructions)
+	uc.Providers = ParseProviderList(strings.Join(uc.Providers, ","))
 	return uc
 }
 
@@ -85,6 +154,47 @@ func SaveRepoInstructions(instructions string) error {
 	return os.WriteFile(path, out, 0o644)
 }
 
+// SaveRepoLint stores rules as the "lint" object of the repo .aic.json unless
+// the file already has one, which may have been written by hand. It reports
+// whether the rules were written.
+func SaveRepoLint(rules *lint.Rules) (bool, error) {
+	root := repoRoot()
+	if root == "" {
+		return false, fmt.Errorf("not a git repository; cannot locate repo root")
+	}
+	path := filepath.Join(root, ".aic.json")
+	existing := UserConfig{}
+	if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+		_ = json.Unmarshal(b, &existing) // best-effort; ignore errors
+	}
+	if existing.Lint != nil || rules == nil {
+		return false, nil
+	}
+	existing.Lint = rules
+	out, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, out, 0o644)
+}
+
+// ParseProviderList splits a comma-separated provider chain such as
+// "openai, Claude,custom" into lower-cased names, dropping empty entries and
+// duplicates.
+func ParseProviderList(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
 // repoRoot returns the absolute path to the current repo's top-level directory, or "" if not in a repo.
 func repoRoot() string {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
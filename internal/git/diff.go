@@ -10,16 +10,23 @@ import (
 
 // StagedDiff returns the staged (cached) git diff using minimal unified output.
 func StagedDiff() (string, error) {
+	return StagedDiffFrom("")
+}
+
+// StagedDiffFrom is StagedDiff against base instead of HEAD; "" means HEAD.
+// With HEAD's parent it covers the commit being amended plus the staged
+// changes.
+func StagedDiffFrom(base string) (string, error) {
 	// First ensure we are inside a git work tree.
 	if err := insideRepo(); err != nil {
 		return "", err
 	}
 
-	variants := [][]string{
+	variants := withBase([][]string{
 		{"diff", "--cached", "--minimal", "--unified=0", "--no-prefix", "--color=never"},
 		{"diff", "--staged", "--minimal", "--unified=0", "--no-prefix", "--color=never"}, // alias
 		{"diff", "--minimal", "--unified=0", "--no-prefix", "--color=never"},             // fallback (includes unstaged)
-	}
+	}, base)
 
 	var lastErr error
 	for i, args := range variants {
@@ -61,15 +68,20 @@ func insideRepo() error {
 // StagedFiles returns the list of files included in the staged diff.
 // It prefers --cached/--staged and falls back to name-only without it (which may include unstaged).
 func StagedFiles() ([]string, error) {
+	return StagedFilesFrom("")
+}
+
+// StagedFilesFrom is StagedFiles against base instead of HEAD; "" means HEAD.
+func StagedFilesFrom(base string) ([]string, error) {
 	if err := insideRepo(); err != nil {
 		return nil, err
 	}
 
-	variants := [][]string{
+	variants := withBase([][]string{
 		{"diff", "--name-only", "--cached"},
 		{"diff", "--name-only", "--staged"}, // alias
 		{"diff", "--name-only"},             // fallback (may include unstaged)
-	}
+	}, base)
 
 	var outStr string
 	for i, args := range variants {
@@ -101,3 +113,14 @@ func StagedFiles() ([]string, error) {
 	}
 	return files, nil
 }
+
+// withBase appends base, when set, to each git diff variant.
+func withBase(variants [][]string, base string) [][]string {
+	if base == "" {
+		return variants
+	}
+	for i, args := range variants {
+		variants[i] = append(args, base)
+	}
+	return variants
+}
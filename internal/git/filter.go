@@ -0,0 +1,239 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the gitignore-syntax file at the repo root listing paths whose
+// hunks are not sent to the model.
+const IgnoreFile = ".aicignore"
+
+// DefaultExcludes are excluded unless re-included by .aicignore ("!go.sum")
+// or an include glob: lockfiles and other generated files whose hunks say
+// little about a change but take up most of the prompt.
+var DefaultExcludes = []string{
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"bun.lockb",
+	"go.sum",
+	"Cargo.lock",
+	"Gemfile.lock",
+	"composer.lock",
+	"poetry.lock",
+	"Pipfile.lock",
+	"uv.lock",
+	"pubspec.lock",
+	"Podfile.lock",
+	"packages.lock.json",
+	"mix.lock",
+	"flake.lock",
+	"*.min.js",
+	"*.min.css",
+}
+
+// Filter decides which files of a diff are sent in full and which are reduced
+// to a one-line stat. Patterns use gitignore syntax; the last matching rule
+// wins and include patterns override every exclude. A nil *Filter excludes
+// nothing.
+type Filter struct {
+	rules   []ignoreRule
+	include []ignoreRule
+}
+
+// NewFilter builds a filter from DefaultExcludes, the contents of an ignore
+// file (gitignore syntax) and exclude/include glob lists, in that order.
+func NewFilter(ignore string, exclude, include []string) *Filter {
+	f := &Filter{}
+	f.rules = append(f.rules, parseIgnore(strings.Join(DefaultExcludes, "\n"))...)
+	f.rules = append(f.rules, parseIgnore(ignore)...)
+	f.rules = append(f.rules, parseIgnore(strings.Join(exclude, "\n"))...)
+	f.include = parseIgnore(strings.Join(include, "\n"))
+	return f
+}
+
+// ReadIgnoreFile returns the contents of .aicignore at the repo root, or ""
+// if there is none.
+func ReadIgnoreFile() string {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), IgnoreFile))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Excluded reports whether the file at p (relative to the repo root) should
+// be summarized by a stat line instead of sent in full.
+func (f *Filter) Excluded(p string) bool {
+	if f == nil {
+		return false
+	}
+	for _, r := range f.include {
+		if !r.negate && r.match(p) {
+			return false
+		}
+	}
+	excluded := false
+	for _, r := range f.rules {
+		if r.match(p) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+// FilterDiff replaces the files of diff excluded by f with one stat line each
+// ("package-lock.json: +1200 -900"), listed after the remaining files. It
+// returns the new diff and the excluded paths.
+func FilterDiff(diff string, f *Filter) (string, []string) {
+	if f == nil {
+		return diff, nil
+	}
+	files := SplitDiff(diff)
+	var kept strings.Builder
+	var stats, excluded []string
+	for _, fd := range files {
+		if !f.Excluded(fd.Path) {
+			kept.WriteString(fd.String())
+			continue
+		}
+		excluded = append(excluded, fd.Path)
+		stats = append(stats, fd.StatLine())
+	}
+	if len(excluded) == 0 {
+		return diff, nil
+	}
+	kept.WriteString("\nFiles with diff omitted (lines added/removed):\n")
+	kept.WriteString(strings.Join(stats, "\n"))
+	kept.WriteString("\n")
+	return kept.String(), excluded
+}
+
+// StatLine summarizes the file as "path: +added -deleted", or "path: binary"
+// for binary files.
+func (f FileDiff) StatLine() string {
+	if f.Binary() {
+		return f.Path + ": binary"
+	}
+	added, deleted := 0, 0
+	for _, h := range f.Hunks {
+		lines := strings.Split(h, "\n")
+		// lines[0] is the "@@" header.
+		for _, ln := range lines[1:] {
+			switch {
+			case strings.HasPrefix(ln, "+"):
+				added++
+			case strings.HasPrefix(ln, "-"):
+				deleted++
+			}
+		}
+	}
+	return fmt.Sprintf("%s: +%d -%d", f.Path, added, deleted)
+}
+
+// Binary reports whether f changes a binary file.
+func (f FileDiff) Binary() bool {
+	return strings.Contains("\n"+f.Header, "\nGIT binary patch") || strings.Contains("\n"+f.Header, "\nBinary files")
+}
+
+// ignoreRule is one gitignore-syntax pattern.
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	// dirOnly rules (trailing "/") match directories only.
+	dirOnly bool
+	// anchored rules (containing "/") match the whole path from the repo
+	// root; others match any path component.
+	anchored bool
+}
+
+// parseIgnore parses gitignore-syntax text, skipping blank lines and comments.
+func parseIgnore(text string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// "\#" and "\!" escape a leading special character.
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// match reports whether the rule matches file p or one of its parent
+// directories (ignoring a directory ignores everything below it).
+func (r ignoreRule) match(p string) bool {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		isDir := i < len(parts)-1
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.anchored {
+			if globMatch(strings.Split(r.pattern, "/"), parts[:i+1]) {
+				return true
+			}
+		} else if ok, _ := path.Match(r.pattern, parts[i]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches path segments against pattern segments, where a "**"
+// segment matches any number of path segments (at least one when trailing,
+// so "dir/**" matches what is inside dir but not dir itself).
+func globMatch(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return len(parts) > 0
+		}
+		for i := 0; i <= len(parts); i++ {
+			if globMatch(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return globMatch(pattern[1:], parts[1:])
+}
@@ -0,0 +1,79 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterExcluded(t *testing.T) {
+	ignore := "# generated code\n*.pb.go\ndist/\n/docs/api/**\n!dist/keep.js\n"
+	f := NewFilter(ignore, []string{"vendor/"}, []string{"web/package-lock.json"})
+	cases := map[string]bool{
+		"package-lock.json":      true, // default
+		"app/yarn.lock":          true, // default, any level
+		"web/package-lock.json":  false,
+		"api/v1/service.pb.go":   true,
+		"api/v1/service.go":      false,
+		"dist/app.js":            true,
+		"dist/keep.js":           false,
+		"src/dist.go":            false,
+		"docs/api/index.html":    true,
+		"sub/docs/api/index.md":  false, // anchored to the root
+		"vendor/x/y.go":          true,
+		"README.md":              false,
+		"assets/site.min.js":     true,
+		"assets/site.js":         false,
+		"docs/api":               false, // file named like the dir, no ** match below it
+		"third_party/dist/a.txt": true,
+	}
+	for p, want := range cases {
+		if got := f.Excluded(p); got != want {
+			t.Errorf("Excluded(%q) = %v, want %v", p, got, want)
+		}
+	}
+	var nilFilter *Filter
+	if nilFilter.Excluded("package-lock.json") {
+		t.Fatalf("nil filter must exclude nothing")
+	}
+}
+
+func TestFilterDiffReplacesExcludedFilesWithStats(t *testing.T) {
+	diff := "diff --git main.go main.go\n" +
+		"--- main.go\n" +
+		"+++ main.go\n" +
+		"@@ -1 +1 @@\n" +
+		"-old\n" +
+		"+new\n" +
+		"diff --git package-lock.json package-lock.json\n" +
+		"--- package-lock.json\n" +
+		"+++ package-lock.json\n" +
+		"@@ -1,2 +1,3 @@\n" +
+		"-a\n" +
+		"-b\n" +
+		"+c\n" +
+		"+d\n" +
+		"+e\n" +
+		"diff --git logo.min.js logo.min.js\n" +
+		"new file mode 100644\n" +
+		"Binary files /dev/null and logo.min.js differ\n"
+
+	got, excluded := FilterDiff(diff, NewFilter("", nil, nil))
+	if len(excluded) != 2 || excluded[0] != "package-lock.json" || excluded[1] != "logo.min.js" {
+		t.Fatalf("excluded = %v", excluded)
+	}
+	if !strings.HasPrefix(got, "diff --git main.go main.go\n") || !strings.Contains(got, "+new\n") {
+		t.Fatalf("kept file missing from filtered diff:\n%s", got)
+	}
+	for _, want := range []string{"package-lock.json: +3 -2", "logo.min.js: binary"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("filtered diff lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "+e\n") {
+		t.Fatalf("excluded hunks leaked into the filtered diff:\n%s", got)
+	}
+
+	if same, ex := FilterDiff("diff --git a.go a.go\n@@ -1 +1 @@\n+x\n", NewFilter("", nil, nil)); ex != nil || same != "diff --git a.go a.go\n@@ -1 +1 @@\n+x\n" {
+		t.Fatalf("diff without excluded files must be returned unchanged")
+	}
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxMessages caps the commit messages Messages returns.
+const maxMessages = 50
+
+// ReadState returns the contents of name in the repository's git directory,
+// e.g. "MERGE_MSG" or "SQUASH_MSG", and whether it exists. Linked worktrees
+// are handled by git rev-parse --git-path.
+func ReadState(name string) (string, bool) {
+	path, err := run("", nil, "rev-parse", "--git-path", name)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Resolve returns the commit id of rev, or false if rev does not name a
+// commit (e.g. HEAD before the first commit).
+func Resolve(rev string) (string, bool) {
+	out, err := run("", nil, "rev-parse", "--verify", "-q", rev+"^{commit}")
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(out), true
+}
+
+// Message returns the full message of commit rev.
+func Message(rev string) (string, error) {
+	out, err := run("", nil, "log", "-1", "--format=%B", rev, "--")
+	return strings.TrimSpace(out), err
+}
+
+// Messages returns the messages of the commits in revRange (e.g.
+// "HEAD..MERGE_HEAD"), oldest first. Only the newest maxMessages are returned.
+func Messages(revRange string) ([]string, error) {
+	out, err := run("", nil, "log", "-z", "--format=%B", "-n", strconv.Itoa(maxMessages), revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+	var msgs []string
+	for _, m := range strings.Split(out, "\x00") {
+		if m = strings.TrimSpace(m); m != "" {
+			msgs = append(msgs, m)
+		}
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+// NameRev names commit rev for a merge subject the way git merge does, e.g.
+// "branch 'dev'" or "tag 'v1.2.0'", falling back to "commit '<short id>'".
+func NameRev(rev string) string {
+	out, err := run("", nil, "name-rev", "--name-only", "--no-undefined", rev)
+	name := strings.TrimSuffix(strings.TrimSpace(out), "^0")
+	switch {
+	case err != nil || name == "" || strings.ContainsAny(name, "~^"):
+	case strings.HasPrefix(name, "tags/"):
+		return "tag '" + strings.TrimPrefix(name, "tags/") + "'"
+	case strings.HasPrefix(name, "remotes/"):
+		return "remote-tracking branch '" + strings.TrimPrefix(name, "remotes/") + "'"
+	default:
+		return "branch '" + name + "'"
+	}
+	short, err := run("", nil, "rev-parse", "--short", rev)
+	if err != nil {
+		return "commit '" + rev + "'"
+	}
+	return "commit '" + strings.TrimSpace(short) + "'"
+}
+
+// EmptyTree returns the id of the empty tree, the diff base of a root commit.
+func EmptyTree() (string, error) {
+	out, err := run("", strings.NewReader(""), "hash-object", "-t", "tree", "--stdin")
+	return strings.TrimSpace(out), err
+}
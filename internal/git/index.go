@@ -0,0 +1,137 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// StagedPatch returns the staged changes as a patch that `git apply --cached`
+// accepts: a/ and b/ prefixes, no context lines, binary contents included and
+// renames shown as a deletion plus an addition.
+func StagedPatch() (string, error) {
+	if err := insideRepo(); err != nil {
+		return "", err
+	}
+	return run("", nil, "diff", "--cached", "--binary", "--no-color", "--no-ext-diff", "--no-renames", "--unified=0")
+}
+
+// BlobSizes returns the sizes of the blobs named on the "index" line of a
+// file's diff header, which StagedPatch writes in full. A side that does not
+// exist, as for added or deleted files, has size 0.
+func BlobSizes(header string) (old, cur int64, err error) {
+	for _, line := range strings.Split(header, "\n") {
+		ids, ok := strings.CutPrefix(line, "index ")
+		if !ok {
+			continue
+		}
+		ids, _, _ = strings.Cut(ids, " ")
+		from, to, ok := strings.Cut(ids, "..")
+		if !ok {
+			break
+		}
+		if old, err = blobSize(from); err != nil {
+			return 0, 0, err
+		}
+		cur, err = blobSize(to)
+		return old, cur, err
+	}
+	return 0, 0, errors.New("no index line in the diff header")
+}
+
+func blobSize(id string) (int64, error) {
+	if strings.Trim(id, "0") == "" {
+		return 0, nil
+	}
+	out, err := run("", nil, "cat-file", "-s", id)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
+
+// WriteTree writes the index to a tree object and returns its id. It fails
+// while the index has unmerged entries.
+func WriteTree() (string, error) {
+	out, err := run("", nil, "write-tree")
+	return strings.TrimSpace(out), err
+}
+
+// ReadTree replaces the index with tree (the working tree is left alone).
+func ReadTree(tree string) error {
+	_, err := run("", nil, "read-tree", tree)
+	return err
+}
+
+// ResetIndex makes the index match HEAD, or empties it before the first commit.
+func ResetIndex() error {
+	if _, err := run("", nil, "rev-parse", "--verify", "-q", "HEAD"); err != nil {
+		_, err = run("", nil, "read-tree", "--empty")
+		return err
+	}
+	return ReadTree("HEAD")
+}
+
+// ApplyCached applies patch (as produced by StagedPatch) to the index only.
+func ApplyCached(patch string) error {
+	top, err := run("", nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	// Patch paths are relative to the repo root; from a subdirectory git
+	// apply would silently skip files outside it.
+	_, err = run(strings.TrimSpace(top), strings.NewReader(patch), "apply", "--cached", "--unidiff-zero", "--whitespace=nowarn", "-")
+	return err
+}
+
+// Commit records the index as a new commit with msg, passed on stdin via -F
+// so that a subject and body are kept as written. Hook and git output go to
+// the terminal.
+func Commit(msg string) error {
+	return commit(msg)
+}
+
+// Amend is Commit for git commit --amend: the index and msg replace the
+// last commit.
+func Amend(msg string) error {
+	return commit(msg, "--amend")
+}
+
+func commit(msg string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"commit", "-F", "-"}, args...)...)
+	cmd.Stdin = strings.NewReader(strings.TrimSpace(msg) + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// CommentChar returns core.commentChar, the prefix of the comment lines git
+// strips from commit messages; "#" unless configured ("auto" counts as "#").
+func CommentChar() string {
+	out, err := run("", nil, "config", "core.commentChar")
+	if c := strings.TrimSpace(out); err == nil && c != "" && c != "auto" {
+		return c
+	}
+	return "#"
+}
+
+// run executes git with args in dir (the current directory if empty) and
+// returns stdout; stderr is included in the error.
+func run(dir string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(errOut.String()))
+	}
+	return out.String(), nil
+}
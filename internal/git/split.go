@@ -0,0 +1,97 @@
+package git
+
+import "strings"
+
+// FileDiff is the part of a unified diff that belongs to one file.
+type FileDiff struct {
+	// Path is the file's path (the new path for renames, the old one for deletions).
+	Path string
+	// Header holds the lines from "diff --git" up to the first hunk.
+	Header string
+	// Hunks holds each "@@" hunk including its header line.
+	Hunks []string
+}
+
+// String reassembles the file's diff.
+func (f FileDiff) String() string {
+	return f.Header + strings.Join(f.Hunks, "")
+}
+
+// SplitDiff splits a unified diff (as produced by StagedDiff) into per-file
+// parts, each further split into hunks. Text before the first "diff --git"
+// line is ignored.
+func SplitDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var cur *FileDiff
+	var hunk strings.Builder
+	endHunk := func() {
+		if cur != nil && hunk.Len() > 0 {
+			cur.Hunks = append(cur.Hunks, hunk.String())
+			hunk.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			endHunk()
+			files = append(files, FileDiff{Path: pathFromDiffLine(line)})
+			cur = &files[len(files)-1]
+			cur.Header = line
+		case cur == nil:
+			// preamble; ignore
+		case strings.HasPrefix(line, "@@"):
+			endHunk()
+			hunk.WriteString(line)
+		case hunk.Len() > 0:
+			hunk.WriteString(line)
+		default:
+			cur.Header += line
+			if p, ok := pathFromHeaderLine(line); ok {
+				cur.Path = p
+			}
+		}
+	}
+	endHunk()
+	return files
+}
+
+// pathFromHeaderLine extracts the path from "+++ path" (or "--- path" for
+// deletions). The "a/" and "b/" prefixes are stripped when present.
+func pathFromHeaderLine(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	var p string
+	switch {
+	case strings.HasPrefix(line, "+++ "):
+		p = strings.TrimPrefix(line, "+++ ")
+	case strings.HasPrefix(line, "--- "):
+		p = strings.TrimPrefix(line, "--- ")
+	case strings.HasPrefix(line, "rename to "):
+		return strings.TrimPrefix(line, "rename to "), true
+	default:
+		return "", false
+	}
+	if p == "/dev/null" {
+		return "", false
+	}
+	if strings.HasPrefix(p, "b/") || strings.HasPrefix(p, "a/") {
+		p = p[2:]
+	}
+	return p, true
+}
+
+// pathFromDiffLine guesses the path from "diff --git <old> <new>", assuming
+// both paths are equal (true unless the file was renamed, in which case the
+// "+++"/"rename to" lines override it).
+func pathFromDiffLine(line string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if n := len(rest); n%2 == 1 && rest[:n/2] == rest[n/2+1:] {
+		return rest[n/2+1:]
+	}
+	if i := strings.LastIndexByte(rest, ' '); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return strings.TrimPrefix(rest, "b/")
+}
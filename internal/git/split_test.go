@@ -0,0 +1,53 @@
+package git
+
+import "testing"
+
+func TestSplitDiff(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		"-a\n" +
+		"+b\n" +
+		"@@ -10,1 +10,1 @@\n" +
+		"-c\n" +
+		"+d\n" +
+		"diff --git a/old name.txt b/new name.txt\n" +
+		"similarity index 90%\n" +
+		"rename from old name.txt\n" +
+		"rename to new name.txt\n" +
+		"diff --git a/gone.txt b/gone.txt\n" +
+		"deleted file mode 100644\n" +
+		"--- a/gone.txt\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-bye\n"
+
+	files := SplitDiff(diff)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	want := []struct {
+		path  string
+		hunks int
+	}{{"main.go", 2}, {"new name.txt", 0}, {"gone.txt", 1}}
+	for i, w := range want {
+		if files[i].Path != w.path || len(files[i].Hunks) != w.hunks {
+			t.Errorf("file %d: got %q with %d hunks, want %q with %d", i, files[i].Path, len(files[i].Hunks), w.path, w.hunks)
+		}
+	}
+	var joined string
+	for _, f := range files {
+		joined += f.String()
+	}
+	if joined != diff {
+		t.Fatalf("reassembled diff differs:\n%s", joined)
+	}
+}
+
+func TestSplitDiffIgnoresPreamble(t *testing.T) {
+	if files := SplitDiff("warning: something\n"); len(files) != 0 {
+		t.Fatalf("expected no files, got %v", files)
+	}
+}
@@ -0,0 +1,62 @@
+// Package gittest creates throwaway Git repositories for tests, isolated from
+// the user's Git configuration.
+package gittest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Repo initializes a repository in a temp dir and makes it the working
+// directory for the rest of the test. HOME and the global Git config point
+// to a temp dir of their own, system config is ignored and commits get a
+// fixed identity. The test is skipped when git is not installed.
+func Repo(t testing.TB) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	home := t.TempDir()
+	for _, kv := range [][2]string{
+		{"HOME", home}, {"GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig")}, {"GIT_CONFIG_NOSYSTEM", "1"},
+		{"GIT_AUTHOR_NAME", "aic"}, {"GIT_AUTHOR_EMAIL", "aic@example.com"},
+		{"GIT_COMMITTER_NAME", "aic"}, {"GIT_COMMITTER_EMAIL", "aic@example.com"},
+	} {
+		t.Setenv(kv[0], kv[1])
+	}
+	Run(t, "init", "-q")
+	return dir
+}
+
+// Run runs git with args in the working directory and returns its trimmed
+// output, failing the test on error.
+func Run(t testing.TB, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Write writes files, keyed by name, to the working directory.
+func Write(t testing.TB, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package hook installs, removes and inspects the Git hooks that run aic.
+// The hook scripts are embedded in the binary, so no clone of the aic
+// repository is needed. Hooks that were already in place are kept and run
+// first: they are renamed to "<hook>.pre-aic" and called by the aic script.
+package hook
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed scripts
+var scripts embed.FS
+
+// Hook names. PrepareCommitMsg suggests messages; CommitMsg runs aic lint.
+const (
+	PrepareCommitMsg = "prepare-commit-msg"
+	CommitMsg        = "commit-msg"
+)
+
+// Names lists the hooks aic can install.
+var Names = []string{PrepareCommitMsg, CommitMsg}
+
+// chainSuffix names the pre-existing hook an aic hook runs first.
+const chainSuffix = ".pre-aic"
+
+// marker identifies aic scripts, including those linked by the old
+// scripts/install_git_hook.sh.
+const marker = " hook for aic"
+
+// Script returns the embedded script of hook name.
+func Script(name string) ([]byte, error) {
+	return scripts.ReadFile("scripts/" + name)
+}
+
+// Dir is a hooks directory and where it comes from.
+type Dir struct {
+	Path string
+	// Source explains Path, e.g. "core.hooksPath" or "init.templateDir".
+	Source string
+	// Global is set for the template directory used by git init and clone.
+	Global bool
+}
+
+// RepoDir returns the hooks directory of the current repository. It honours
+// core.hooksPath and, in linked worktrees, the hooks shared with the main
+// worktree.
+func RepoDir() (Dir, error) {
+	out, err := gitOutput("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return Dir{}, errors.New("not inside a Git repository")
+	}
+	path, err := filepath.Abs(out)
+	if err != nil {
+		return Dir{}, err
+	}
+	source := ".git/hooks"
+	if v, err := gitOutput("config", "core.hooksPath"); err == nil && v != "" {
+		source = "core.hooksPath"
+	}
+	return Dir{Path: path, Source: source}, nil
+}
+
+// GlobalDir returns the hooks directory of the global init.templateDir,
+// whose hooks git copies into every repository it creates or clones. When
+// init.templateDir is unset and create is true, it is set to
+// ~/.git-templates.
+func GlobalDir(create bool) (Dir, error) {
+	dir, err := gitOutput("config", "--global", "--path", "init.templateDir")
+	if err != nil || dir == "" {
+		if !create {
+			return Dir{}, errors.New("init.templateDir is not set")
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Dir{}, err
+		}
+		dir = filepath.Join(home, ".git-templates")
+		if _, err := gitOutput("config", "--global", "init.templateDir", dir); err != nil {
+			return Dir{}, err
+		}
+	}
+	return Dir{Path: filepath.Join(dir, "hooks"), Source: "init.templateDir", Global: true}, nil
+}
+
+// State of one hook in a directory.
+type State int
+
+const (
+	// Missing: no hook of that name.
+	Missing State = iota
+	// Installed: the hook is an aic script.
+	Installed
+	// Foreign: another program's hook, which aic would chain.
+	Foreign
+)
+
+func (s State) String() string {
+	switch s {
+	case Installed:
+		return "installed"
+	case Foreign:
+		return "other hook"
+	}
+	return "not installed"
+}
+
+// Status describes hook Name in a directory.
+type Status struct {
+	Name  string
+	Path  string
+	State State
+	// Chained is the path of the pre-existing hook the aic script runs
+	// first, if any.
+	Chained string
+	// Outdated is set for aic scripts that differ from the embedded one.
+	Outdated bool
+}
+
+// Inspect returns the status of every hook in Names.
+func (d Dir) Inspect() []Status {
+	out := make([]Status, 0, len(Names))
+	for _, name := range Names {
+		out = append(out, d.inspect(name))
+	}
+	return out
+}
+
+func (d Dir) inspect(name string) Status {
+	st := Status{Name: name, Path: filepath.Join(d.Path, name)}
+	data, err := os.ReadFile(st.Path)
+	switch {
+	case err == nil && bytes.Contains(data, []byte(marker)):
+		st.State = Installed
+		script, _ := Script(name)
+		st.Outdated = !bytes.Equal(data, script)
+	case isOldLink(st.Path, name):
+		// The clone the old installer linked into is gone; the link is
+		// still aic's and is replaced rather than chained.
+		st.State, st.Outdated = Installed, true
+	case err == nil || isDanglingLink(st.Path):
+		st.State = Foreign
+	}
+	if _, err := os.Lstat(st.Path + chainSuffix); err == nil {
+		st.Chained = st.Path + chainSuffix
+	}
+	return st
+}
+
+// Install writes the aic scripts of names into d. An aic script already in
+// place is replaced; any other hook is renamed to "<hook>.pre-aic" and run
+// by the aic script before it does anything else.
+func (d Dir) Install(names []string) ([]Status, error) {
+	if err := os.MkdirAll(d.Path, 0o755); err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, name := range names {
+		script, err := Script(name)
+		if err != nil {
+			return out, fmt.Errorf("unknown hook %q", name)
+		}
+		st := d.inspect(name)
+		if st.State == Foreign {
+			if st.Chained != "" {
+				return out, fmt.Errorf("%s: cannot keep the existing hook, %s is in the way", st.Path, filepath.Base(st.Chained))
+			}
+			if err := os.Rename(st.Path, st.Path+chainSuffix); err != nil {
+				return out, err
+			}
+			st.Chained = st.Path + chainSuffix
+		}
+		// Remove first: the old installer left symlinks into a clone of aic,
+		// which must not be overwritten.
+		if err := os.Remove(st.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return out, err
+		}
+		if err := os.WriteFile(st.Path, script, 0o755); err != nil {
+			return out, err
+		}
+		st.State, st.Outdated = Installed, false
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Uninstall removes the aic scripts from d and puts chained hooks back. Hooks
+// that are not aic's are left alone.
+func (d Dir) Uninstall() ([]Status, error) {
+	var out []Status
+	for _, st := range d.Inspect() {
+		if st.State != Installed {
+			continue
+		}
+		if err := os.Remove(st.Path); err != nil {
+			return out, err
+		}
+		st.State = Missing
+		if st.Chained != "" {
+			if err := os.Rename(st.Chained, st.Path); err != nil {
+				return out, err
+			}
+			st.State = Foreign
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// isOldLink reports whether path is a symlink made by the old
+// scripts/install_git_hook.sh, which pointed into scripts/git-hooks of a
+// clone of aic.
+func isOldLink(path, name string) bool {
+	target, err := os.Readlink(path)
+	return err == nil && strings.HasSuffix(filepath.ToSlash(target), "scripts/git-hooks/"+name)
+}
+
+func isDanglingLink(path string) bool {
+	fi, err := os.Lstat(path)
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,157 @@
+package hook
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/gittest"
+)
+
+func states(d Dir) map[string]State {
+	out := map[string]State{}
+	for _, st := range d.Inspect() {
+		out[st.Name] = st.State
+	}
+	return out
+}
+
+func TestInstallChainsAndUninstallRestores(t *testing.T) {
+	gittest.Repo(t)
+	dir, err := RepoDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(dir.Path, CommitMsg)
+	if err := os.WriteFile(existing, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Install(Names); err != nil {
+		t.Fatal(err)
+	}
+	status := dir.Inspect()
+	for _, st := range status {
+		if st.State != Installed || st.Outdated {
+			t.Fatalf("%s: %v (outdated %v)", st.Name, st.State, st.Outdated)
+		}
+	}
+	if status[1].Chained != existing+".pre-aic" || status[0].Chained != "" {
+		t.Fatalf("chained = %q, %q", status[0].Chained, status[1].Chained)
+	}
+	// Installing again updates the scripts and keeps the chain.
+	if _, err := dir.Install(Names); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if got := states(dir); got[PrepareCommitMsg] != Missing || got[CommitMsg] != Foreign {
+		t.Fatalf("after uninstall: %v", got)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "#!/bin/sh\nexit 0\n" {
+		t.Fatalf("existing hook not restored: %q", data)
+	}
+}
+
+func TestChainedHookRunsFirst(t *testing.T) {
+	gittest.Repo(t)
+	dir, _ := RepoDir()
+	path := filepath.Join(dir.Path, CommitMsg)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho rejected >&2\nexit 3\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Install([]string{CommitMsg}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(path, "msg").CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 3 || !strings.Contains(string(out), "rejected") {
+		t.Fatalf("chained hook did not run first: %v\n%s", err, out)
+	}
+}
+
+func TestRepoDirHonoursHooksPathAndWorktrees(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(gittest.Repo(t))
+	gittest.Run(t, "commit", "-q", "--allow-empty", "-m", "initial")
+	repoDir := func() Dir {
+		t.Helper()
+		dir, err := RepoDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir.Path, _ = filepath.EvalSymlinks(dir.Path)
+		return dir
+	}
+
+	gittest.Run(t, "config", "core.hooksPath", ".githooks")
+	if err := os.MkdirAll(filepath.Join(root, ".githooks", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(root, ".githooks", "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if dir := repoDir(); dir.Path != filepath.Join(root, ".githooks") || dir.Source != "core.hooksPath" {
+		t.Fatalf("dir = %+v, want %s from core.hooksPath", dir, filepath.Join(root, ".githooks"))
+	}
+
+	gittest.Run(t, "config", "--unset", "core.hooksPath")
+	worktree := filepath.Join(t.TempDir(), "wt")
+	gittest.Run(t, "worktree", "add", "-q", worktree)
+	if err := os.Chdir(worktree); err != nil {
+		t.Fatal(err)
+	}
+	if dir := repoDir(); dir.Path != filepath.Join(root, ".git", "hooks") {
+		t.Fatalf("worktree hooks = %s, want the main repository's", dir.Path)
+	}
+}
+
+func TestGlobalDirSetsTemplateDir(t *testing.T) {
+	gittest.Repo(t)
+	if _, err := GlobalDir(false); err == nil {
+		t.Fatalf("expected an error without init.templateDir")
+	}
+	dir, err := GlobalDir(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Install([]string{PrepareCommitMsg}); err != nil {
+		t.Fatal(err)
+	}
+	// git init copies the template hooks into new repositories.
+	repo := filepath.Join(t.TempDir(), "new")
+	gittest.Run(t, "init", "-q", repo)
+	if st := (Dir{Path: filepath.Join(repo, ".git", "hooks")}).Inspect(); st[0].State != Installed {
+		t.Fatalf("new repository lacks the hook: %+v", st[0])
+	}
+}
+
+func TestInstallReplacesDanglingOldLink(t *testing.T) {
+	gittest.Repo(t)
+	dir, _ := RepoDir()
+	path := filepath.Join(dir.Path, PrepareCommitMsg)
+	// The old installer linked into a clone of aic that has since been removed.
+	if err := os.Symlink(filepath.Join(t.TempDir(), "aic", "scripts", "git-hooks", PrepareCommitMsg), path); err != nil {
+		t.Fatal(err)
+	}
+	if st := dir.Inspect()[0]; st.State != Installed || !st.Outdated {
+		t.Fatalf("old link: %v (outdated %v)", st.State, st.Outdated)
+	}
+	if _, err := dir.Install([]string{PrepareCommitMsg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path + chainSuffix); err == nil {
+		t.Fatal("old link was chained instead of replaced")
+	}
+	if fi, err := os.Lstat(path); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("hook not replaced by a script: %v", err)
+	}
+	// A dangling link to anything else is still another program's hook.
+	other := filepath.Join(dir.Path, CommitMsg)
+	if err := os.Symlink(filepath.Join(t.TempDir(), "missing"), other); err != nil {
+		t.Fatal(err)
+	}
+	if got := states(dir); got[CommitMsg] != Foreign {
+		t.Fatalf("foreign link: %v", got[CommitMsg])
+	}
+}
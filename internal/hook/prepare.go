@@ -0,0 +1,129 @@
+package hook
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Action is what the prepare-commit-msg hook does with a message file.
+type Action int
+
+const (
+	// Skip leaves the message file alone.
+	Skip Action = iota
+	// Select lets the user pick a suggestion.
+	Select
+	// First writes the first suggestion without prompting; the user asked
+	// for it by typing the sentinel.
+	First
+)
+
+func (a Action) String() string {
+	switch a {
+	case Select:
+		return "select"
+	case First:
+		return "first"
+	}
+	return "skip"
+}
+
+// sentinel matches a first line asking for a generated message: "aic",
+// optionally followed by punctuation, e.g. "aic" or "AIC!".
+var sentinel = regexp.MustCompile(`(?i)^aic([:!.-].*)?$`)
+
+// IsSentinel reports whether text, a message without comments, is the
+// placeholder that asks the hook for a generated message.
+func IsSentinel(text string) bool {
+	first, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return sentinel.MatchString(strings.TrimSpace(first))
+}
+
+// Git's own messages for merges and squash merges, which a suggestion may
+// replace.
+var (
+	mergeText  = regexp.MustCompile(`^Merge (branch|branches|remote-tracking branch|tag|commit|commits) `)
+	squashText = "Squashed commit of the following:"
+)
+
+// Decide returns the action for a message file with text (comments removed,
+// see lint.Clean) prepared by git from source and sha, the second and third
+// hook arguments. editor reports whether git will open an editor on the
+// message afterwards (see EditorShown):
+//
+//   - "" (plain git commit) and "template" (-t or commit.template): select
+//     a suggestion when there is no text yet;
+//   - "message" (-m or -F): only when the text is the sentinel. This source
+//     is also used with --amend (see GitAmends);
+//   - "merge" and "squash": select when git wrote its default message and
+//     the editor opens, so that the pick can still be changed;
+//   - "commit" with sha HEAD (--amend): select when the editor opens;
+//     cancelling keeps the previous message. Other commits (-c, -C) are
+//     left alone.
+//
+// The sentinel always writes the first suggestion, except for "commit",
+// where the text is an existing commit's message.
+func Decide(source, sha, text string, editor bool) Action {
+	switch source {
+	case "", "template", "message", "merge", "squash":
+		if IsSentinel(text) {
+			return First
+		}
+	}
+	switch source {
+	case "", "template":
+		if text == "" {
+			return Select
+		}
+	case "merge":
+		if editor && (text == "" || mergeText.MatchString(text)) {
+			return Select
+		}
+	case "squash":
+		if editor && (text == "" || strings.HasPrefix(text, squashText)) {
+			return Select
+		}
+	case "commit":
+		if editor && IsAmend(source, sha) {
+			return Select
+		}
+	}
+	return Skip
+}
+
+// IsAmend reports whether the hook arguments source and sha are those of
+// git commit --amend.
+func IsAmend(source, sha string) bool {
+	return source == "commit" && sha == "HEAD"
+}
+
+// EditorShown reports whether git opens an editor on the message after the
+// hook: git sets GIT_EDITOR to ":" for hooks when it will not.
+func EditorShown() bool {
+	return os.Getenv("GIT_EDITOR") != ":"
+}
+
+// InsertMessage returns content, a message file prepared by git, with msg
+// in place of the text above git's comment block, such as git's merge or
+// squash message or the message being amended. The comment lines and
+// everything after them (such as the diff of commit -v) are kept.
+func InsertMessage(content, msg, commentChar string) string {
+	msg = strings.TrimSpace(msg)
+	lines := strings.Split(content, "\n")
+	for i, ln := range lines {
+		if strings.HasPrefix(ln, commentChar) {
+			return msg + "\n\n" + strings.Join(lines[i:], "\n")
+		}
+	}
+	return msg + "\n"
+}
+
+// WriteMessage puts msg into the message file at path with InsertMessage.
+func WriteMessage(path, msg, commentChar string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(path, []byte(InsertMessage(string(data), msg, commentChar)), 0o644)
+}
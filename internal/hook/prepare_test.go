@@ -0,0 +1,116 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// commitTemplate is the file git prepares for a plain git commit.
+const commitTemplate = "\n# Please enter the commit message for your changes. Lines starting\n# with '#' will be ignored.\n#\n# Changes to be committed:\n#\tmodified:   main.go\n#\n"
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		source, sha, text string
+		editor            bool
+		want              Action
+	}{
+		{"", "", "", true, Select},
+		{"", "", "aic", true, First},
+		{"", "", "feat: add login", true, Skip},
+		{"template", "", "", true, Select},
+		{"template", "", "AIC", true, First},
+		{"template", "", "Issue: \nSummary:", true, Skip},
+		{"message", "", "aic", false, First},
+		{"message", "", "aic: please", false, First},
+		{"message", "", "aic!", false, First},
+		{"message", "", "", false, Skip},
+		{"message", "", "fix: typo", false, Skip},
+		{"message", "", "aicore: bump", false, Skip},
+		{"merge", "", "Merge branch 'dev'", true, Select},
+		{"merge", "", "Merge remote-tracking branch 'origin/main' into dev", true, Select},
+		{"merge", "", "Merge branch 'dev'", false, Skip},
+		{"merge", "", "feat: hand-written merge message", true, Skip},
+		{"merge", "", "aic", false, First},
+		{"squash", "", "Squashed commit of the following:\n\ncommit 1234", true, Select},
+		{"squash", "", "Squashed commit of the following:", false, Skip},
+		{"squash", "", "aic", false, First},
+		{"commit", "HEAD", "feat: add login", true, Select},
+		{"commit", "HEAD", "feat: add login", false, Skip},
+		{"commit", "HEAD", "aic", false, Skip},
+		{"commit", "1a2b3c", "feat: add login", true, Skip},
+		{"unknown", "", "", true, Skip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source+"/"+tt.text, func(t *testing.T) {
+			if got := Decide(tt.source, tt.sha, tt.text, tt.editor); got != tt.want {
+				t.Fatalf("Decide(%q, %q, %q, %v) = %v, want %v", tt.source, tt.sha, tt.text, tt.editor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertMessage(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"plain commit", commitTemplate, "feat: add login\n\n" + commitTemplate[1:]},
+		{"sentinel with editor", "aic\n" + commitTemplate, "feat: add login\n\n" + commitTemplate[1:]},
+		{"message", "aic\n", "feat: add login\n"},
+		{"empty", "", "feat: add login\n"},
+		{"merge", "Merge branch 'dev'\n\n# Conflicts:\n#\tmain.go\n", "feat: add login\n\n# Conflicts:\n#\tmain.go\n"},
+		{"amend", "fix: old message\n\nOld body.\n" + commitTemplate, "feat: add login\n\n" + commitTemplate[1:]},
+		{"verbose", "\n# Comment\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n", "feat: add login\n\n# Comment\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InsertMessage(tt.content, "feat: add login\n", "#"); got != tt.want {
+				t.Fatalf("InsertMessage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := InsertMessage("\n; comment\n", "fix: x", ";"); got != "fix: x\n\n; comment\n" {
+		t.Fatalf("custom comment char: %q", got)
+	}
+}
+
+func TestWriteMessageKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(path, []byte(commitTemplate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMessage(path, "feat: add login\n\n- use sessions", "#"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "feat: add login\n\n- use sessions\n\n" + commitTemplate[1:]; string(data) != want {
+		t.Fatalf("file = %q, want %q", data, want)
+	}
+}
+
+func TestAmendArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"git", "commit", "--amend", "-m", "aic"}, true},
+		{[]string{"/usr/bin/git", "-c", "user.name=x", "commit", "-F", "msg.txt", "--amend"}, true},
+		{[]string{"git", "commit", "-m", "aic"}, false},
+		{[]string{"git", "commit", "--", "--amend"}, false},
+		{[]string{"git", "--amend"}, false},
+	} {
+		if got := amendArgs(tc.args); got != tc.want {
+			t.Errorf("amendArgs(%q) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestProcessReadsOwnCommandLine(t *testing.T) {
+	args, ppid, ok := process(os.Getpid())
+	if !ok {
+		t.Skip("process information not available")
+	}
+	if ppid != os.Getppid() || len(args) == 0 || filepath.Base(args[0]) != filepath.Base(os.Args[0]) {
+		t.Fatalf("process = %q, %d; want %q, %d", args, ppid, os.Args, os.Getppid())
+	}
+}
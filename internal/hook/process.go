@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExitAbort is the exit status with which aic hook prepare-commit-msg asks
+// the hook script to abort the commit. Other failures never block it.
+const ExitAbort = 3
+
+// GitAmends reports whether the git process running the hook is git commit
+// --amend. Git passes the hook no sign of an amend when the message comes
+// from -m or -F, so the command line of the nearest git ancestor of this
+// process is checked. It is false when that cannot be read.
+func GitAmends() bool {
+	pid := os.Getppid()
+	// aic runs under the hook script, which runs under git.
+	for range 4 {
+		args, ppid, ok := process(pid)
+		if !ok {
+			return false
+		}
+		if isGit(args) {
+			return amendArgs(args)
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// process returns the command line and parent of process pid from /proc,
+// or from ps where there is no /proc.
+func process(pid int) (args []string, ppid int, ok bool) {
+	if pid <= 1 {
+		return nil, 0, false
+	}
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return nil, 0, false
+		}
+		// "pid (comm) state ppid ...", where comm may contain spaces.
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		if len(fields) < 2 {
+			return nil, 0, false
+		}
+		ppid, err = strconv.Atoi(fields[1])
+		return strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"), ppid, err == nil
+	}
+	out, err := exec.Command("ps", "-o", "ppid=", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, 0, false
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return nil, 0, false
+	}
+	ppid, err = strconv.Atoi(fields[0])
+	return fields[1:], ppid, err == nil
+}
+
+func isGit(args []string) bool {
+	return len(args) > 0 && strings.TrimSuffix(filepath.Base(args[0]), ".exe") == "git"
+}
+
+// amendArgs reports whether args, a git command line, is a commit with
+// --amend.
+func amendArgs(args []string) bool {
+	commit := false
+	for _, a := range args[1:] {
+		switch {
+		case a == "--":
+			return false
+		case a == "commit":
+			commit = true
+		case a == "--amend":
+			return commit
+		}
+	}
+	return false
+}
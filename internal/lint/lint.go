@@ -0,0 +1,258 @@
+// Package lint checks commit messages against Conventional Commits rules and
+// fixes the violations that can be fixed mechanically (quotes, type case,
+// trailing punctuation, ...).
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultTypes are the commit types allowed when Rules.Types is empty.
+var DefaultTypes = []string{"feat", "fix", "refactor", "docs", "chore", "test", "perf", "build", "ci", "style"}
+
+// DefaultMaxLength limits the header when Rules.MaxLength is 0.
+const DefaultMaxLength = 72
+
+// Values of Rules.Case.
+const (
+	CaseLower = "lower"
+	CaseUpper = "upper"
+	CaseAny   = "any"
+)
+
+// Rules configures the checks; it is the "lint" object of .aic.json. The
+// zero value applies the defaults.
+type Rules struct {
+	// Types lists the allowed commit types [default: DefaultTypes].
+	Types []string `json:"types,omitempty"`
+	// RequireScope rejects headers without a "(scope)".
+	RequireScope bool `json:"require_scope,omitempty"`
+	// MaxLength limits the header (first line) in characters [default: 72].
+	MaxLength int `json:"max_length,omitempty"`
+	// Case of the description's first letter: lower (default), upper or any.
+	Case string `json:"case,omitempty"`
+	// TrailingPunctuation lists characters the header may not end with
+	// [default: "."].
+	TrailingPunctuation string `json:"trailing_punctuation,omitempty"`
+	// BannedWords are whole words the description may not contain, matched
+	// case-insensitively, e.g. "diff" or "file".
+	BannedWords []string `json:"banned_words,omitempty"`
+}
+
+// Violation is one broken rule. Rule names follow commitlint where one
+// exists, e.g. "type-enum" or "header-max-length".
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string { return v.Rule + ": " + v.Message }
+
+// AllowedTypes returns the configured types, or DefaultTypes.
+func (r Rules) AllowedTypes() []string {
+	if len(r.Types) == 0 {
+		return DefaultTypes
+	}
+	return r.Types
+}
+
+// MaxHeader returns the configured header limit, or DefaultMaxLength.
+func (r Rules) MaxHeader() int {
+	if r.MaxLength <= 0 {
+		return DefaultMaxLength
+	}
+	return r.MaxLength
+}
+
+func (r Rules) punctuation() string {
+	if r.TrailingPunctuation == "" {
+		return "."
+	}
+	return r.TrailingPunctuation
+}
+
+func (r Rules) caseRule() string {
+	switch strings.ToLower(r.Case) {
+	case CaseUpper, CaseAny:
+		return strings.ToLower(r.Case)
+	}
+	return CaseLower
+}
+
+// header is "type(scope)!: description"; loose accepts what Fix can repair
+// (upper-case types, spacing around the colon, an empty scope).
+var (
+	header      = regexp.MustCompile(`^([a-z]+)(?:\(([^()\s][^()]*)\))?(!)?: (\S.*)$`)
+	looseHeader = regexp.MustCompile(`^([A-Za-z]+)\s*(?:\(\s*([^()]*?)\s*\))?\s*(!)?\s*:\s*(.*)$`)
+)
+
+// typeAliases maps common misspellings of types to the canonical type.
+var typeAliases = map[string]string{
+	"feature": "feat", "features": "feat",
+	"bug": "fix", "bugfix": "fix", "hotfix": "fix", "fixes": "fix",
+	"doc": "docs", "documentation": "docs",
+	"tests": "test", "testing": "test",
+	"refactoring": "refactor", "performance": "perf",
+	"chores": "chore", "styles": "style",
+}
+
+// Check returns the violations of msg, header first.
+func (r Rules) Check(msg string) []Violation {
+	var out []Violation
+	add := func(rule, format string, args ...any) {
+		out = append(out, Violation{rule, fmt.Sprintf(format, args...)})
+	}
+	lines := strings.Split(strings.TrimSpace(msg), "\n")
+	head := strings.TrimSpace(lines[0])
+	if head == "" {
+		add("header-empty", "message is empty")
+		return out
+	}
+	if n := utf8.RuneCountInString(head); n > r.MaxHeader() {
+		add("header-max-length", "header is %d characters, limit is %d", n, r.MaxHeader())
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		add("body-leading-blank", "body must be separated from the header by a blank line")
+	}
+	m := header.FindStringSubmatch(head)
+	if m == nil {
+		add("header-format", "header must look like \"type(scope): description\"")
+		return out
+	}
+	typ, scope, desc := m[1], m[2], m[4]
+	if !slices.Contains(r.AllowedTypes(), typ) {
+		add("type-enum", "type %q is not one of %s", typ, strings.Join(r.AllowedTypes(), ", "))
+	}
+	if r.RequireScope && scope == "" {
+		add("scope-empty", "a scope is required, e.g. \"%s(api): ...\"", typ)
+	}
+	if first, ok := caseFix(desc, r.caseRule()); ok && first != desc {
+		add("subject-case", "description must start with a %s-case letter", r.caseRule())
+	}
+	if strings.ContainsAny(lastRune(head), r.punctuation()) {
+		add("subject-full-stop", "header must not end with %q", lastRune(head))
+	}
+	for _, w := range r.BannedWords {
+		if w = strings.TrimSpace(w); w != "" && containsWord(desc, w) {
+			add("subject-banned-word", "description must not mention %q", w)
+		}
+	}
+	return out
+}
+
+// Fix repairs what can be repaired mechanically — surrounding quotes, type
+// case and aliases ("Feature" becomes "feat"), spacing around the colon, an
+// empty scope, the description's first letter, trailing punctuation and a
+// missing blank line before the body — and returns the result with the
+// violations that remain.
+func (r Rules) Fix(msg string) (string, []Violation) {
+	msg = unquote(strings.TrimSpace(msg))
+	lines := strings.Split(msg, "\n")
+	head := strings.TrimSpace(lines[0])
+	if m := looseHeader.FindStringSubmatch(head); m != nil {
+		typ := strings.ToLower(m[1])
+		if alias, ok := typeAliases[typ]; ok && !slices.Contains(r.AllowedTypes(), typ) && slices.Contains(r.AllowedTypes(), alias) {
+			typ = alias
+		}
+		desc := strings.TrimRightFunc(strings.TrimSpace(m[4]), func(c rune) bool {
+			return strings.ContainsRune(r.punctuation(), c) || unicode.IsSpace(c)
+		})
+		if fixed, ok := caseFix(desc, r.caseRule()); ok {
+			desc = fixed
+		}
+		if desc != "" {
+			head = typ
+			if m[2] != "" {
+				head += "(" + m[2] + ")"
+			}
+			head += m[3] + ": " + desc
+		}
+	}
+	lines[0] = head
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		lines = append([]string{head, ""}, lines[1:]...)
+	}
+	fixed := strings.Join(lines, "\n")
+	return fixed, r.Check(fixed)
+}
+
+// unquote strips one pair of quotes or backticks wrapping the whole message.
+func unquote(s string) string {
+	for _, q := range [][2]string{{`"`, `"`}, {"'", "'"}, {"`", "`"}, {"“", "”"}} {
+		if len(s) > len(q[0])+len(q[1]) && strings.HasPrefix(s, q[0]) && strings.HasSuffix(s, q[1]) {
+			return strings.TrimSpace(s[len(q[0]) : len(s)-len(q[1])])
+		}
+	}
+	return s
+}
+
+// caseFix returns desc with its first letter in case rule. It reports false
+// when the case should be left alone: the first word looks like an acronym
+// or identifier ("API", "iOS", "getUser") or the rule is "any".
+func caseFix(desc, rule string) (string, bool) {
+	first, size := utf8.DecodeRuneInString(desc)
+	if rule == CaseAny || size == 0 || !unicode.IsLetter(first) {
+		return desc, false
+	}
+	word, _, _ := strings.Cut(desc[size:], " ")
+	if strings.IndexFunc(word, func(c rune) bool { return unicode.IsUpper(c) || unicode.IsDigit(c) }) >= 0 {
+		return desc, false
+	}
+	if rule == CaseUpper {
+		return string(unicode.ToUpper(first)) + desc[size:], true
+	}
+	return string(unicode.ToLower(first)) + desc[size:], true
+}
+
+func lastRune(s string) string {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return string(r)
+}
+
+// containsWord reports whether text contains word as a whole word, ignoring case.
+func containsWord(text, word string) bool {
+	re, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`)
+	return err == nil && re.MatchString(text)
+}
+
+// scissors marks where git cuts the message when committing with -v; the
+// comment character is prepended.
+const scissors = " ------------------------ >8 ------------------------"
+
+// Clean returns msg the way git records it with the default cleanup mode:
+// everything from the scissors line on and lines starting with commentChar
+// are dropped, as are trailing spaces and repeated blank lines.
+func Clean(msg, commentChar string) string {
+	if commentChar == "" {
+		commentChar = "#"
+	}
+	var out []string
+	for _, ln := range strings.Split(msg, "\n") {
+		ln = strings.TrimRight(ln, " \t\r")
+		if ln == commentChar+scissors {
+			break
+		}
+		if strings.HasPrefix(ln, commentChar) || (ln == "" && (len(out) == 0 || out[len(out)-1] == "")) {
+			continue
+		}
+		out = append(out, ln)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// Exempt reports whether msg is one git writes itself (merges, reverts) or
+// marks a commit for autosquash (fixup!, squash!, amend!). Such messages are
+// accepted as they are.
+func Exempt(msg string) bool {
+	for _, prefix := range []string{"Merge ", "Revert \"", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func rules(vs []Violation) []string {
+	var out []string
+	for _, v := range vs {
+		out = append(out, v.Rule)
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	long := "feat: add a description that goes on and on until it is far too long for a header"
+	tests := []struct {
+		name  string
+		rules Rules
+		msg   string
+		want  []string
+	}{
+		{"valid", Rules{}, "feat(api): add pagination", nil},
+		{"breaking", Rules{}, "feat!: drop v1 endpoints", nil},
+		{"body", Rules{}, "fix: handle nil config\n\n- guard LoadConfig", nil},
+		{"empty", Rules{}, "  ", []string{"header-empty"}},
+		{"no type", Rules{}, "add pagination", []string{"header-format"}},
+		{"unknown type", Rules{}, "wip: add pagination", []string{"type-enum"}},
+		{"custom types", Rules{Types: []string{"wip"}}, "wip: add pagination", nil},
+		{"too long", Rules{}, long, []string{"header-max-length"}},
+		{"max length", Rules{MaxLength: 200}, long, nil},
+		{"scope required", Rules{RequireScope: true}, "feat: add pagination", []string{"scope-empty"}},
+		{"upper case", Rules{}, "feat: Add pagination", []string{"subject-case"}},
+		{"acronym", Rules{}, "feat: API pagination", nil},
+		{"case upper", Rules{Case: "upper"}, "feat: add pagination", []string{"subject-case"}},
+		{"full stop", Rules{}, "feat: add pagination.", []string{"subject-full-stop"}},
+		{"punctuation", Rules{TrailingPunctuation: ".!"}, "feat: add pagination!", []string{"subject-full-stop"}},
+		{"banned", Rules{BannedWords: []string{"file"}}, "chore: update File", []string{"subject-banned-word"}},
+		{"banned substring", Rules{BannedWords: []string{"file"}}, "chore: update profile", nil},
+		{"no blank line", Rules{}, "fix: handle nil\n- guard", []string{"body-leading-blank"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(tt.rules.Check(tt.msg)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		msg   string
+		want  string
+		left  []string
+	}{
+		{"quotes", Rules{}, `"feat: add pagination"`, "feat: add pagination", nil},
+		{"backticks", Rules{}, "`fix: handle nil`", "fix: handle nil", nil},
+		{"type case", Rules{}, "Feat: add pagination", "feat: add pagination", nil},
+		{"alias", Rules{}, "Feature(api): add pagination", "feat(api): add pagination", nil},
+		{"alias not allowed", Rules{Types: []string{"feature"}}, "feature: add pagination", "feature: add pagination", nil},
+		{"spacing", Rules{}, "fix (parser) :handle nil", "fix(parser): handle nil", nil},
+		{"empty scope", Rules{}, "fix(): handle nil", "fix: handle nil", nil},
+		{"full stop", Rules{}, "docs: update readme.", "docs: update readme", nil},
+		{"first letter", Rules{}, "feat: Add pagination", "feat: add pagination", nil},
+		{"acronym kept", Rules{}, "feat: API pagination", "feat: API pagination", nil},
+		{"case upper", Rules{Case: "upper"}, "feat: add pagination", "feat: Add pagination", nil},
+		{"blank line", Rules{}, "fix: handle nil\n- guard", "fix: handle nil\n\n- guard", nil},
+		{"unfixable type", Rules{}, "wip: add pagination", "wip: add pagination", []string{"type-enum"}},
+		{"unfixable format", Rules{}, "Add pagination", "Add pagination", []string{"header-format"}},
+		{"unfixable scope", Rules{RequireScope: true}, "feat: add pagination", "feat: add pagination", []string{"scope-empty"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, left := tt.rules.Fix(tt.msg)
+			if got != tt.want || !reflect.DeepEqual(rules(left), tt.left) {
+				t.Fatalf("Fix(%q) = %q, %v; want %q, %v", tt.msg, got, rules(left), tt.want, tt.left)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	msg := "feat: add login  \n\n\n# Please enter the commit message\n- use sessions\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n"
+	if got, want := Clean(msg, "#"), "feat: add login\n\n- use sessions"; got != want {
+		t.Fatalf("Clean = %q, want %q", got, want)
+	}
+	if got, want := Clean("; note\nfix: x\n#1 issue", ";"), "fix: x\n#1 issue"; got != want {
+		t.Fatalf("Clean with ';' = %q, want %q", got, want)
+	}
+}
+
+func TestExempt(t *testing.T) {
+	for _, msg := range []string{"Merge branch 'main' into dev", `Revert "feat: add login"`, "fixup! feat: add login"} {
+		if !Exempt(msg) {
+			t.Errorf("Exempt(%q) = false", msg)
+		}
+	}
+	if Exempt("Merged the branches") {
+		t.Errorf("Exempt should only match git's own messages")
+	}
+}
@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/diesi/aic/internal/retry"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI api-version used when none is configured.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureConfig holds the Azure OpenAI routing details. Requests go to
+// {endpoint}/openai/deployments/{deployment}/chat/completions?api-version=...
+// and authenticate with the api-key header.
+type AzureConfig struct {
+	// Deployment names the model deployment; when empty the request's model is used.
+	Deployment string
+	APIVersion string
+}
+
+// NewAzureClient creates a client for an Azure OpenAI resource endpoint
+// (e.g. https://my-resource.openai.azure.com).
+func NewAzureClient(apiKey, endpoint, deployment, apiVersion string) *Client {
+	if strings.TrimSpace(apiVersion) == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	return &Client{
+		APIKey:     apiKey,
+		HTTPClient: NewHTTPClient(ResponseTimeout),
+		Retry:      retry.Default(),
+		BaseURL:    strings.TrimRight(strings.TrimSpace(endpoint), "/"),
+		Azure:      &AzureConfig{Deployment: strings.TrimSpace(deployment), APIVersion: apiVersion},
+	}
+}
+
+// chatURL returns the chat completions URL for the deployment serving model.
+func (a *AzureConfig) chatURL(baseURL, model string) string {
+	deployment := a.Deployment
+	if deployment == "" {
+		deployment = model
+	}
+	return baseURL + "/openai/deployments/" + url.PathEscape(deployment) +
+		"/chat/completions?api-version=" + url.QueryEscape(a.APIVersion)
+}
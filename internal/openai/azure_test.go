@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureChatRoutingAndFallbacks(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/openai/deployments/my-gpt/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if v := r.URL.Query().Get("api-version"); v != "2025-01-01-preview" {
+			t.Errorf("unexpected api-version %q", v)
+		}
+		if r.Header.Get("api-key") != "az-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth headers: %v", r.Header)
+		}
+		var req ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.MaxTokens > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Unsupported parameter: 'max_tokens' is not supported with this model. Use 'max_completion_tokens' instead."}}`)
+			return
+		}
+		if req.MaxCompletionTokens != 64 {
+			t.Errorf("expected max_completion_tokens=64, got %d", req.MaxCompletionTokens)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"feat: azure"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewAzureClient("az-key", srv.URL+"/", "my-gpt", "2025-01-01-preview")
+	c.HTTPClient = srv.Client()
+	resp, err := c.Chat(context.Background(), ChatCompletionRequest{Model: "gpt-4o-mini", MaxTokens: 64})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "feat: azure" {
+		t.Fatalf("unexpected response: %#v", resp.Choices)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the max_tokens fallback to retry once, got %d calls", calls)
+	}
+}
+
+func TestAzureChatURLDefaults(t *testing.T) {
+	c := NewAzureClient("k", "https://res.openai.azure.com", "", "")
+	got := c.Azure.chatURL(c.BaseURL, "gpt-4o")
+	want := "https://res.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=" + DefaultAzureAPIVersion
+	if got != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+}
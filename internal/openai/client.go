@@ -2,45 +2,61 @@ package openai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/diesi/aic/internal/retry"
 )
 
+// ResponseTimeout is how long provider clients wait for a response to start.
+const ResponseTimeout = 60 * time.Second
+
+// NewHTTPClient returns an HTTP client for provider APIs that waits at most
+// headerTimeout for the response headers. Reading the body is not limited:
+// a streamed generation may run well past it while tokens keep arriving, and
+// is bounded by the request context instead.
+func NewHTTPClient(headerTimeout time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = headerTimeout
+	return &http.Client{Transport: t}
+}
+
 // Client is a minimal OpenAI API client.
 type Client struct {
 	APIKey     string
 	HTTPClient *http.Client
-	BaseURL    string
+	// Retry controls retries on rate limits and transient failures (zero value: none).
+	Retry   retry.Policy
+	BaseURL string
+	// Azure switches the client to Azure OpenAI routing and auth when non-nil.
+	Azure *AzureConfig
 }
 
 func NewClient(apiKey string) *Client {
 	return &Client{
 		APIKey:     apiKey,
-		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		HTTPClient: NewHTTPClient(ResponseTimeout),
+		Retry:      retry.Default(),
 		BaseURL:    "https://api.openai.com/v1",
 	}
 }
 
-func (c *Client) Chat(req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+// Chat sends a chat completion request. The request is bound to ctx so callers
+// can cancel it or attach a deadline.
+func (c *Client) Chat(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
 	attempt := 0
 	for {
 		attempt++
-		bodyBytes, err := json.Marshal(req)
+		httpReq, err := c.newChatRequest(ctx, req)
 		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
+			return nil, err
 		}
-		endpoint := c.BaseURL + "/chat/completions"
-		httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
-		if err != nil {
-			return nil, fmt.Errorf("new request: %w", err)
-		}
-		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-		httpReq.Header.Set("Content-Type", "application/json")
-		resp, err := c.HTTPClient.Do(httpReq)
+		resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
 		if err != nil {
 			return nil, fmt.Errorf("request failed: %w", err)
 		}
@@ -54,19 +70,10 @@ func (c *Client) Chat(req ChatCompletionRequest) (*ChatCompletionResponse, error
 		}
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
 			bodyStr := string(respBody)
-			if attempt < 3 {
-				if strings.Contains(bodyStr, "Unsupported parameter: 'max_tokens'") {
-					req.MaxCompletionTokens = req.MaxTokens
-					req.MaxTokens = 0
-					continue
-				}
-				if strings.Contains(bodyStr, "Unsupported value: 'temperature'") && req.Temperature != nil {
-					// remove temperature to use provider default
-					req.Temperature = nil
-					continue
-				}
+			if attempt < 3 && AdaptToError(&req, bodyStr) {
+				continue
 			}
-			return nil, fmt.Errorf("openai http %d: %s", resp.StatusCode, bodyStr)
+			return nil, &APIError{StatusCode: resp.StatusCode, Body: bodyStr}
 		}
 		var completion ChatCompletionResponse
 		if err := json.Unmarshal(respBody, &completion); err != nil {
@@ -74,26 +81,58 @@ func (c *Client) Chat(req ChatCompletionRequest) (*ChatCompletionResponse, error
 		}
 		completion.Raw = string(respBody)
 		if completion.Error.Message != "" {
-			return nil, fmt.Errorf("openai error: %s", completion.Error.Message)
+			return nil, &APIError{Message: completion.Error.Message}
 		}
 		// Handle empty content with finish_reason=length
-		if len(completion.Choices) > 0 {
-			allEmptyWithLength := true
-			for _, choice := range completion.Choices {
-				if choice.Message.Content != "" || choice.FinishReason != "length" {
-					allEmptyWithLength = false
-					break
-				}
-			}
-			if allEmptyWithLength && req.MaxCompletionTokens > 0 {
-				// Likely hit token limit before generating content. Retry with larger limit.
-				req.MaxCompletionTokens *= 2        // Double the tokens and retry
-				if req.MaxCompletionTokens > 8000 { // Safety cap
-					return &completion, nil
-				}
-				continue
+		if allEmptyWithLength(&completion) && req.MaxCompletionTokens > 0 {
+			// Likely hit token limit before generating content. Retry with larger limit.
+			req.MaxCompletionTokens *= 2        // Double the tokens and retry
+			if req.MaxCompletionTokens > 8000 { // Safety cap
+				return &completion, nil
 			}
+			continue
 		}
 		return &completion, nil
 	}
 }
+
+// newChatRequest builds a POST chat completions request for req with the
+// client's routing and authentication applied.
+func (c *Client) newChatRequest(ctx context.Context, req ChatCompletionRequest) (*http.Request, error) {
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	endpoint := c.BaseURL + "/chat/completions"
+	if c.Azure != nil {
+		endpoint = c.Azure.chatURL(c.BaseURL, req.Model)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	if c.Azure != nil {
+		httpReq.Header.Set("api-key", c.APIKey)
+	} else {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// AdaptToError applies compatibility tweaks to req based on an error response
+// body (e.g., models that reject max_tokens or a custom temperature).
+// It reports whether req was changed and the call is worth retrying.
+func AdaptToError(req *ChatCompletionRequest, body string) bool {
+	if strings.Contains(body, "Unsupported parameter: 'max_tokens'") && req.MaxTokens > 0 {
+		req.MaxCompletionTokens = req.MaxTokens
+		req.MaxTokens = 0
+		return true
+	}
+	if strings.Contains(body, "Unsupported value: 'temperature'") && req.Temperature != nil {
+		// remove temperature to use provider default
+		req.Temperature = nil
+		return true
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"testing"
@@ -40,7 +41,7 @@ func TestChatReadError(t *testing.T) {
 		})},
 		BaseURL: "http://example.com",
 	}
-	_, err := client.Chat(ChatCompletionRequest{Model: "gpt-3.5", Messages: []Message{{Role: "user", Content: "hi"}}})
+	_, err := client.Chat(context.Background(), ChatCompletionRequest{Model: "gpt-3.5", Messages: []Message{{Role: "user", Content: "hi"}}})
 	if err == nil || err.Error() != "read response body: read error" {
 		t.Fatalf("expected read error, got %v", err)
 	}
@@ -48,3 +49,19 @@ func TestChatReadError(t *testing.T) {
 		t.Fatalf("response body not closed")
 	}
 }
+
+func TestChatHonoursCanceledContext(t *testing.T) {
+	client := &Client{
+		APIKey: "test",
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, r.Context().Err()
+		})},
+		BaseURL: "http://example.com",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.Chat(ctx, ChatCompletionRequest{Model: "gpt-3.5", Messages: []Message{{Role: "user", Content: "hi"}}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
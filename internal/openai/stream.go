@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/diesi/aic/internal/retry"
+	"github.com/diesi/aic/internal/sse"
+)
+
+// ChatStream sends a streaming chat completion request and calls onDelta with
+// each content fragment as it arrives (choice is the choice index). The fully
+// assembled response is returned once the stream ends.
+func (c *Client) ChatStream(ctx context.Context, req ChatCompletionRequest, onDelta func(choice int, delta string)) (*ChatCompletionResponse, error) {
+	req.Stream = true
+	for attempt := 1; ; attempt++ {
+		httpReq, err := c.newChatRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Accept", "text/event-stream")
+		resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			respBody, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				return nil, fmt.Errorf("read response body: %w", readErr)
+			}
+			if attempt < 3 && AdaptToError(&req, string(respBody)) {
+				continue
+			}
+			return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		}
+		completion, err := ReadStream(resp, onDelta)
+		if err != nil {
+			return nil, err
+		}
+		if completion.Error.Message != "" {
+			return nil, &APIError{Message: completion.Error.Message}
+		}
+		if allEmptyWithLength(completion) && req.MaxCompletionTokens > 0 {
+			// Ran out of budget before any content; the non-streaming path knows how to grow it.
+			req.Stream = false
+			return c.Chat(ctx, req)
+		}
+		return completion, nil
+	}
+}
+
+// ReadStream consumes a chat completions response body and closes it.
+// Server-sent event bodies are decoded chunk by chunk, calling onDelta for each
+// content fragment. Servers that ignore stream=true and reply with a regular
+// JSON body are handled too: each choice is reported as a single delta.
+func ReadStream(resp *http.Response, onDelta func(choice int, delta string)) (*ChatCompletionResponse, error) {
+	defer resp.Body.Close()
+	if !strings.Contains(resp.Header.Get("Content-Type"), "event-stream") {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read response body: %w", err)
+		}
+		// Some servers stream without setting the content type; sniff for SSE framing.
+		if !bytes.HasPrefix(bytes.TrimSpace(respBody), []byte("data:")) {
+			var completion ChatCompletionResponse
+			if err := json.Unmarshal(respBody, &completion); err != nil {
+				return nil, fmt.Errorf("unmarshal response: %w", err)
+			}
+			completion.Raw = string(respBody)
+			for i, ch := range completion.Choices {
+				if ch.Message.Content != "" && onDelta != nil {
+					onDelta(i, ch.Message.Content)
+				}
+			}
+			return &completion, nil
+		}
+		return decodeStream(bytes.NewReader(respBody), onDelta)
+	}
+	return decodeStream(resp.Body, onDelta)
+}
+
+func decodeStream(r io.Reader, onDelta func(choice int, delta string)) (*ChatCompletionResponse, error) {
+	out := &ChatCompletionResponse{}
+	var raw strings.Builder
+	err := sse.Read(r, func(ev sse.Event) error {
+		if strings.TrimSpace(ev.Data) == "[DONE]" {
+			return sse.Done
+		}
+		raw.WriteString(ev.Data)
+		raw.WriteString("\n")
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			return fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error.Message != "" {
+			out.Error.Message = chunk.Error.Message
+			return sse.Done
+		}
+		for _, ch := range chunk.Choices {
+			for len(out.Choices) <= ch.Index {
+				out.Choices = append(out.Choices, Choice{Message: Message{Role: "assistant"}})
+			}
+			out.Choices[ch.Index].Message.Content += ch.Delta.Content
+			if ch.FinishReason != "" {
+				out.Choices[ch.Index].FinishReason = ch.FinishReason
+			}
+			if ch.Delta.Content != "" && onDelta != nil {
+				onDelta(ch.Index, ch.Delta.Content)
+			}
+		}
+		return nil
+	})
+	out.Raw = raw.String()
+	if err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	return out, nil
+}
+
+// allEmptyWithLength reports whether every choice came back empty because the
+// output token limit was reached.
+func allEmptyWithLength(c *ChatCompletionResponse) bool {
+	if len(c.Choices) == 0 {
+		return false
+	}
+	for _, choice := range c.Choices {
+		if choice.Message.Content != "" || choice.FinishReason != "length" {
+			return false
+		}
+	}
+	return true
+}
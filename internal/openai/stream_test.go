@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatStreamAssemblesChoices(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"content":"feat: "}},{"index":1,"delta":{"content":"fix: "}}]}`,
+		`data: {"choices":[{"index":1,"delta":{"content":"b"},"finish_reason":"stop"}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"a"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	client := &Client{
+		APIKey: "test",
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			b, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(b), `"stream":true`) {
+				t.Errorf("stream flag not sent: %s", b)
+			}
+			h := make(http.Header)
+			h.Set("Content-Type", "text/event-stream")
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body)), Header: h}, nil
+		})},
+		BaseURL: "http://example.com",
+	}
+	var order []string
+	resp, err := client.ChatStream(context.Background(), ChatCompletionRequest{Model: "m", N: 2}, func(choice int, delta string) {
+		order = append(order, delta)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[0].Message.Content != "feat: a" || resp.Choices[1].Message.Content != "fix: b" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if strings.Join(order, "|") != "feat: |fix: |b|a" {
+		t.Fatalf("unexpected delta order: %v", order)
+	}
+}
+
+func TestReadStreamPlainJSONFallback(t *testing.T) {
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	resp := &http.Response{StatusCode: 200, Header: h, Body: io.NopCloser(strings.NewReader(`{"choices":[{"message":{"content":"chore: x"},"finish_reason":"stop"}]}`))}
+	var got string
+	out, err := ReadStream(resp, func(choice int, delta string) { got += delta })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "chore: x" || out.Choices[0].Message.Content != "chore: x" {
+		t.Fatalf("unexpected result %q / %#v", got, out.Choices)
+	}
+}
+
+func TestHTTPClientLimitsOnlyTheWaitForHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-start" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 4; i++ {
+			fmt.Fprintf(w, "chunk %d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	client := NewHTTPClient(100 * time.Millisecond)
+
+	// The body takes longer than the timeout but starts right away.
+	resp, err := client.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || !strings.Contains(string(data), "chunk 3") {
+		t.Fatalf("stream cut off: %q, %v", data, err)
+	}
+	if _, err := client.Get(srv.URL + "/slow-start"); err == nil {
+		t.Fatal("expected a timeout waiting for the response headers")
+	}
+}
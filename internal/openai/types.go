@@ -1,5 +1,7 @@
 package openai
 
+import "fmt"
+
 // ChatCompletionRequest represents the OpenAI chat completions request payload.
 type ChatCompletionRequest struct {
 	Model               string    `json:"model"`
@@ -16,15 +18,45 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// Choice is a single completion choice in a (non-streamed) response.
+type Choice struct {
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
 type ChatCompletionResponse struct {
+	Choices []Choice `json:"choices"`
+	Error   struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Raw string `json:"-"`
+}
+
+// ChatCompletionChunk is one streamed event of a chat completion (stream=true).
+type ChatCompletionChunk struct {
 	Choices []struct {
-		Message struct {
+		Index int `json:"index"`
+		Delta struct {
 			Content string `json:"content"`
-		} `json:"message"`
+		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
-	Raw string `json:"-"`
+}
+
+// APIError is an error reported by the API, either as a non-2xx response
+// (StatusCode and raw Body) or inside an otherwise successful response (Message).
+type APIError struct {
+	StatusCode int
+	Body       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("openai http %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("openai error: %s", e.Message)
 }
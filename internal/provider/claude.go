@@ -2,119 +2,230 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/retry"
+	"github.com/diesi/aic/internal/sse"
 )
 
 // Claude implements the Provider interface using Anthropic's Claude API.
 type Claude struct {
 	APIKey     string
 	HTTPClient *http.Client
-	BaseURL    string
+	// Retry controls retries on rate limits and transient failures (zero value: none).
+	Retry   retry.Policy
+	BaseURL string
+	// Concurrency bounds parallel calls when several choices are requested.
+	Concurrency int
 }
 
 // NewClaude creates a new Claude provider.
 func NewClaude(apiKey string) *Claude {
 	return &Claude{
-		APIKey:     apiKey,
-		HTTPClient: &http.Client{Timeout: 60 * time.Second},
-		BaseURL:    "https://api.anthropic.com/v1",
+		APIKey:      apiKey,
+		HTTPClient:  openai.NewHTTPClient(openai.ResponseTimeout),
+		Retry:       retry.Default(),
+		BaseURL:     "https://api.anthropic.com/v1",
+		Concurrency: concurrencyFromEnv(),
 	}
 }
 
-func (c *Claude) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+// Chat sends one /messages call per requested choice, up to Concurrency at a
+// time, and merges the results in choice order. Failed calls are skipped as
+// long as at least one succeeds.
+func (c *Claude) Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
 	count := req.N
 	if count < 1 {
 		count = 1
 	}
-	choices := make([]string, 0, count)
-	rawParts := make([]string, 0, count)
+	choices, raws, err := fanOut(ctx, count, c.Concurrency, func(ctx context.Context, _ int) (string, string, error) {
+		return c.message(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CompletionResponse{Choices: choices, Raw: strings.Join(raws, "\n")}, nil
+}
 
-	for i := 0; i < count; i++ {
-		messages := []map[string]string{}
-		var system string
-		for _, m := range req.Messages {
-			if m.Role == "system" && system == "" {
-				system = m.Content
-				continue
-			}
-			messages = append(messages, map[string]string{"role": m.Role, "content": m.Content})
-		}
-		body := map[string]any{
-			"model":    req.Model,
-			"messages": messages,
-		}
-		if system != "" {
-			body["system"] = system
-		}
-		if req.MaxTokens > 0 {
-			body["max_tokens"] = req.MaxTokens
-		} else if req.MaxCompletionTokens > 0 {
-			body["max_tokens"] = req.MaxCompletionTokens
-		} else {
-			body["max_tokens"] = 256
-		}
-		if req.Temperature != nil {
-			body["temperature"] = req.Temperature
+// message performs a single /messages call and returns its text and raw body.
+func (c *Claude) message(ctx context.Context, req openai.ChatCompletionRequest) (string, string, error) {
+	httpReq, err := c.newMessagesRequest(ctx, req, false)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
+	if err != nil {
+		return "", "", networkError("claude", err)
+	}
+	respBody, readErr := io.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if readErr != nil {
+		return "", "", fmt.Errorf("read response body: %w", readErr)
+	}
+	if closeErr != nil {
+		return "", "", fmt.Errorf("close response body: %w", closeErr)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", newHTTPError("claude", resp.StatusCode, respBody)
+	}
+	var completion struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		return "", "", fmt.Errorf("unmarshal response: %w", err)
+	}
+	if completion.Error.Message != "" {
+		return "", "", newMessageError("claude", completion.Error.Message)
+	}
+	text := ""
+	for _, c := range completion.Content {
+		if c.Type == "text" {
+			text += c.Text
 		}
+	}
+	return text, string(respBody), nil
+}
 
-		bodyBytes, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
-		}
-		endpoint := c.BaseURL + "/messages"
-		httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
-		if err != nil {
-			return nil, fmt.Errorf("new request: %w", err)
-		}
-		httpReq.Header.Set("x-api-key", c.APIKey)
-		httpReq.Header.Set("content-type", "application/json")
-		httpReq.Header.Set("anthropic-version", "2023-06-01")
-		resp, err := c.HTTPClient.Do(httpReq)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
-		}
+// ChatStream streams one /messages call per requested choice, up to
+// Concurrency at a time, forwarding text deltas to onDelta with the choice
+// index. onDelta is never called concurrently.
+func (c *Claude) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	count := req.N
+	if count < 1 {
+		count = 1
+	}
+	var mu sync.Mutex
+	choices, raws, err := fanOut(ctx, count, c.Concurrency, func(ctx context.Context, i int) (string, string, error) {
+		delta := syncDelta(&mu, onDelta, i)
+		text, raw, err := c.messageStream(ctx, req, delta)
+		dropOnError(delta, err)
+		return text, raw, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CompletionResponse{Choices: choices, Raw: strings.Join(raws, "")}, nil
+}
+
+// messageStream performs a single streaming /messages call. Deltas are passed
+// to onDelta with choice index 0.
+func (c *Claude) messageStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (string, string, error) {
+	httpReq, err := c.newMessagesRequest(ctx, req, true)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
+	if err != nil {
+		return "", "", networkError("claude", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		respBody, readErr := io.ReadAll(resp.Body)
-		closeErr := resp.Body.Close()
 		if readErr != nil {
-			return nil, fmt.Errorf("read response body: %w", readErr)
+			return "", "", fmt.Errorf("read response body: %w", readErr)
 		}
-		if closeErr != nil {
-			return nil, fmt.Errorf("close response body: %w", closeErr)
-		}
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			return nil, fmt.Errorf("claude http %d: %s", resp.StatusCode, string(respBody))
-		}
-		var completion struct {
-			Content []struct {
+		return "", "", newHTTPError("claude", resp.StatusCode, respBody)
+	}
+	var text, raw strings.Builder
+	var streamErr string
+	err = sse.Read(resp.Body, func(ev sse.Event) error {
+		raw.WriteString(ev.Data)
+		raw.WriteString("\n")
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
 				Type string `json:"type"`
 				Text string `json:"text"`
-			} `json:"content"`
+			} `json:"delta"`
 			Error struct {
 				Message string `json:"message"`
 			} `json:"error"`
 		}
-		if err := json.Unmarshal(respBody, &completion); err != nil {
-			return nil, fmt.Errorf("unmarshal response: %w", err)
+		if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+			return fmt.Errorf("unmarshal stream event: %w", err)
 		}
-		if completion.Error.Message != "" {
-			return nil, fmt.Errorf("claude error: %s", completion.Error.Message)
-		}
-		text := ""
-		for _, c := range completion.Content {
-			if c.Type == "text" {
-				text += c.Text
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(Delta{Text: event.Delta.Text})
+				}
 			}
+		case "error":
+			streamErr = event.Error.Message
+			return sse.Done
+		case "message_stop":
+			return sse.Done
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("read stream: %w", err)
+	}
+	if streamErr != "" {
+		return "", "", newMessageError("claude", streamErr)
+	}
+	return text.String(), raw.String(), nil
+}
+
+// newMessagesRequest builds a POST /messages request for req. The first system
+// message becomes the top-level system prompt, as the Messages API expects.
+func (c *Claude) newMessagesRequest(ctx context.Context, req openai.ChatCompletionRequest, stream bool) (*http.Request, error) {
+	messages := []map[string]string{}
+	var system string
+	for _, m := range req.Messages {
+		if m.Role == "system" && system == "" {
+			system = m.Content
+			continue
 		}
-		choices = append(choices, text)
-		rawParts = append(rawParts, string(respBody))
+		messages = append(messages, map[string]string{"role": m.Role, "content": m.Content})
+	}
+	body := map[string]any{
+		"model":    req.Model,
+		"messages": messages,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	} else if req.MaxCompletionTokens > 0 {
+		body["max_tokens"] = req.MaxCompletionTokens
+	} else {
+		body["max_tokens"] = 256
+	}
+	if req.Temperature != nil {
+		body["temperature"] = req.Temperature
+	}
+	if stream {
+		body["stream"] = true
 	}
 
-	return &CompletionResponse{Choices: choices, Raw: strings.Join(rawParts, "\n")}, nil
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	endpoint := c.BaseURL + "/messages"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	httpReq.Header.Set("x-api-key", c.APIKey)
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	return httpReq, nil
 }
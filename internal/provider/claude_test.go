@@ -1,11 +1,16 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/retry"
 )
 
 type errReader struct {
@@ -42,7 +47,7 @@ func TestClaudeChatReadError(t *testing.T) {
 		})},
 		BaseURL: "http://example.com",
 	}
-	_, err := client.Chat(openai.ChatCompletionRequest{Model: "claude-3-sonnet-20240229", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	_, err := client.Chat(context.Background(), openai.ChatCompletionRequest{Model: "claude-3-sonnet-20240229", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err == nil || err.Error() != "read response body: read error" {
 		t.Fatalf("expected read error, got %v", err)
 	}
@@ -50,3 +55,30 @@ func TestClaudeChatReadError(t *testing.T) {
 		t.Fatalf("response body not closed")
 	}
 }
+
+func TestClaudeChatRetriesOverloaded(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("retry-after", "0")
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"content":[{"type":"text","text":"feat: ok"}]}`))
+	}))
+	defer srv.Close()
+	client := &Claude{
+		APIKey:     "test",
+		HTTPClient: srv.Client(),
+		Retry:      retry.Policy{MaxAttempts: 3, MaxElapsed: time.Second, BaseDelay: time.Millisecond},
+		BaseURL:    srv.URL,
+	}
+	resp, err := client.Chat(context.Background(), openai.ChatCompletionRequest{Model: "claude", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 || len(resp.Choices) != 1 || resp.Choices[0] != "feat: ok" {
+		t.Fatalf("calls=%d choices=%#v", calls, resp.Choices)
+	}
+}
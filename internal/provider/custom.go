@@ -2,16 +2,18 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/diesi/aic/internal/config"
 	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/retry"
 )
 
 // Custom implements the Provider interface using a configurable OpenAI-compatible server.
@@ -20,7 +22,11 @@ import (
 type Custom struct {
 	APIKey     string
 	HTTPClient *http.Client
-	BaseURL    string
+	// Retry controls retries on rate limits and transient failures (zero value: none).
+	Retry   retry.Policy
+	BaseURL string
+	// Concurrency bounds parallel calls when emulating n (see emulateN).
+	Concurrency int
 	// Paths (joined with BaseURL)
 	ChatCompletionsPath string
 	CompletionsPath     string
@@ -42,7 +48,9 @@ func NewCustom(apiKey string) *Custom {
 	base := envOr(config.Get(config.EnvCustomBaseURL), "http://127.0.0.1:1234")
 	return &Custom{
 		APIKey:              apiKey,
-		HTTPClient:          &http.Client{Timeout: 60 * time.Second},
+		HTTPClient:          openai.NewHTTPClient(openai.ResponseTimeout),
+		Retry:               retry.Default(),
+		Concurrency:         concurrencyFromEnv(),
 		BaseURL:             strings.TrimRight(base, "/"),
 		ChatCompletionsPath: envOr(config.Get(config.EnvCustomChatCompletionsPath), "/v1/chat/completions"),
 		CompletionsPath:     envOr(config.Get(config.EnvCustomCompletionsPath), "/v1/completions"),
@@ -62,113 +70,159 @@ func (c *Custom) endpoint(path string) string {
 }
 
 // ensureModel populates req.Model by querying the models endpoint when needed.
-func (c *Custom) ensureModel(req *openai.ChatCompletionRequest) error {
+func (c *Custom) ensureModel(ctx context.Context, req *openai.ChatCompletionRequest) error {
 	m := strings.TrimSpace(req.Model)
 	if m != "" && strings.ToLower(m) != "auto" {
 		return nil
 	}
-	// GET models
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("could not determine model from /models response")
+	}
+	req.Model = models[0]
+	return nil
+}
+
+// ListModels returns the model IDs served by the models endpoint, in the
+// server's order.
+func (c *Custom) ListModels(ctx context.Context) ([]string, error) {
 	url := c.endpoint(c.ModelsPath)
-	httpReq, err := http.NewRequest("GET", url, nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("new request: %w", err)
+		return nil, fmt.Errorf("new request: %w", err)
 	}
 	if c.APIKey != "" {
 		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
 	}
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, networkError("custom", err)
 	}
 	body, readErr := io.ReadAll(resp.Body)
 	closeErr := resp.Body.Close()
 	if readErr != nil {
-		return fmt.Errorf("read response body: %w", readErr)
+		return nil, fmt.Errorf("read response body: %w", readErr)
 	}
 	if closeErr != nil {
-		return fmt.Errorf("close response body: %w", closeErr)
+		return nil, fmt.Errorf("close response body: %w", closeErr)
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("custom models http %d: %s", resp.StatusCode, string(body))
+		return nil, newHTTPError("custom", resp.StatusCode, body)
 	}
-	// Try OpenAI-compatible shape: { data: [ { id: "..." }, ... ] }
-	var models struct {
-		Data []struct {
-			ID string `json:"id"`
-		} `json:"data"`
+	type model struct {
+		ID string `json:"id"`
 	}
-	if err := json.Unmarshal(body, &models); err == nil && len(models.Data) > 0 && strings.TrimSpace(models.Data[0].ID) != "" {
-		req.Model = models.Data[0].ID
-		return nil
+	// Try OpenAI-compatible shape: { data: [ { id: "..." }, ... ] },
+	// then a simple array [ { "id": "..." } ].
+	var list []model
+	var wrapped struct {
+		Data []model `json:"data"`
 	}
-	// Fallback: try simple array [ { "id": "..." } ]
-	var arr []struct {
-		ID string `json:"id"`
+	if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Data) > 0 {
+		list = wrapped.Data
+	} else if err := json.Unmarshal(body, &list); err != nil {
+		return nil, nil
 	}
-	if err := json.Unmarshal(body, &arr); err == nil && len(arr) > 0 && strings.TrimSpace(arr[0].ID) != "" {
-		req.Model = arr[0].ID
-		return nil
+	var ids []string
+	for _, m := range list {
+		if id := strings.TrimSpace(m.ID); id != "" {
+			ids = append(ids, id)
+		}
 	}
-	return fmt.Errorf("could not determine model from /models response")
+	return ids, nil
 }
 
 // Chat sends a chat completion request to the custom server and maps the response.
-func (c *Custom) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+// Servers that ignore n and answer with a single choice get the missing
+// choices filled in by additional single-choice requests (see emulateN).
+func (c *Custom) Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+	if err := c.ensureModel(ctx, &req); err != nil {
+		return nil, err
+	}
+	out, served, err := c.chatOnce(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return emulateN(ctx, c.Concurrency, req, out, served, func(ctx context.Context, _ int, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+		out, _, err := c.chatOnce(ctx, req)
+		return out, err
+	})
+}
+
+// ChatStream streams a chat completion from the custom server. Reasoning
+// (<think>...</think>) sections are filtered out before deltas reach onDelta.
+// Emulated choices (see emulateN) stream with choice indices after the first.
+func (c *Custom) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	if err := c.ensureModel(ctx, &req); err != nil {
+		return nil, err
+	}
+	out, served, err := c.chatStreamOnce(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	return emulateN(ctx, c.Concurrency, req, out, served, func(ctx context.Context, i int, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+		delta := syncDelta(&mu, onDelta, 1+i)
+		out, _, err := c.chatStreamOnce(ctx, req, delta)
+		dropOnError(delta, err)
+		return out, err
+	})
+}
+
+// chatOnce performs a single chat completion request. Besides the mapped
+// response it reports how many choices the server actually returned, which
+// may differ from the mapped ones after reasoning choices are merged.
+func (c *Custom) chatOnce(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, int, error) {
 	attempt := 0
 	for {
 		attempt++
 		// Auto-resolve model if needed
-		if err := c.ensureModel(&req); err != nil {
-			return nil, err
+		if err := c.ensureModel(ctx, &req); err != nil {
+			return nil, 0, err
 		}
 		bodyBytes, err := json.Marshal(req)
 		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
+			return nil, 0, fmt.Errorf("marshal request: %w", err)
 		}
 		endpoint := c.endpoint(c.ChatCompletionsPath)
-		httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
 		if err != nil {
-			return nil, fmt.Errorf("new request: %w", err)
+			return nil, 0, fmt.Errorf("new request: %w", err)
 		}
 		if c.APIKey != "" {
 			httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
-		resp, err := c.HTTPClient.Do(httpReq)
+		resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
 		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
+			return nil, 0, networkError("custom", err)
 		}
 		respBody, readErr := io.ReadAll(resp.Body)
 		closeErr := resp.Body.Close()
 		if readErr != nil {
-			return nil, fmt.Errorf("read response body: %w", readErr)
+			return nil, 0, fmt.Errorf("read response body: %w", readErr)
 		}
 		if closeErr != nil {
-			return nil, fmt.Errorf("close response body: %w", closeErr)
+			return nil, 0, fmt.Errorf("close response body: %w", closeErr)
 		}
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
 			bodyStr := string(respBody)
 			// try minor compatibility adjustments based on error text
-			if attempt < 3 {
-				if strings.Contains(bodyStr, "Unsupported parameter: 'max_tokens'") {
-					req.MaxCompletionTokens = req.MaxTokens
-					req.MaxTokens = 0
-					continue
-				}
-				if strings.Contains(bodyStr, "Unsupported value: 'temperature'") && req.Temperature != nil {
-					req.Temperature = nil
-					continue
-				}
+			if attempt < 3 && openai.AdaptToError(&req, bodyStr) {
+				continue
 			}
-			return nil, fmt.Errorf("custom http %d: %s", resp.StatusCode, bodyStr)
+			return nil, 0, newHTTPError("custom", resp.StatusCode, respBody)
 		}
 		var completion openai.ChatCompletionResponse
 		if err := json.Unmarshal(respBody, &completion); err != nil {
-			return nil, fmt.Errorf("unmarshal response: %w", err)
+			return nil, 0, fmt.Errorf("unmarshal response: %w", err)
 		}
 		completion.Raw = string(respBody)
 		if completion.Error.Message != "" {
-			return nil, fmt.Errorf("custom error: %s", completion.Error.Message)
+			return nil, 0, newMessageError("custom", completion.Error.Message)
 		}
 		// If server splits reasoning vs answer across choices, merge and strip reasoning blocks.
 		joined := ""
@@ -179,7 +233,7 @@ func (c *Custom) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 			joined += ch.Message.Content
 		}
 		if s := stripReasoning(joined); strings.TrimSpace(s) != "" {
-			return &CompletionResponse{Choices: []string{strings.TrimSpace(s)}, Raw: completion.Raw}, nil
+			return &CompletionResponse{Choices: []string{strings.TrimSpace(s)}, Raw: completion.Raw}, len(completion.Choices), nil
 		}
 		// Handle empty content with finish_reason=length similarly
 		if len(completion.Choices) > 0 {
@@ -198,7 +252,7 @@ func (c *Custom) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 					for _, ch := range completion.Choices {
 						out.Choices = append(out.Choices, ch.Message.Content)
 					}
-					return out, nil
+					return out, len(completion.Choices), nil
 				}
 				continue
 			}
@@ -212,8 +266,173 @@ func (c *Custom) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 			}
 			out.Choices = append(out.Choices, cleaned)
 		}
-		return out, nil
+		return out, len(completion.Choices), nil
+	}
+}
+
+// chatStreamOnce performs a single streaming request. Like chatOnce it also
+// reports how many choices the server returned.
+func (c *Custom) chatStreamOnce(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, int, error) {
+	if err := c.ensureModel(ctx, &req); err != nil {
+		return nil, 0, err
+	}
+	req.Stream = true
+	for attempt := 1; ; attempt++ {
+		bodyBytes, err := json.Marshal(req)
+		if err != nil {
+			return nil, 0, fmt.Errorf("marshal request: %w", err)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(c.ChatCompletionsPath), bytes.NewBuffer(bodyBytes))
+		if err != nil {
+			return nil, 0, fmt.Errorf("new request: %w", err)
+		}
+		if c.APIKey != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Accept", "text/event-stream")
+		resp, err := retry.Do(c.HTTPClient, httpReq, c.Retry)
+		if err != nil {
+			return nil, 0, networkError("custom", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			respBody, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				return nil, 0, fmt.Errorf("read response body: %w", readErr)
+			}
+			if attempt < 3 && openai.AdaptToError(&req, string(respBody)) {
+				continue
+			}
+			return nil, 0, newHTTPError("custom", resp.StatusCode, respBody)
+		}
+		filters := map[int]*thinkFilter{}
+		completion, err := openai.ReadStream(resp, func(choice int, delta string) {
+			f := filters[choice]
+			if f == nil {
+				f = &thinkFilter{}
+				filters[choice] = f
+			}
+			if visible := f.Write(delta); visible != "" && onDelta != nil {
+				onDelta(Delta{Choice: choice, Text: visible})
+			}
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		for choice, f := range filters {
+			if rest := f.Flush(); rest != "" && onDelta != nil {
+				onDelta(Delta{Choice: choice, Text: rest})
+			}
+		}
+		if completion.Error.Message != "" {
+			return nil, 0, newMessageError("custom", completion.Error.Message)
+		}
+		// Mirror Chat: merge choices and strip reasoning for the final result.
+		joined := ""
+		for i, ch := range completion.Choices {
+			if i > 0 {
+				joined += "\n"
+			}
+			joined += ch.Message.Content
+		}
+		if s := stripReasoning(joined); strings.TrimSpace(s) != "" {
+			return &CompletionResponse{Choices: []string{strings.TrimSpace(s)}, Raw: completion.Raw}, len(completion.Choices), nil
+		}
+		// Nothing usable was streamed (e.g., budget spent on reasoning); Chat knows how to recover.
+		req.Stream = false
+		return c.chatOnce(ctx, req)
+	}
+}
+
+// thinkFilter removes <think>...</think> sections from text that arrives in
+// fragments. Tags split across fragments are held back until complete.
+type thinkFilter struct {
+	inThink bool
+	pending string
+}
+
+// Write consumes the next fragment and returns the visible part of it.
+func (f *thinkFilter) Write(s string) string {
+	s = f.pending + s
+	f.pending = ""
+	var out strings.Builder
+	for s != "" {
+		lower := asciiLower(s)
+		if f.inThink {
+			i := strings.Index(lower, "</think")
+			if i < 0 {
+				f.pending = partialTagSuffix(s)
+				return out.String()
+			}
+			j := strings.IndexByte(s[i:], '>')
+			if j < 0 {
+				f.pending = s[i:]
+				return out.String()
+			}
+			s = s[i+j+1:]
+			f.inThink = false
+			continue
+		}
+		open := strings.Index(lower, "<think")
+		closing := strings.Index(lower, "</think")
+		i := open
+		if closing >= 0 && (i < 0 || closing < i) {
+			i = closing
+		}
+		if i < 0 {
+			keep := partialTagSuffix(s)
+			out.WriteString(s[:len(s)-len(keep)])
+			f.pending = keep
+			return out.String()
+		}
+		out.WriteString(s[:i])
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			f.pending = s[i:]
+			return out.String()
+		}
+		s = s[i+j+1:]
+		// A stray closing tag is dropped; an opening tag starts a hidden section.
+		f.inThink = i == open
+	}
+	return out.String()
+}
+
+// Flush returns any held-back text once the stream has ended.
+func (f *thinkFilter) Flush() string {
+	rest := f.pending
+	f.pending = ""
+	if f.inThink {
+		return ""
+	}
+	return rest
+}
+
+// partialTagSuffix returns the trailing part of s that may be the start of a
+// (closing) think tag, e.g. "<thi" or "</".
+func partialTagSuffix(s string) string {
+	i := strings.LastIndexByte(s, '<')
+	if i < 0 || strings.IndexByte(s[i:], '>') >= 0 {
+		return ""
+	}
+	tail := asciiLower(s[i:])
+	if strings.HasPrefix("<think", tail) || strings.HasPrefix("</think", tail) ||
+		strings.HasPrefix(tail, "<think") || strings.HasPrefix(tail, "</think") {
+		return s[i:]
+	}
+	return ""
+}
+
+// asciiLower lower-cases ASCII letters only, keeping byte offsets stable.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
 	}
+	return string(b)
 }
 
 var (
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -36,7 +37,7 @@ func TestCustomChatReadError(t *testing.T) {
 	p.HTTPClient = &http.Client{Transport: roundTripFuncCustom(func(r *http.Request) (*http.Response, error) {
 		return &http.Response{StatusCode: 200, Body: er, Header: make(http.Header)}, nil
 	})}
-	_, err := p.Chat(openai.ChatCompletionRequest{Model: "test-model", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	_, err := p.Chat(context.Background(), openai.ChatCompletionRequest{Model: "test-model", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err == nil || err.Error() != "read response body: read error" {
 		t.Fatalf("expected read error, got %v", err)
 	}
@@ -69,7 +70,7 @@ func TestCustomModelAuto(t *testing.T) {
 		}
 	})}
 	// Pass empty model to force auto
-	_, err := p.Chat(openai.ChatCompletionRequest{Model: "", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	_, err := p.Chat(context.Background(), openai.ChatCompletionRequest{Model: "", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -94,7 +95,7 @@ func TestCustomStripsThinkAndMergesChoices(t *testing.T) {
 		}
 		return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("not found")), Header: make(http.Header)}, nil
 	})}
-	resp, err := p.Chat(openai.ChatCompletionRequest{Model: "auto", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	resp, err := p.Chat(context.Background(), openai.ChatCompletionRequest{Model: "auto", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -117,7 +118,7 @@ func TestCustomStripsUnbalancedThinkTag(t *testing.T) {
 		}
 		return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader("not found")), Header: make(http.Header)}, nil
 	})}
-	resp, err := p.Chat(openai.ChatCompletionRequest{Model: "auto", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	resp, err := p.Chat(context.Background(), openai.ChatCompletionRequest{Model: "auto", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -136,7 +137,7 @@ func TestCustomChatEndpointUsesEnv(t *testing.T) {
 		body := io.NopCloser(strings.NewReader(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
 		return &http.Response{StatusCode: 200, Body: body, Header: make(http.Header)}, nil
 	})}
-	_, err := p.Chat(openai.ChatCompletionRequest{Model: "test-model", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	_, err := p.Chat(context.Background(), openai.ChatCompletionRequest{Model: "test-model", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
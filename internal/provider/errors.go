@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+// Category classifies provider failures so callers can react (and hint) without
+// matching on error strings.
+type Category string
+
+const (
+	CategoryUnknown       Category = "unknown"
+	CategoryAuth          Category = "auth"
+	CategoryRateLimit     Category = "rate-limit"
+	CategoryQuota         Category = "quota"
+	CategoryContextLength Category = "context-length"
+	CategoryModelNotFound Category = "model-not-found"
+	CategoryNetwork       Category = "network"
+	CategoryServer        Category = "server"
+)
+
+// Error is a structured provider failure.
+type Error struct {
+	Provider string   // openai, claude, gemini, custom, ...
+	Status   int      // HTTP status (0 if no response was received)
+	Category Category // classification of the failure
+	Message  string   // vendor error message (parsed from the body when possible)
+	Code     string   // vendor error code/type, if any (e.g. invalid_api_key)
+	Body     string   // raw response body
+	Err      error    // underlying error (network failures)
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("%s request failed: %v", e.Provider, e.Err)
+	case e.Status > 0:
+		return fmt.Sprintf("%s http %d: %s", e.Provider, e.Status, msg)
+	default:
+		return fmt.Sprintf("%s error: %s", e.Provider, msg)
+	}
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// MissingKeyError reports that the API key environment variable for providerName is unset.
+func MissingKeyError(providerName, envVar string) *Error {
+	return &Error{Provider: providerName, Category: CategoryAuth, Message: "missing " + envVar}
+}
+
+// newHTTPError builds an Error from a non-2xx response, parsing the vendor's
+// error JSON (OpenAI, Anthropic, Gemini and common OpenAI-compatible servers).
+func newHTTPError(providerName string, status int, body []byte) *Error {
+	e := &Error{Provider: providerName, Status: status, Body: string(body)}
+	e.Message, e.Code = parseVendorError(body)
+	e.Category = categorize(status, e.Code, e.Message)
+	return e
+}
+
+// newMessageError builds an Error for an error reported inside a 2xx response
+// or mid-stream.
+func newMessageError(providerName, message string) *Error {
+	return &Error{Provider: providerName, Message: message, Category: categorize(0, "", message)}
+}
+
+// networkError wraps a transport failure. Caller cancellation is passed through
+// untouched so it can be recognised with errors.Is(err, context.Canceled).
+func networkError(providerName string, err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	return &Error{Provider: providerName, Category: CategoryNetwork, Err: err}
+}
+
+// fromOpenAI converts errors returned by the openai client into *Error.
+func fromOpenAI(providerName string, err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode > 0 {
+			return newHTTPError(providerName, apiErr.StatusCode, []byte(apiErr.Body))
+		}
+		return newMessageError(providerName, apiErr.Message)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return networkError(providerName, err)
+	}
+	return err
+}
+
+// parseVendorError extracts message and code from the known error shapes:
+//
+//	OpenAI:    {"error":{"message":"...","type":"...","code":"invalid_api_key"}}
+//	Anthropic: {"type":"error","error":{"type":"authentication_error","message":"..."}}
+//	Gemini:    {"error":{"code":400,"message":"...","status":"INVALID_ARGUMENT","details":[{"reason":"API_KEY_INVALID"}]}}
+//	Others:    {"error":"..."} or {"message":"..."} / {"detail":"..."}
+func parseVendorError(body []byte) (message, code string) {
+	var env struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return strings.TrimSpace(string(body)), ""
+	}
+	var asString string
+	if len(env.Error) > 0 && json.Unmarshal(env.Error, &asString) == nil {
+		return asString, ""
+	}
+	var obj struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+		Status  string          `json:"status"`
+		Details []struct {
+			Reason string `json:"reason"`
+		} `json:"details"`
+	}
+	if len(env.Error) > 0 && json.Unmarshal(env.Error, &obj) == nil {
+		message = obj.Message
+		var c string
+		if json.Unmarshal(obj.Code, &c) == nil && c != "" {
+			code = c
+		}
+		for _, d := range obj.Details {
+			if d.Reason != "" && code == "" {
+				code = d.Reason
+			}
+		}
+		if code == "" {
+			code = obj.Type
+		}
+		if code == "" {
+			code = obj.Status
+		}
+	}
+	if message == "" {
+		message = env.Message
+	}
+	if message == "" {
+		message = env.Detail
+	}
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	return message, code
+}
+
+// categorize maps status, vendor code and message to a Category.
+func categorize(status int, code, message string) Category {
+	c := strings.ToLower(code)
+	m := strings.ToLower(message)
+	has := func(s string, subs ...string) bool {
+		for _, sub := range subs {
+			if strings.Contains(s, sub) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has(c, "invalid_api_key", "authentication_error", "permission_error", "api_key_invalid", "unauthenticated", "permission_denied") ||
+		has(m, "api key not valid", "incorrect api key", "invalid api key", "invalid x-api-key", "invalid_api_key"):
+		return CategoryAuth
+	case has(c, "insufficient_quota", "billing") || has(m, "credit balance", "exceeded your current quota", "billing"):
+		return CategoryQuota
+	case has(c, "context_length_exceeded", "string_above_max_length") ||
+		has(m, "context length", "context window", "maximum context", "prompt is too long", "too many tokens", "exceeds the maximum number of tokens", "input token count"):
+		return CategoryContextLength
+	case has(c, "model_not_found", "not_found_error") ||
+		(has(m, "model") && has(m, "not found", "does not exist", "not supported", "no such model")):
+		return CategoryModelNotFound
+	case has(c, "rate_limit", "resource_exhausted") || has(m, "rate limit", "too many requests"):
+		return CategoryRateLimit
+	case has(c, "overloaded_error", "api_error", "internal", "unavailable") || has(m, "overloaded"):
+		return CategoryServer
+	}
+	switch {
+	case status == 401 || status == 403:
+		return CategoryAuth
+	case status == 429:
+		return CategoryRateLimit
+	case status == 404:
+		return CategoryModelNotFound
+	case status == 413:
+		return CategoryContextLength
+	case status >= 500:
+		return CategoryServer
+	}
+	return CategoryUnknown
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+func TestNewHTTPErrorVendorBodies(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		status   int
+		body     string
+		category Category
+		message  string
+		code     string
+	}{
+		{"openai invalid key", "openai", 401, `{"error":{"message":"Incorrect API key provided: sk-abc.","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`, CategoryAuth, "Incorrect API key provided: sk-abc.", "invalid_api_key"},
+		{"openai rate limit", "openai", 429, `{"error":{"message":"Rate limit reached for gpt-4o-mini on requests per min.","type":"requests","code":"rate_limit_exceeded"}}`, CategoryRateLimit, "Rate limit reached for gpt-4o-mini on requests per min.", "rate_limit_exceeded"},
+		{"openai quota", "openai", 429, `{"error":{"message":"You exceeded your current quota, please check your plan and billing details.","type":"insufficient_quota","code":"insufficient_quota"}}`, CategoryQuota, "You exceeded your current quota, please check your plan and billing details.", "insufficient_quota"},
+		{"openai context", "openai", 400, `{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`, CategoryContextLength, "This model's maximum context length is 128000 tokens.", "context_length_exceeded"},
+		{"openai model", "openai", 404, `{"error":{"message":"The model 'gpt-9' does not exist or you do not have access to it.","type":"invalid_request_error","code":"model_not_found"}}`, CategoryModelNotFound, "The model 'gpt-9' does not exist or you do not have access to it.", "model_not_found"},
+		{"openai null code", "openai", 500, `{"error":{"message":"The server had an error","type":"server_error","code":null}}`, CategoryServer, "The server had an error", "server_error"},
+		{"claude auth", "claude", 401, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, CategoryAuth, "invalid x-api-key", "authentication_error"},
+		{"claude overloaded", "claude", 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, CategoryServer, "Overloaded", "overloaded_error"},
+		{"claude rate limit", "claude", 429, `{"type":"error","error":{"type":"rate_limit_error","message":"Number of request tokens has exceeded your per-minute rate limit"}}`, CategoryRateLimit, "Number of request tokens has exceeded your per-minute rate limit", "rate_limit_error"},
+		{"claude credits", "claude", 400, `{"type":"error","error":{"type":"invalid_request_error","message":"Your credit balance is too low to access the Anthropic API."}}`, CategoryQuota, "Your credit balance is too low to access the Anthropic API.", "invalid_request_error"},
+		{"claude prompt too long", "claude", 400, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`, CategoryContextLength, "prompt is too long: 210000 tokens > 200000 maximum", "invalid_request_error"},
+		{"claude model", "claude", 404, `{"type":"error","error":{"type":"not_found_error","message":"model: claude-9"}}`, CategoryModelNotFound, "model: claude-9", "not_found_error"},
+		{"gemini bad key", "gemini", 400, `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"API_KEY_INVALID"}]}}`, CategoryAuth, "API key not valid. Please pass a valid API key.", "API_KEY_INVALID"},
+		{"gemini exhausted", "gemini", 429, `{"error":{"code":429,"message":"Resource has been exhausted (e.g. check quota).","status":"RESOURCE_EXHAUSTED"}}`, CategoryRateLimit, "Resource has been exhausted (e.g. check quota).", "RESOURCE_EXHAUSTED"},
+		{"gemini model", "gemini", 404, `{"error":{"code":404,"message":"models/gemini-9 is not found for API version v1beta, or is not supported for generateContent.","status":"NOT_FOUND"}}`, CategoryModelNotFound, "models/gemini-9 is not found for API version v1beta, or is not supported for generateContent.", "NOT_FOUND"},
+		{"custom string error", "custom", 404, `{"error":"model 'llama9' not found"}`, CategoryModelNotFound, "model 'llama9' not found", ""},
+		{"custom plain text", "custom", 502, `Bad Gateway`, CategoryServer, "Bad Gateway", ""},
+	}
+	for _, c := range cases {
+		e := newHTTPError(c.provider, c.status, []byte(c.body))
+		if e.Category != c.category || e.Message != c.message || e.Code != c.code || e.Status != c.status || e.Provider != c.provider {
+			t.Errorf("%s: got category=%s message=%q code=%q", c.name, e.Category, e.Message, e.Code)
+		}
+	}
+}
+
+func TestFromOpenAIConvertsAPIError(t *testing.T) {
+	err := fromOpenAI("openai", &openai.APIError{StatusCode: 401, Body: `{"error":{"message":"bad","code":"invalid_api_key"}}`})
+	var perr *Error
+	if !errors.As(err, &perr) || perr.Category != CategoryAuth || perr.Error() != "openai http 401: bad" {
+		t.Fatalf("unexpected conversion: %#v", err)
+	}
+}
+
+func TestNetworkErrorKeepsCancellation(t *testing.T) {
+	if err := networkError("claude", context.Canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancellation lost: %v", err)
+	}
+	var perr *Error
+	if err := networkError("claude", errors.New("dial tcp: connection refused")); !errors.As(err, &perr) || perr.Category != CategoryNetwork {
+		t.Fatalf("expected network category, got %v", err)
+	}
+}
+
+func TestGeminiChatReturnsTypedError(t *testing.T) {
+	client := &Gemini{
+		APIKey: "bad",
+		HTTPClient: &http.Client{Transport: roundTripFunc2(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 400, Body: nopBody(`{"error":{"code":400,"message":"API key not valid.","status":"INVALID_ARGUMENT","details":[{"reason":"API_KEY_INVALID"}]}}`), Header: make(http.Header)}, nil
+		})},
+		BaseURL: "http://example.com",
+	}
+	_, err := client.Chat(context.Background(), openai.ChatCompletionRequest{Model: "gemini-1.5-flash", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	var perr *Error
+	if !errors.As(err, &perr) || perr.Category != CategoryAuth || perr.Provider != "gemini" {
+		t.Fatalf("expected typed auth error, got %v", err)
+	}
+}
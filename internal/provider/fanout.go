@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/openai"
+)
+
+// defaultConcurrency is the number of parallel calls used when emulating
+// multiple choices with single-choice requests (AIC_CONCURRENCY overrides).
+const defaultConcurrency = 4
+
+// concurrencyFromEnv returns the fan-out worker limit from AIC_CONCURRENCY.
+func concurrencyFromEnv() int {
+	return config.IntInRange(config.EnvAICConcurrency, defaultConcurrency, 1, 10)
+}
+
+// fanResult is the outcome of a single fanned-out call.
+type fanResult struct {
+	text string
+	raw  string
+	err  error
+}
+
+// fanOut runs call for indices 0..n-1 with at most limit calls in flight and
+// returns the successful texts and raw bodies in index order, so the merged
+// result does not depend on completion order. Failed calls are dropped; an
+// error is returned only when every call failed (or ctx was canceled).
+//
+// Rate-limit feedback lowers the pressure: when a call fails with
+// CategoryRateLimit the limit is halved (down to 1) and that call is queued
+// once more.
+func fanOut(ctx context.Context, n, limit int, call func(ctx context.Context, i int) (text, raw string, err error)) ([]string, []string, error) {
+	if limit < 1 {
+		limit = 1
+	}
+	results := make([]fanResult, n)
+	queue := make([]int, n)
+	for i := range queue {
+		queue[i] = i
+	}
+	requeued := map[int]bool{}
+	active := 0
+
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	var wg sync.WaitGroup
+
+	mu.Lock()
+	for len(queue) > 0 || active > 0 {
+		if len(queue) == 0 || active >= limit {
+			cond.Wait()
+			continue
+		}
+		i := queue[0]
+		queue = queue[1:]
+		active++
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			text, raw, err := call(ctx, i)
+			mu.Lock()
+			defer mu.Unlock()
+			defer cond.Broadcast()
+			active--
+			var perr *Error
+			if errors.As(err, &perr) && perr.Category == CategoryRateLimit && ctx.Err() == nil {
+				if limit > 1 {
+					limit /= 2
+				}
+				if !requeued[i] {
+					requeued[i] = true
+					queue = append(queue, i)
+					return
+				}
+			}
+			results[i] = fanResult{text: text, raw: raw, err: err}
+		}(i)
+	}
+	mu.Unlock()
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	texts := make([]string, 0, n)
+	raws := make([]string, 0, n)
+	var firstErr error
+	for _, r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		texts = append(texts, r.text)
+		raws = append(raws, r.raw)
+	}
+	if len(texts) == 0 && firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return texts, raws, nil
+}
+
+// emulateN tops up first with extra single-choice calls when req asked for
+// several choices but the server returned only one (served) that does not
+// already hold enough suggestion lines. The extra calls run through fanOut
+// with at most limit in flight; any that fail are dropped and first is always
+// kept.
+func emulateN(ctx context.Context, limit int, req openai.ChatCompletionRequest, first *CompletionResponse, served int,
+	call func(ctx context.Context, i int, req openai.ChatCompletionRequest) (*CompletionResponse, error)) (*CompletionResponse, error) {
+	missing := req.N - countLines(first.Choices)
+	if req.N <= 1 || served != 1 || missing <= 0 {
+		return first, nil
+	}
+	req.N = 1
+	texts, raws, err := fanOut(ctx, missing, limit, func(ctx context.Context, i int) (string, string, error) {
+		out, err := call(ctx, i, req)
+		if err != nil {
+			return "", "", err
+		}
+		return strings.Join(out.Choices, "\n"), out.Raw, nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return first, nil
+	}
+	merged := &CompletionResponse{Choices: append([]string{}, first.Choices...), Raw: first.Raw}
+	for i, t := range texts {
+		if strings.TrimSpace(t) != "" {
+			merged.Choices = append(merged.Choices, t)
+		}
+		merged.Raw += "\n" + raws[i]
+	}
+	return merged, nil
+}
+
+// countLines returns the number of non-empty lines across choices.
+func countLines(choices []string) int {
+	n := 0
+	for _, ch := range choices {
+		for _, line := range strings.Split(ch, "\n") {
+			if strings.TrimSpace(line) != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// dropOnError withdraws the choice of onDelta, the stream of one fanned-out
+// call, when the call failed (see Delta.Dropped).
+func dropOnError(onDelta DeltaFunc, err error) {
+	if err != nil && onDelta != nil {
+		onDelta(Delta{Dropped: true})
+	}
+}
+
+// syncDelta serializes calls to onDelta so it can be shared by concurrent
+// streams, shifting choice indices by offset.
+func syncDelta(mu *sync.Mutex, onDelta DeltaFunc, offset int) DeltaFunc {
+	if onDelta == nil {
+		return nil
+	}
+	return func(d Delta) {
+		mu.Lock()
+		defer mu.Unlock()
+		d.Choice += offset
+		onDelta(d)
+	}
+}
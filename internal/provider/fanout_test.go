@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+func TestFanOutKeepsIndexOrder(t *testing.T) {
+	texts, _, err := fanOut(context.Background(), 5, 5, func(ctx context.Context, i int) (string, string, error) {
+		// Later indices finish first.
+		time.Sleep(time.Duration(5-i) * 5 * time.Millisecond)
+		return fmt.Sprintf("c%d", i), "", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"c0", "c1", "c2", "c3", "c4"}
+	if fmt.Sprint(texts) != fmt.Sprint(want) {
+		t.Fatalf("got %v want %v", texts, want)
+	}
+}
+
+func TestFanOutDropsFailedCalls(t *testing.T) {
+	texts, _, err := fanOut(context.Background(), 3, 2, func(ctx context.Context, i int) (string, string, error) {
+		if i == 1 {
+			return "", "", errors.New("boom")
+		}
+		return fmt.Sprintf("c%d", i), "", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(texts) != "[c0 c2]" {
+		t.Fatalf("unexpected texts: %v", texts)
+	}
+
+	_, _, err = fanOut(context.Background(), 3, 2, func(ctx context.Context, i int) (string, string, error) {
+		return "", "", fmt.Errorf("fail %d", i)
+	})
+	if err == nil || err.Error() != "fail 0" {
+		t.Fatalf("expected first error, got %v", err)
+	}
+}
+
+func TestFanOutBoundsConcurrency(t *testing.T) {
+	var inFlight, peak int32
+	_, _, err := fanOut(context.Background(), 8, 3, func(ctx context.Context, i int) (string, string, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return "ok", "", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peak > 3 {
+		t.Fatalf("peak concurrency %d exceeds limit 3", peak)
+	}
+}
+
+func TestFanOutBacksOffOnRateLimit(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[int]int{}
+	var inFlight, peakAfter int32
+	var limited atomic.Bool
+	texts, _, err := fanOut(context.Background(), 6, 4, func(ctx context.Context, i int) (string, string, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		if limited.Load() && n > atomic.LoadInt32(&peakAfter) {
+			atomic.StoreInt32(&peakAfter, n)
+		}
+		mu.Lock()
+		attempts[i]++
+		first := attempts[i] == 1
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		if i == 0 && first {
+			limited.Store(true)
+			return "", "", &Error{Provider: "claude", Status: 429, Category: CategoryRateLimit}
+		}
+		return fmt.Sprintf("c%d", i), "", nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(texts) != 6 || texts[0] != "c0" {
+		t.Fatalf("rate-limited call should be retried in place: %v", texts)
+	}
+	if attempts[0] != 2 {
+		t.Fatalf("expected index 0 to run twice, got %d", attempts[0])
+	}
+	if peakAfter > 4 {
+		t.Fatalf("limit not respected after rate limit: %d", peakAfter)
+	}
+}
+
+func TestClaudeChatFanOutPartialFailure(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"feat: ok"}]}`)
+	}))
+	defer srv.Close()
+	c := &Claude{APIKey: "k", HTTPClient: srv.Client(), BaseURL: srv.URL, Concurrency: 1}
+	resp, err := c.Chat(context.Background(), openai.ChatCompletionRequest{Model: "claude", N: 3, Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 {
+		t.Fatalf("expected 2 surviving choices, got %#v", resp.Choices)
+	}
+}
+
+func TestCustomEmulatesN(t *testing.T) {
+	var mu sync.Mutex
+	var ns []int
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		calls++
+		n := calls
+		ns = append(ns, req.N)
+		mu.Unlock()
+		// The server ignores n and always answers with one choice.
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"feat: option %d"},"finish_reason":"stop"}]}`, n)
+	}))
+	defer srv.Close()
+	c := &Custom{HTTPClient: srv.Client(), BaseURL: srv.URL, ChatCompletionsPath: "/v1/chat/completions", Concurrency: 2}
+	resp, err := c.Chat(context.Background(), openai.ChatCompletionRequest{Model: "m", N: 3, Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 3 || resp.Choices[0] != "feat: option 1" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if len(ns) != 3 || ns[0] != 3 || ns[1] != 1 || ns[2] != 1 {
+		t.Fatalf("unexpected n per request: %v", ns)
+	}
+}
+
+func TestCustomNoEmulationWhenLinesSuffice(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"feat: a\nfix: b\nchore: c"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+	c := &Custom{HTTPClient: srv.Client(), BaseURL: srv.URL, ChatCompletionsPath: "/v1/chat/completions", Concurrency: 2}
+	if _, err := c.Chat(context.Background(), openai.ChatCompletionRequest{Model: "m", N: 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single request, got %d", calls)
+	}
+}
@@ -2,28 +2,33 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/retry"
+	"github.com/diesi/aic/internal/sse"
 )
 
 // Gemini implements the Provider interface using Google's Gemini API.
 type Gemini struct {
 	APIKey     string
 	HTTPClient *http.Client
-	BaseURL    string
+	// Retry controls retries on rate limits and transient failures (zero value: none).
+	Retry   retry.Policy
+	BaseURL string
 }
 
 // NewGemini creates a new Gemini provider.
 func NewGemini(apiKey string) *Gemini {
 	return &Gemini{
 		APIKey:     apiKey,
-		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		HTTPClient: openai.NewHTTPClient(openai.ResponseTimeout),
+		Retry:      retry.Default(),
 		BaseURL:    "https://generativelanguage.googleapis.com/v1beta",
 	}
 }
@@ -36,65 +41,19 @@ func mapRole(role string) string {
 }
 
 // Chat sends a chat completion request to Gemini and converts the result to a generic CompletionResponse.
-func (g *Gemini) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, error) {
-	// Build messages once; only maxOutputTokens may change across attempts
-	messages := []map[string]any{}
-	var system string
-	for _, m := range req.Messages {
-		if m.Role == "system" && system == "" {
-			system = m.Content
-			continue
-		}
-		messages = append(messages, map[string]any{
-			"role":  mapRole(m.Role),
-			"parts": []map[string]string{{"text": m.Content}},
-		})
-	}
-
+func (g *Gemini) Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
 	// Determine initial max output tokens
-	maxOut := 256
-	if req.MaxTokens > 0 {
-		maxOut = req.MaxTokens
-	} else if req.MaxCompletionTokens > 0 {
-		maxOut = req.MaxCompletionTokens
-	}
+	maxOut := initialMaxOut(req)
 
 	// Up to 3 attempts: increase output tokens if we hit MAX_TOKENS with empty content
 	for attempt := 1; attempt <= 3; attempt++ {
-		body := map[string]any{
-			"contents": messages,
-		}
-		if system != "" {
-			body["systemInstruction"] = map[string]any{
-				"parts": []map[string]any{{"text": system}},
-			}
-		}
-		genConfig := map[string]any{
-			"maxOutputTokens": maxOut,
-		}
-		if req.Temperature != nil {
-			genConfig["temperature"] = req.Temperature
-		}
-		if req.N > 0 {
-			genConfig["candidateCount"] = req.N
-		}
-		// Encourage plain text content
-		genConfig["responseMimeType"] = "text/plain"
-		body["generationConfig"] = genConfig
-
-		bodyBytes, err := json.Marshal(body)
+		httpReq, err := g.newRequest(ctx, req, "generateContent", maxOut)
 		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
+			return nil, err
 		}
-		endpoint := fmt.Sprintf("%s/models/%s:generateContent?key=%s", g.BaseURL, req.Model, g.APIKey)
-		httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
+		resp, err := retry.Do(g.HTTPClient, httpReq, g.Retry)
 		if err != nil {
-			return nil, fmt.Errorf("new request: %w", err)
-		}
-		httpReq.Header.Set("content-type", "application/json")
-		resp, err := g.HTTPClient.Do(httpReq)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
+			return nil, networkError("gemini", err)
 		}
 		respBody, readErr := io.ReadAll(resp.Body)
 		closeErr := resp.Body.Close()
@@ -105,7 +64,7 @@ func (g *Gemini) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 			return nil, fmt.Errorf("close response body: %w", closeErr)
 		}
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			return nil, fmt.Errorf("gemini http %d: %s", resp.StatusCode, string(respBody))
+			return nil, newHTTPError("gemini", resp.StatusCode, respBody)
 		}
 		var completion struct {
 			Candidates []struct {
@@ -124,7 +83,7 @@ func (g *Gemini) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 			return nil, fmt.Errorf("unmarshal response: %w", err)
 		}
 		if completion.Error.Message != "" {
-			return nil, fmt.Errorf("gemini error: %s", completion.Error.Message)
+			return nil, newMessageError("gemini", completion.Error.Message)
 		}
 		choices := make([]string, 0, len(completion.Candidates))
 		allEmpty := true
@@ -156,3 +115,152 @@ func (g *Gemini) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, er
 	// Unreachable due to returns in loop
 	return nil, fmt.Errorf("unexpected gemini Chat loop exit")
 }
+
+// ChatStream streams candidates via streamGenerateContent (SSE), forwarding
+// text deltas to onDelta with the candidate index.
+func (g *Gemini) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	httpReq, err := g.newRequest(ctx, req, "streamGenerateContent", initialMaxOut(req))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := retry.Do(g.HTTPClient, httpReq, g.Retry)
+	if err != nil {
+		return nil, networkError("gemini", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return nil, fmt.Errorf("read response body: %w", readErr)
+		}
+		return nil, newHTTPError("gemini", resp.StatusCode, respBody)
+	}
+	var choices []string
+	var raw strings.Builder
+	hitMaxTokens := false
+	var streamErr string
+	err = sse.Read(resp.Body, func(ev sse.Event) error {
+		raw.WriteString(ev.Data)
+		raw.WriteString("\n")
+		var chunk struct {
+			Candidates []struct {
+				Index   int `json:"index"`
+				Content struct {
+					Parts []struct {
+						Text string `json:"text"`
+					} `json:"parts"`
+				} `json:"content"`
+				FinishReason string `json:"finishReason"`
+			} `json:"candidates"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			return fmt.Errorf("unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error.Message != "" {
+			streamErr = chunk.Error.Message
+			return sse.Done
+		}
+		for _, c := range chunk.Candidates {
+			for len(choices) <= c.Index {
+				choices = append(choices, "")
+			}
+			for _, p := range c.Content.Parts {
+				if p.Text == "" {
+					continue
+				}
+				choices[c.Index] += p.Text
+				if onDelta != nil {
+					onDelta(Delta{Choice: c.Index, Text: p.Text})
+				}
+			}
+			if strings.EqualFold(c.FinishReason, "MAX_TOKENS") {
+				hitMaxTokens = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	if streamErr != "" {
+		return nil, newMessageError("gemini", streamErr)
+	}
+	allEmpty := true
+	for _, c := range choices {
+		if strings.TrimSpace(c) != "" {
+			allEmpty = false
+			break
+		}
+	}
+	if allEmpty && hitMaxTokens {
+		// Nothing was produced before the budget ran out; Chat retries with a larger one.
+		return g.Chat(ctx, req)
+	}
+	return &CompletionResponse{Choices: choices, Raw: raw.String()}, nil
+}
+
+// initialMaxOut returns the output token budget requested by req (default 256).
+func initialMaxOut(req openai.ChatCompletionRequest) int {
+	if req.MaxTokens > 0 {
+		return req.MaxTokens
+	}
+	if req.MaxCompletionTokens > 0 {
+		return req.MaxCompletionTokens
+	}
+	return 256
+}
+
+// newRequest builds a POST request for the given model method
+// (generateContent or streamGenerateContent).
+func (g *Gemini) newRequest(ctx context.Context, req openai.ChatCompletionRequest, method string, maxOut int) (*http.Request, error) {
+	messages := []map[string]any{}
+	var system string
+	for _, m := range req.Messages {
+		if m.Role == "system" && system == "" {
+			system = m.Content
+			continue
+		}
+		messages = append(messages, map[string]any{
+			"role":  mapRole(m.Role),
+			"parts": []map[string]string{{"text": m.Content}},
+		})
+	}
+	body := map[string]any{
+		"contents": messages,
+	}
+	if system != "" {
+		body["systemInstruction"] = map[string]any{
+			"parts": []map[string]any{{"text": system}},
+		}
+	}
+	genConfig := map[string]any{
+		"maxOutputTokens": maxOut,
+	}
+	if req.Temperature != nil {
+		genConfig["temperature"] = req.Temperature
+	}
+	if req.N > 0 {
+		genConfig["candidateCount"] = req.N
+	}
+	// Encourage plain text content
+	genConfig["responseMimeType"] = "text/plain"
+	body["generationConfig"] = genConfig
+
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:%s?key=%s", g.BaseURL, req.Model, method, g.APIKey)
+	if method == "streamGenerateContent" {
+		endpoint += "&alt=sse"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	return httpReq, nil
+}
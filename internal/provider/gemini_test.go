@@ -1,8 +1,11 @@
 package provider
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/diesi/aic/internal/openai"
@@ -42,7 +45,7 @@ func TestGeminiChatReadError(t *testing.T) {
 		})},
 		BaseURL: "http://example.com",
 	}
-	_, err := client.Chat(openai.ChatCompletionRequest{Model: "gemini-1.5-flash", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	_, err := client.Chat(context.Background(), openai.ChatCompletionRequest{Model: "gemini-1.5-flash", Messages: []openai.Message{{Role: "user", Content: "hi"}}})
 	if err == nil || err.Error() != "read response body: read error" {
 		t.Fatalf("expected read error, got %v", err)
 	}
@@ -50,3 +53,5 @@ func TestGeminiChatReadError(t *testing.T) {
 		t.Fatalf("response body not closed")
 	}
 }
+
+func nopBody(s string) io.ReadCloser { return io.NopCloser(strings.NewReader(s)) }
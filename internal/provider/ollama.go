@@ -0,0 +1,306 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/retry"
+)
+
+// Ollama implements the Provider interface using Ollama's native API
+// (/api/chat and /api/tags) rather than its OpenAI compatibility layer.
+type Ollama struct {
+	HTTPClient *http.Client
+	// Retry controls retries on rate limits and transient failures (zero value: none).
+	Retry   retry.Policy
+	BaseURL string
+	// KeepAlive is sent as keep_alive (e.g. "5m", "-1"); empty leaves the server default.
+	KeepAlive string
+	// NumCtx sets options.num_ctx when > 0.
+	NumCtx int
+	// Seed sets options.seed when non-nil. Extra calls for additional choices
+	// use Seed+1, Seed+2, ... so they do not repeat the first answer.
+	Seed *int
+	// Concurrency bounds parallel calls when several choices are requested.
+	Concurrency int
+}
+
+// NewOllama creates a new Ollama provider using environment configuration.
+func NewOllama() *Ollama {
+	o := &Ollama{
+		HTTPClient:  openai.NewHTTPClient(120 * time.Second),
+		Retry:       retry.Default(),
+		BaseURL:     ollamaBaseURL(config.Get(config.EnvOllamaHost)),
+		KeepAlive:   strings.TrimSpace(config.Get(config.EnvOllamaKeepAlive)),
+		NumCtx:      config.IntInRange(config.EnvOllamaNumCtx, 0, 1, 1<<20),
+		Concurrency: concurrencyFromEnv(),
+	}
+	if v := strings.TrimSpace(config.Get(config.EnvOllamaSeed)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			o.Seed = &n
+		}
+	}
+	return o
+}
+
+// ollamaBaseURL normalizes an OLLAMA_HOST style value ("host:port", "0.0.0.0",
+// or a full URL) into a base URL. Bare hosts get Ollama's default port.
+func ollamaBaseURL(host string) string {
+	host = strings.TrimRight(strings.TrimSpace(host), "/")
+	if host == "" {
+		return "http://127.0.0.1:11434"
+	}
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return host
+	}
+	if !strings.Contains(host, ":") {
+		host += ":11434"
+	}
+	return "http://" + host
+}
+
+// ollamaChatResponse is one /api/chat response object; streaming responses
+// are a sequence of these, one per line.
+type ollamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason"`
+	Error      string `json:"error"`
+}
+
+// Chat sends /api/chat requests. Ollama always answers with a single message,
+// so when several choices are requested and that message does not already
+// list enough suggestions, the rest are fetched with additional calls.
+func (o *Ollama) Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+	if err := o.ensureModel(ctx, &req); err != nil {
+		return nil, err
+	}
+	first, err := o.chatOnce(ctx, req, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return emulateN(ctx, o.Concurrency, req, first, 1, func(ctx context.Context, i int, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+		return o.chatOnce(ctx, req, 1+i, nil)
+	})
+}
+
+// ChatStream streams /api/chat responses. Reasoning (<think>...</think>)
+// sections are filtered out before deltas reach onDelta.
+func (o *Ollama) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	if err := o.ensureModel(ctx, &req); err != nil {
+		return nil, err
+	}
+	first, err := o.chatOnce(ctx, req, 0, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	return emulateN(ctx, o.Concurrency, req, first, 1, func(ctx context.Context, i int, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+		delta := syncDelta(&mu, onDelta, 1+i)
+		out, err := o.chatOnce(ctx, req, 1+i, delta)
+		dropOnError(delta, err)
+		return out, err
+	})
+}
+
+// chatOnce performs a single /api/chat call. The call index offsets the seed.
+// When onDelta is non-nil the response is streamed and visible text is passed
+// to it with choice index 0.
+func (o *Ollama) chatOnce(ctx context.Context, req openai.ChatCompletionRequest, call int, onDelta DeltaFunc) (*CompletionResponse, error) {
+	httpReq, err := o.newChatRequest(ctx, req, call, onDelta != nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := retry.Do(o.HTTPClient, httpReq, o.Retry)
+	if err != nil {
+		return nil, networkError("ollama", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return nil, fmt.Errorf("read response body: %w", readErr)
+		}
+		return nil, newHTTPError("ollama", resp.StatusCode, respBody)
+	}
+
+	var text, raw strings.Builder
+	filter := &thinkFilter{}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		raw.Write(line)
+		raw.WriteString("\n")
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("unmarshal response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, newMessageError("ollama", chunk.Error)
+		}
+		text.WriteString(chunk.Message.Content)
+		if onDelta != nil {
+			if visible := filter.Write(chunk.Message.Content); visible != "" {
+				onDelta(Delta{Text: visible})
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	if onDelta != nil {
+		if rest := filter.Flush(); rest != "" {
+			onDelta(Delta{Text: rest})
+		}
+	}
+	out := &CompletionResponse{Raw: raw.String()}
+	if s := stripReasoning(text.String()); s != "" {
+		out.Choices = []string{s}
+	}
+	return out, nil
+}
+
+// newChatRequest builds a POST /api/chat request for req.
+func (o *Ollama) newChatRequest(ctx context.Context, req openai.ChatCompletionRequest, call int, stream bool) (*http.Request, error) {
+	options := map[string]any{}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	} else if req.MaxCompletionTokens > 0 {
+		options["num_predict"] = req.MaxCompletionTokens
+	}
+	if o.NumCtx > 0 {
+		options["num_ctx"] = o.NumCtx
+	}
+	if o.Seed != nil {
+		options["seed"] = *o.Seed + call
+	}
+	body := map[string]any{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"stream":   stream,
+	}
+	if len(options) > 0 {
+		body["options"] = options
+	}
+	if o.KeepAlive != "" {
+		// Ollama accepts either a duration string or a number of seconds.
+		if n, err := strconv.Atoi(o.KeepAlive); err == nil {
+			body["keep_alive"] = n
+		} else {
+			body["keep_alive"] = o.KeepAlive
+		}
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.BaseURL+"/api/chat", bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// ensureModel populates req.Model from /api/tags when it is empty or "auto",
+// picking the first model that can chat.
+func (o *Ollama) ensureModel(ctx context.Context, req *openai.ChatCompletionRequest) error {
+	m := strings.TrimSpace(req.Model)
+	if m != "" && strings.ToLower(m) != "auto" {
+		return nil
+	}
+	models, err := o.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("no chat-capable model found in ollama /api/tags (pull one with 'ollama pull' or set AIC_MODEL)")
+	}
+	req.Model = models[0]
+	return nil
+}
+
+// ListModels returns the locally pulled models that can chat, in /api/tags
+// order; embedding models are skipped.
+func (o *Ollama) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", o.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	resp, err := retry.Do(o.HTTPClient, httpReq, o.Retry)
+	if err != nil {
+		return nil, networkError("ollama", err)
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if readErr != nil {
+		return nil, fmt.Errorf("read response body: %w", readErr)
+	}
+	if closeErr != nil {
+		return nil, fmt.Errorf("close response body: %w", closeErr)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newHTTPError("ollama", resp.StatusCode, body)
+	}
+	var tags struct {
+		Models []ollamaModel `json:"models"`
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	var names []string
+	for _, m := range tags.Models {
+		if m.Name != "" && !m.embeddingOnly() {
+			names = append(names, m.Name)
+		}
+	}
+	return names, nil
+}
+
+// ollamaModel is an entry of the /api/tags response.
+type ollamaModel struct {
+	Name    string `json:"name"`
+	Details struct {
+		Family   string   `json:"family"`
+		Families []string `json:"families"`
+	} `json:"details"`
+}
+
+// embeddingOnly reports whether m looks like an embedding model. /api/tags
+// does not list capabilities, so this goes by the name and the BERT model
+// families that embedding models are built on.
+func (m ollamaModel) embeddingOnly() bool {
+	if strings.Contains(strings.ToLower(m.Name), "embed") {
+		return true
+	}
+	families := append([]string{m.Details.Family}, m.Details.Families...)
+	for _, f := range families {
+		if strings.Contains(strings.ToLower(f), "bert") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+func TestOllamaEnsureModelSkipsEmbeddingModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"models":[
+			{"name":"nomic-embed-text:latest","details":{"family":"nomic-bert","families":["nomic-bert"]}},
+			{"name":"bge-m3:latest","details":{"family":"bert"}},
+			{"name":"qwen3:8b","details":{"family":"qwen3","families":["qwen3"]}}
+		]}`)
+	}))
+	defer srv.Close()
+	o := &Ollama{HTTPClient: srv.Client(), BaseURL: srv.URL}
+	req := openai.ChatCompletionRequest{Model: "auto"}
+	if err := o.ensureModel(context.Background(), &req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Model != "qwen3:8b" {
+		t.Fatalf("expected chat model, got %q", req.Model)
+	}
+}
+
+func TestOllamaChatOptionsAndEmulatedChoices(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		n := len(bodies)
+		mu.Unlock()
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":"<think>hmm</think>feat: option %d"},"done":true,"done_reason":"stop"}`, n)
+	}))
+	defer srv.Close()
+	seed := 7
+	o := &Ollama{HTTPClient: srv.Client(), BaseURL: srv.URL, KeepAlive: "10m", NumCtx: 8192, Seed: &seed, Concurrency: 1}
+	resp, err := o.Chat(context.Background(), openai.ChatCompletionRequest{Model: "llama3", N: 2, MaxTokens: 64, Messages: []openai.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[0] != "feat: option 1" || resp.Choices[1] != "feat: option 2" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	first := bodies[0]
+	if first["keep_alive"] != "10m" || first["stream"] != false {
+		t.Fatalf("unexpected body: %#v", first)
+	}
+	opts := first["options"].(map[string]any)
+	if opts["num_ctx"] != float64(8192) || opts["num_predict"] != float64(64) || opts["seed"] != float64(7) {
+		t.Fatalf("unexpected options: %#v", opts)
+	}
+	if s := bodies[1]["options"].(map[string]any)["seed"]; s != float64(8) {
+		t.Fatalf("extra call should use the next seed, got %v", s)
+	}
+}
+
+func TestOllamaChatStreamFiltersThink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, part := range []string{"<thi", "nk>plan</think>", "feat: add ", "ollama"} {
+			b, _ := json.Marshal(map[string]any{"message": map[string]string{"role": "assistant", "content": part}, "done": false})
+			fmt.Fprintf(w, "%s\n", b)
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`+"\n")
+	}))
+	defer srv.Close()
+	o := &Ollama{HTTPClient: srv.Client(), BaseURL: srv.URL}
+	var log deltaLog
+	resp, err := o.ChatStream(context.Background(), openai.ChatCompletionRequest{Model: "llama3", N: 1}, log.add)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0] != "feat: add ollama" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if log.byChoice[0] != "feat: add ollama" {
+		t.Fatalf("unexpected deltas: %#v", log.byChoice)
+	}
+}
+
+func TestOllamaBaseURL(t *testing.T) {
+	cases := map[string]string{
+		"":                        "http://127.0.0.1:11434",
+		"0.0.0.0":                 "http://0.0.0.0:11434",
+		"gpu-box:9000":            "http://gpu-box:9000",
+		"https://ollama.example/": "https://ollama.example",
+		"http://localhost:11434":  "http://localhost:11434",
+	}
+	for in, want := range cases {
+		if got := ollamaBaseURL(in); got != want {
+			t.Errorf("ollamaBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,23 +1,72 @@
 package provider
 
-import "github.com/diesi/aic/internal/openai"
+import (
+	"context"
+	"fmt"
 
-// OpenAI implements the Provider interface using the OpenAI API.
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/openai"
+)
+
+// OpenAI implements the Provider interface using the OpenAI API. It also
+// serves Azure OpenAI (see NewAzure), which speaks the same protocol.
 type OpenAI struct {
-	client *openai.Client
+	Client *openai.Client
+	name   string
+	// configErr is returned by every call when the provider is misconfigured.
+	configErr error
 }
 
 // NewOpenAI creates a new OpenAI provider.
 func NewOpenAI(apiKey string) *OpenAI {
-	return &OpenAI{client: openai.NewClient(apiKey)}
+	return &OpenAI{Client: openai.NewClient(apiKey), name: "openai"}
+}
+
+// NewAzure creates an Azure OpenAI provider from the environment and the
+// "azure" object of .aic.json (see config.LoadAzure): the resource endpoint,
+// an optional deployment (defaults to the request model) and an optional
+// api-version.
+func NewAzure(apiKey string) *OpenAI {
+	az := config.LoadAzure()
+	o := &OpenAI{
+		Client: openai.NewAzureClient(apiKey, az.Endpoint, az.Deployment, az.APIVersion),
+		name:   "azure",
+	}
+	if az.Endpoint == "" {
+		o.configErr = fmt.Errorf("azure: %s is not set, nor \"azure\": {\"endpoint\": ...} in ~/.aic.json (e.g. https://<resource>.openai.azure.com)", config.EnvAzureOpenAIEndpoint)
+	}
+	return o
 }
 
 // Chat sends a chat completion request to OpenAI and converts the result
 // to a generic CompletionResponse.
-func (o *OpenAI) Chat(req openai.ChatCompletionRequest) (*CompletionResponse, error) {
-	resp, err := o.client.Chat(req)
+func (o *OpenAI) Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error) {
+	if o.configErr != nil {
+		return nil, o.configErr
+	}
+	resp, err := o.Client.Chat(ctx, req)
+	if err != nil {
+		return nil, fromOpenAI(o.name, err)
+	}
+	out := &CompletionResponse{Raw: resp.Raw}
+	for _, c := range resp.Choices {
+		out.Choices = append(out.Choices, c.Message.Content)
+	}
+	return out, nil
+}
+
+// ChatStream streams a chat completion from OpenAI, forwarding content deltas to onDelta.
+func (o *OpenAI) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error) {
+	if o.configErr != nil {
+		return nil, o.configErr
+	}
+	var forward func(choice int, delta string)
+	if onDelta != nil {
+		forward = func(choice int, delta string) { onDelta(Delta{Choice: choice, Text: delta}) }
+	}
+	resp, err := o.Client.ChatStream(ctx, req, forward)
 	if err != nil {
-		return nil, err
+		return nil, fromOpenAI(o.name, err)
 	}
 	out := &CompletionResponse{Raw: resp.Raw}
 	for _, c := range resp.Choices {
@@ -1,6 +1,10 @@
 package provider
 
-import "github.com/diesi/aic/internal/openai"
+import (
+	"context"
+
+	"github.com/diesi/aic/internal/openai"
+)
 
 // Generic completion response for abstraction
 type CompletionResponse struct {
@@ -8,7 +12,33 @@ type CompletionResponse struct {
 	Raw     string
 }
 
+// Delta is one event of a streamed response.
+type Delta struct {
+	// Choice is the index of the choice (candidate) the event belongs to.
+	Choice int
+	// Text is the next content fragment of the choice.
+	Text string
+	// Dropped withdraws the choice: when choices are streamed by separate
+	// calls and one of them fails, what was streamed for it is not part of
+	// the result. Text is empty.
+	Dropped bool
+}
+
+// DeltaFunc receives the events of a streamed response.
+type DeltaFunc func(Delta)
+
 // Provider defines the interface for AI providers.
+// Implementations must bind outgoing HTTP requests to ctx so that callers can
+// cancel in-flight calls (e.g., on Ctrl+C) or enforce per-call deadlines.
 type Provider interface {
-	Chat(req openai.ChatCompletionRequest) (*CompletionResponse, error)
+	Chat(ctx context.Context, req openai.ChatCompletionRequest) (*CompletionResponse, error)
+	// ChatStream behaves like Chat but reports content incrementally through
+	// onDelta as it arrives. The returned response holds the final choices.
+	ChatStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta DeltaFunc) (*CompletionResponse, error)
+}
+
+// ModelLister is implemented by providers that can list the models their
+// server offers (custom, ollama).
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
 }
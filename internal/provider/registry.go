@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/diesi/aic/internal/config"
+)
+
+// Default models used when AIC_MODEL is unset.
+const (
+	DefaultOpenAIModel = "gpt-4o-mini"
+	DefaultClaudeModel = "claude-3-sonnet-20240229"
+	DefaultGeminiModel = "gemini-1.5-flash"
+)
+
+// Info describes a provider implementation known to aic.
+type Info struct {
+	// Name is the AIC_PROVIDER / "providers" name, e.g. "claude".
+	Name string
+	// KeyEnv is the environment variable holding the API key ("" if none).
+	KeyEnv string
+	// KeyOptional is set for local servers that may run without an API key.
+	KeyOptional bool
+	// DefaultModel is used when no model is configured. Empty lets the
+	// provider pick a model from the server (/v1/models, /api/tags).
+	DefaultModel string
+	// Models lists well-known model IDs, offered by shell completion.
+	Models []string
+	// New creates the provider for apiKey.
+	New func(apiKey string) Provider
+}
+
+// Well-known models per provider, default first.
+var (
+	openAIModels = []string{DefaultOpenAIModel, "gpt-4o", "gpt-4.1", "gpt-4.1-mini", "gpt-5", "gpt-5-mini", "o4-mini"}
+	claudeModels = []string{DefaultClaudeModel, "claude-3-5-haiku-20241022", "claude-sonnet-4-20250514", "claude-opus-4-20250514"}
+	geminiModels = []string{DefaultGeminiModel, "gemini-2.5-flash", "gemini-2.5-pro"}
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = []Info{
+		{Name: "openai", KeyEnv: config.EnvOpenAIAPIKey, DefaultModel: DefaultOpenAIModel, Models: openAIModels, New: func(k string) Provider { return NewOpenAI(k) }},
+		{Name: "claude", KeyEnv: config.EnvClaudeAPIKey, DefaultModel: DefaultClaudeModel, Models: claudeModels, New: func(k string) Provider { return NewClaude(k) }},
+		{Name: "gemini", KeyEnv: config.EnvGeminiAPIKey, DefaultModel: DefaultGeminiModel, Models: geminiModels, New: func(k string) Provider { return NewGemini(k) }},
+		// Azure routes by deployment; the model only names it when AZURE_OPENAI_DEPLOYMENT is unset.
+		{Name: "azure", KeyEnv: config.EnvAzureOpenAIAPIKey, DefaultModel: DefaultOpenAIModel, Models: openAIModels, New: func(k string) Provider { return NewAzure(k) }},
+		{Name: "custom", KeyEnv: config.EnvCustomAPIKey, KeyOptional: true, New: func(k string) Provider { return NewCustom(k) }},
+		{Name: "ollama", KeyOptional: true, New: func(string) Provider { return NewOllama() }},
+	}
+)
+
+// Register adds a provider implementation, replacing any registered under
+// the same name. It returns the replaced Info (zero if none), which tests use
+// to restore the original after swapping in a fake.
+func Register(info Info) Info {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i, r := range registry {
+		if r.Name == info.Name {
+			registry[i] = info
+			return r
+		}
+	}
+	registry = append(registry, info)
+	return Info{}
+}
+
+// Lookup returns the registered provider named name.
+func Lookup(name string) (Info, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Info{}, false
+}
+
+// Names lists the registered provider names in registration order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]string, len(registry))
+	for i, r := range registry {
+		out[i] = r.Name
+	}
+	return out
+}
+
+// UnknownError reports a provider name that is not registered.
+func UnknownError(name string) error {
+	names := Names()
+	return fmt.Errorf("unknown provider %q (expected %s or %s)", name, strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// KeyEnv returns the API key environment variable of provider name, or ""
+// when it needs none.
+func KeyEnv(name string) string {
+	info, _ := Lookup(name)
+	return info.KeyEnv
+}
+
+// APIKey returns the API key for provider name from the environment.
+func APIKey(name string) string {
+	if env := KeyEnv(name); env != "" {
+		return config.Get(env)
+	}
+	return ""
+}
+
+// DefaultModel returns the default model of provider name.
+func DefaultModel(name string) string {
+	info, _ := Lookup(name)
+	return info.DefaultModel
+}
+
+// Models returns the model IDs known for provider name: its well-known models
+// followed by those its server lists (custom, ollama) when it is reachable
+// within ctx. Duplicates are dropped.
+func Models(ctx context.Context, name string) []string {
+	info, ok := Lookup(name)
+	if !ok {
+		return nil
+	}
+	models := append([]string(nil), info.Models...)
+	if lister, ok := info.New(APIKey(name)).(ModelLister); ok {
+		if listed, err := lister.ListModels(ctx); err == nil {
+			models = append(models, listed...)
+		}
+	}
+	seen := map[string]bool{}
+	out := models[:0]
+	for _, m := range models {
+		if !seen[m] {
+			seen[m] = true
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// New returns the provider named name for apiKey, falling back to the key
+// from the environment when apiKey is empty. It fails for unknown names and
+// for missing keys (except for local servers that may not need one).
+func New(name, apiKey string) (Provider, error) {
+	info, ok := Lookup(name)
+	if !ok {
+		return nil, UnknownError(name)
+	}
+	if apiKey == "" && info.KeyEnv != "" {
+		apiKey = config.Get(info.KeyEnv)
+	}
+	if apiKey == "" && !info.KeyOptional {
+		return nil, MissingKeyError(name, info.KeyEnv)
+	}
+	return info.New(apiKey), nil
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+func TestNewResolvesEveryProvider(t *testing.T) {
+	for _, name := range []string{"OPENAI_API_KEY", "CLAUDE_API_KEY", "GEMINI_API_KEY", "AZURE_OPENAI_API_KEY", "CUSTOM_API_KEY"} {
+		t.Setenv(name, "")
+	}
+	cases := []struct {
+		name     string
+		keyEnv   string
+		optional bool
+		model    string
+	}{
+		{"openai", "OPENAI_API_KEY", false, DefaultOpenAIModel},
+		{"claude", "CLAUDE_API_KEY", false, DefaultClaudeModel},
+		{"gemini", "GEMINI_API_KEY", false, DefaultGeminiModel},
+		{"azure", "AZURE_OPENAI_API_KEY", false, DefaultOpenAIModel},
+		{"custom", "CUSTOM_API_KEY", true, ""},
+		{"ollama", "", true, ""},
+	}
+	if got := strings.Join(Names(), ","); got != "openai,claude,gemini,azure,custom,ollama" {
+		t.Fatalf("Names() = %s", got)
+	}
+	for _, c := range cases {
+		if KeyEnv(c.name) != c.keyEnv || DefaultModel(c.name) != c.model {
+			t.Errorf("%s: KeyEnv=%q DefaultModel=%q", c.name, KeyEnv(c.name), DefaultModel(c.name))
+		}
+		_, err := New(c.name, "")
+		var perr *Error
+		if c.optional != (err == nil) {
+			t.Errorf("%s without key: err = %v", c.name, err)
+		} else if err != nil && (!errors.As(err, &perr) || perr.Category != CategoryAuth || !strings.Contains(perr.Message, c.keyEnv)) {
+			t.Errorf("%s: expected a missing-key error, got %v", c.name, err)
+		}
+		if c.keyEnv != "" {
+			t.Setenv(c.keyEnv, "from-env")
+			if APIKey(c.name) != "from-env" {
+				t.Errorf("%s: APIKey() = %q", c.name, APIKey(c.name))
+			}
+			if _, err := New(c.name, ""); err != nil {
+				t.Errorf("%s: key from env not used: %v", c.name, err)
+			}
+		}
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	_, err := New("bard", "k")
+	if err == nil || err.Error() != `unknown provider "bard" (expected openai, claude, gemini, azure, custom or ollama)` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type fakeProvider struct{ key string }
+
+func (f fakeProvider) Chat(context.Context, openai.ChatCompletionRequest) (*CompletionResponse, error) {
+	return &CompletionResponse{Choices: []string{f.key}}, nil
+}
+
+func (f fakeProvider) ChatStream(ctx context.Context, req openai.ChatCompletionRequest, _ DeltaFunc) (*CompletionResponse, error) {
+	return f.Chat(ctx, req)
+}
+
+func TestRegisterReplacesAndRestores(t *testing.T) {
+	orig := Register(Info{Name: "claude", KeyEnv: "CLAUDE_API_KEY", New: func(k string) Provider { return fakeProvider{key: k} }})
+	defer Register(orig)
+	p, err := New("claude", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := p.Chat(context.Background(), openai.ChatCompletionRequest{}); resp.Choices[0] != "k1" {
+		t.Fatalf("registered constructor not used: %v", resp.Choices)
+	}
+	Register(orig)
+	if p, _ := New("claude", "k1"); p == nil {
+		t.Fatal("expected the original provider after restoring")
+	} else if _, ok := p.(*Claude); !ok {
+		t.Fatalf("expected *Claude after restoring, got %T", p)
+	}
+}
+
+func TestModelsMergesServerList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"qwen2.5-coder"},{"id":"llama3"},{"id":"qwen2.5-coder"}]}`)
+	}))
+	defer srv.Close()
+	t.Setenv("CUSTOM_BASE_URL", srv.URL)
+	if got := strings.Join(Models(context.Background(), "custom"), ","); got != "qwen2.5-coder,llama3" {
+		t.Fatalf("custom models = %s", got)
+	}
+	if got := Models(context.Background(), "openai"); len(got) == 0 || got[0] != DefaultOpenAIModel {
+		t.Fatalf("openai models = %v", got)
+	}
+	// An unreachable server leaves only the well-known models (none for ollama).
+	t.Setenv("OLLAMA_HOST", "http://127.0.0.1:1")
+	t.Setenv("AIC_RETRY_MAX_ATTEMPTS", "1")
+	if got := Models(context.Background(), "ollama"); len(got) != 0 {
+		t.Fatalf("ollama models = %v", got)
+	}
+}
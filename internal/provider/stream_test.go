@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/diesi/aic/internal/openai"
+)
+
+func sseServer(t *testing.T, check func(r *http.Request), events ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			fmt.Fprint(w, ev+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// deltaLog records streamed text by choice; a dropped choice is marked with
+// "[dropped]".
+type deltaLog struct{ byChoice map[int]string }
+
+func (d *deltaLog) add(delta Delta) {
+	if d.byChoice == nil {
+		d.byChoice = map[int]string{}
+	}
+	if delta.Dropped {
+		d.byChoice[delta.Choice] += "[dropped]"
+		return
+	}
+	d.byChoice[delta.Choice] += delta.Text
+}
+
+func TestClaudeChatStream(t *testing.T) {
+	srv := sseServer(t, func(r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	},
+		"event: message_start\ndata: {\"type\":\"message_start\"}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"feat: add \"}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"streaming\"}}",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+	)
+	c := &Claude{APIKey: "k", HTTPClient: srv.Client(), BaseURL: srv.URL}
+	var log deltaLog
+	resp, err := c.ChatStream(context.Background(), openai.ChatCompletionRequest{Model: "claude", N: 2, Messages: []openai.Message{{Role: "user", Content: "hi"}}}, log.add)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[1] != "feat: add streaming" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if log.byChoice[0] != "feat: add streaming" || log.byChoice[1] != "feat: add streaming" {
+		t.Fatalf("unexpected deltas: %#v", log.byChoice)
+	}
+}
+
+func TestGeminiChatStream(t *testing.T) {
+	srv := sseServer(t, func(r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, ":streamGenerateContent") || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected url %s", r.URL.String())
+		}
+	},
+		`data: {"candidates":[{"index":0,"content":{"parts":[{"text":"fix: a"}]}},{"index":1,"content":{"parts":[{"text":"docs: b"}]}}]}`,
+		`data: {"candidates":[{"index":0,"content":{"parts":[{"text":"bc"}]},"finishReason":"STOP"}]}`,
+	)
+	g := &Gemini{APIKey: "k", HTTPClient: srv.Client(), BaseURL: srv.URL}
+	var log deltaLog
+	resp, err := g.ChatStream(context.Background(), openai.ChatCompletionRequest{Model: "gemini", N: 2, Messages: []openai.Message{{Role: "user", Content: "hi"}}}, log.add)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 || resp.Choices[0] != "fix: abc" || resp.Choices[1] != "docs: b" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if log.byChoice[0] != "fix: abc" {
+		t.Fatalf("unexpected deltas: %#v", log.byChoice)
+	}
+}
+
+func TestCustomChatStreamFiltersThink(t *testing.T) {
+	srv := sseServer(t, nil,
+		`data: {"choices":[{"index":0,"delta":{"content":"<thi"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"nk>hmm</think>feat: "}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"content":"add x"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	)
+	t.Setenv("CUSTOM_BASE_URL", srv.URL)
+	p := NewCustom("")
+	p.HTTPClient = srv.Client()
+	var log deltaLog
+	resp, err := p.ChatStream(context.Background(), openai.ChatCompletionRequest{Model: "m", Messages: []openai.Message{{Role: "user", Content: "hi"}}}, log.add)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0] != "feat: add x" {
+		t.Fatalf("unexpected choices: %#v", resp.Choices)
+	}
+	if log.byChoice[0] != "feat: add x" {
+		t.Fatalf("reasoning leaked into deltas: %q", log.byChoice[0])
+	}
+}
+
+func TestThinkFilterStrayClosingTag(t *testing.T) {
+	f := &thinkFilter{}
+	got := f.Write("reasoning</th") + f.Write("ink>answer <b>") + f.Flush()
+	if got != "reasoninganswer <b>" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestClaudeChatStreamDropsFailedChoice(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		text := "feat: ok"
+		if atomic.AddInt32(&calls, 1) == 2 {
+			text = "fix: partial"
+		}
+		fmt.Fprintf(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", text)
+		if text == "fix: partial" {
+			fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+			return
+		}
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer srv.Close()
+	c := &Claude{APIKey: "k", HTTPClient: srv.Client(), BaseURL: srv.URL, Concurrency: 1}
+	var log deltaLog
+	resp, err := c.ChatStream(context.Background(), openai.ChatCompletionRequest{Model: "claude", N: 3, Messages: []openai.Message{{Role: "user", Content: "hi"}}}, log.add)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 2 {
+		t.Fatalf("expected 2 surviving choices, got %#v", resp.Choices)
+	}
+	if log.byChoice[1] != "fix: partial[dropped]" || log.byChoice[0] != "feat: ok" || log.byChoice[2] != "feat: ok" {
+		t.Fatalf("expected choice 1 to be dropped after its deltas: %#v", log.byChoice)
+	}
+}
@@ -0,0 +1,262 @@
+// Package redact replaces likely secrets (API keys, tokens, private keys,
+// passwords) in diffs with placeholders before they are sent to a provider.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/git"
+)
+
+// Detector finds one kind of secret. When Re has a capture group only the
+// first group is replaced, so "password = hunter2" keeps its key.
+type Detector struct {
+	Name string
+	Re   *regexp.Regexp
+	// Check, when set, filters candidate matches (e.g. by entropy).
+	Check func(match string) bool
+}
+
+var builtin = []Detector{
+	{Name: "aws-access-key", Re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-key", Re: regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|private).{0,20}?[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{Name: "github-token", Re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{Name: "slack-token", Re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{Name: "api-key", Re: regexp.MustCompile(`\b(?:sk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}|AIza[0-9A-Za-z_-]{35})\b`)},
+	{Name: "jwt", Re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{Name: "password", Re: regexp.MustCompile(`(?i)(?:^|[^a-z])(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)["']?\s*(?::=|[:=])\s*["']?([^\s"',;]{4,})`),
+		Check: func(v string) bool { return !placeholderValue(v) }},
+	{Name: "high-entropy", Re: regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`), Check: highEntropy},
+}
+
+// pemBegin and pemEnd delimit private key blocks, which span several lines.
+var (
+	pemBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	pemEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+)
+
+// Compile turns user regexes (from .aic.json "redact") into detectors named
+// "custom".
+func Compile(patterns []string) ([]Detector, error) {
+	var out []Detector
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		out = append(out, Detector{Name: "custom", Re: re})
+	}
+	return out, nil
+}
+
+// Redactor applies the built-in detectors plus custom ones. A nil *Redactor
+// applies the built-in detectors only.
+type Redactor struct {
+	detectors []Detector
+}
+
+// New returns a Redactor using the built-in detectors followed by custom.
+func New(custom []Detector) *Redactor {
+	return &Redactor{detectors: append(append([]Detector{}, builtin...), custom...)}
+}
+
+func (r *Redactor) list() []Detector {
+	if r == nil {
+		return builtin
+	}
+	return r.detectors
+}
+
+// Finding is one redacted secret in a diff.
+type Finding struct {
+	Detector string
+	File     string
+	// Line is the line number in the new file, or in the old file for a
+	// removed line.
+	Line    int
+	Removed bool
+}
+
+// Location describes where the secret was found, e.g. "config/.env:3".
+func (f Finding) Location() string {
+	loc := f.File + ":" + strconv.Itoa(f.Line)
+	if f.Removed {
+		loc += " (removed line)"
+	}
+	return loc
+}
+
+// Placeholder is the text that replaces a secret found by detector name.
+func Placeholder(name string) string { return "[REDACTED:" + name + "]" }
+
+// Diff redacts the hunks of a unified diff and reports what was replaced.
+// File headers are left alone.
+func (r *Redactor) Diff(diff string) (string, []Finding) {
+	files := git.SplitDiff(diff)
+	if len(files) == 0 {
+		return diff, nil
+	}
+	var b strings.Builder
+	if i := strings.Index(diff, "diff --git "); i > 0 {
+		b.WriteString(diff[:i])
+	}
+	var findings []Finding
+	for _, f := range files {
+		b.WriteString(f.Header)
+		for _, h := range f.Hunks {
+			out, found := r.hunk(f.Path, h)
+			b.WriteString(out)
+			findings = append(findings, found...)
+		}
+	}
+	return b.String(), findings
+}
+
+// hunkHeader matches "@@ -old[,n] +new[,n] @@".
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// hunk redacts one hunk of file path, tracking line numbers for findings.
+func (r *Redactor) hunk(path, h string) (string, []Finding) {
+	lines := strings.SplitAfter(h, "\n")
+	oldLine, newLine := 0, 0
+	if m := hunkHeader.FindStringSubmatch(lines[0]); m != nil {
+		oldLine, _ = strconv.Atoi(m[1])
+		newLine, _ = strconv.Atoi(m[2])
+	}
+	var b strings.Builder
+	b.WriteString(lines[0])
+	var findings []Finding
+	inKey := false
+	for _, ln := range lines[1:] {
+		if ln == "" {
+			continue
+		}
+		prefix, body := ln[:1], ln[1:]
+		loc := Finding{File: path, Line: newLine}
+		switch prefix {
+		case "-":
+			loc.Line, loc.Removed = oldLine, true
+			oldLine++
+		case "+":
+			newLine++
+		case " ":
+			oldLine++
+			newLine++
+		default:
+			// "\ No newline at end of file"
+			b.WriteString(ln)
+			continue
+		}
+		switch {
+		case pemBegin.MatchString(body):
+			inKey = !pemEnd.MatchString(body)
+			body = Placeholder("private-key") + "\n"
+			loc.Detector = "private-key"
+			findings = append(findings, loc)
+		case inKey:
+			// Key material: drop the line and keep the block as one placeholder.
+			if pemEnd.MatchString(body) {
+				inKey = false
+			}
+			continue
+		default:
+			var names []string
+			body, names = r.line(body)
+			for _, n := range names {
+				loc.Detector = n
+				findings = append(findings, loc)
+			}
+		}
+		b.WriteString(prefix + body)
+	}
+	return b.String(), findings
+}
+
+// line applies every detector to one line.
+func (r *Redactor) line(s string) (string, []string) {
+	var names []string
+	for _, d := range r.list() {
+		s = d.Re.ReplaceAllStringFunc(s, func(m string) string {
+			secret := m
+			sub := d.Re.FindStringSubmatchIndex(m)
+			start, end := 0, len(m)
+			if len(sub) >= 4 && sub[2] >= 0 {
+				start, end = sub[2], sub[3]
+				secret = m[start:end]
+			}
+			if strings.HasPrefix(secret, "[REDACTED:") || (d.Check != nil && !d.Check(secret)) {
+				return m
+			}
+			names = append(names, d.Name)
+			return m[:start] + Placeholder(d.Name) + m[end:]
+		})
+	}
+	return s, names
+}
+
+// placeholderValue reports whether an assigned value is clearly not a real
+// secret: empty-ish, a variable reference or a template.
+func placeholderValue(v string) bool {
+	l := strings.ToLower(v)
+	switch {
+	case strings.HasPrefix(v, "$"), strings.HasPrefix(v, "%"), strings.HasPrefix(v, "{"), strings.HasPrefix(v, "<"),
+		strings.HasPrefix(v, "[REDACTED:"):
+		return true
+	case strings.Contains(l, "process.env"), strings.Contains(l, "os.getenv"), strings.Contains(l, "getenv("),
+		strings.Contains(l, "environ"):
+		return true
+	}
+	switch l {
+	case "null", "none", "nil", "true", "false", "undefined", "changeme", "password", "xxxx", "****", "example",
+		"string", "str", "number", "int", "bool", "boolean", "bytes":
+		return true
+	}
+	// Identifiers and calls (password = cfg.Password, token: getToken())
+	// are code, not values.
+	if strings.ContainsAny(v, "().") {
+		return true
+	}
+	return false
+}
+
+// highEntropy reports whether s looks like a random token: mixed case and
+// digits with high Shannon entropy. Hex strings (commit hashes, checksums)
+// stay below the threshold.
+func highEntropy(s string) bool {
+	s = strings.TrimRight(s, "=")
+	var upper, lower, digit bool
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	return entropy(s) >= 4.2
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,211 @@
+// Package retry implements the shared HTTP retry policy used by all providers:
+// jittered exponential backoff for rate limits, overloads and transient
+// network failures, honouring server-provided wait hints.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diesi/aic/internal/config"
+)
+
+// Policy controls how failed requests are retried. The zero value disables retries.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// MaxElapsed bounds the total time spent across attempts (0 = no limit besides ctx).
+	MaxElapsed time.Duration
+	// BaseDelay is the backoff before the second attempt; it doubles per attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps a single computed backoff (server-provided hints may exceed it).
+	MaxDelay time.Duration
+
+	// sleep waits for d or until ctx is done; overridable in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+const (
+	defaultMaxAttempts = 4
+	defaultMaxElapsed  = 90 * time.Second
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 20 * time.Second
+)
+
+// Default returns the policy configured via AIC_RETRY_MAX_ATTEMPTS and
+// AIC_RETRY_MAX_TIME (seconds), falling back to 4 attempts within 90s.
+func Default() Policy {
+	return Policy{
+		MaxAttempts: config.IntInRange(config.EnvAICRetryMaxAttempts, defaultMaxAttempts, 1, 20),
+		MaxElapsed:  time.Duration(config.IntInRange(config.EnvAICRetryMaxTime, int(defaultMaxElapsed/time.Second), 1, 3600)) * time.Second,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+	}
+}
+
+// Do sends req with client, retrying retryable failures according to p.
+// Requests with a body must be replayable (req.GetBody set), which is the case
+// for bodies created from bytes.Buffer/Reader or strings.Reader.
+// When retries are exhausted, the last response is returned unread so the
+// caller can report the provider's error body as usual.
+func Do(client *http.Client, req *http.Request, p Policy) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+	sleep := p.sleep
+	if sleep == nil {
+		sleep = sleepCtx
+	}
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("replay request body: %w", err)
+				}
+				r.Body = body
+			}
+		}
+		resp, err := client.Do(r)
+		if attempt >= p.MaxAttempts || (req.Body != nil && req.GetBody == nil) || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		delay := p.backoff(attempt)
+		if resp != nil {
+			if hint, ok := delayFromHeaders(resp.Header, time.Now()); ok {
+				delay = hint
+			}
+		}
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			// Waiting would blow the time budget; report the failure we have.
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if config.Bool(config.EnvAICDebug) {
+			reason := "network error"
+			if resp != nil {
+				reason = "HTTP " + strconv.Itoa(resp.StatusCode)
+			}
+			fmt.Fprintf(os.Stderr, "[aic][debug] %s; retrying in %s (attempt %d/%d)\n", reason, delay.Round(time.Millisecond), attempt+1, p.MaxAttempts)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether a request that ended with resp/err is worth retrying.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// Cancellation/deadline of the caller's context is final.
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return true
+	}
+	return false
+}
+
+// backoff returns the jittered exponential delay before attempt+1.
+func (p Policy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = defaultBaseDelay
+	}
+	d := base << (attempt - 1)
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	// Equal jitter: somewhere between d/2 and d.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// delayFromHeaders extracts a server-provided wait hint: Retry-After (seconds
+// or HTTP date), retry-after-ms, OpenAI's x-ratelimit-reset-* durations and
+// Anthropic's anthropic-ratelimit-*-reset timestamps. The longest hint wins.
+func delayFromHeaders(h http.Header, now time.Time) (time.Duration, bool) {
+	var best time.Duration
+	found := false
+	consider := func(d time.Duration) {
+		if d < 0 {
+			d = 0
+		}
+		if !found || d > best {
+			best = d
+		}
+		found = true
+	}
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			consider(time.Duration(secs * float64(time.Second)))
+		} else if t, err := http.ParseTime(v); err == nil {
+			consider(t.Sub(now))
+		}
+	}
+	if v := strings.TrimSpace(h.Get("Retry-After-Ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil {
+			consider(time.Duration(ms * float64(time.Millisecond)))
+		}
+	}
+	if found {
+		// Explicit Retry-After takes precedence over rate-limit bookkeeping headers.
+		return best, true
+	}
+	for _, k := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if v := strings.TrimSpace(h.Get(k)); v != "" && exhausted(h, strings.Replace(k, "Reset", "Remaining", 1)) {
+			if d, err := time.ParseDuration(v); err == nil {
+				consider(d)
+			}
+		}
+	}
+	for _, k := range []string{"Anthropic-Ratelimit-Requests-Reset", "Anthropic-Ratelimit-Tokens-Reset", "Anthropic-Ratelimit-Input-Tokens-Reset", "Anthropic-Ratelimit-Output-Tokens-Reset"} {
+		if v := strings.TrimSpace(h.Get(k)); v != "" && exhausted(h, strings.Replace(k, "Reset", "Remaining", 1)) {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				consider(t.Sub(now))
+			}
+		}
+	}
+	return best, found
+}
+
+// exhausted reports whether the "remaining" counterpart of a reset header is
+// zero (or absent, in which case the reset hint is used conservatively).
+func exhausted(h http.Header, remainingKey string) bool {
+	v := strings.TrimSpace(h.Get(remainingKey))
+	if v == "" {
+		return true
+	}
+	n, err := strconv.Atoi(v)
+	return err != nil || n <= 0
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
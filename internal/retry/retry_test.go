@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastPolicy retries without actually sleeping and records requested delays.
+func fastPolicy(attempts int, delays *[]time.Duration) Policy {
+	return Policy{
+		MaxAttempts: attempts,
+		MaxElapsed:  time.Minute,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    time.Second,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			if delays != nil {
+				*delays = append(*delays, d)
+			}
+			return ctx.Err()
+		},
+	}
+}
+
+func TestDoRetriesRateLimitAndReplaysBody(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != `{"x":1}` {
+			t.Errorf("body not replayed, got %q", b)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewBufferString(`{"x":1}`))
+	resp, err := Do(srv.Client(), req, fastPolicy(3, &delays))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || calls != 2 {
+		t.Fatalf("status=%d calls=%d", resp.StatusCode, calls)
+	}
+	if len(delays) != 1 || delays[0] != 2*time.Second {
+		t.Fatalf("expected Retry-After delay of 2s, got %v", delays)
+	}
+}
+
+func TestDoGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("overloaded"))
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := Do(srv.Client(), req, fastPolicy(3, &delays))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || string(body) != "overloaded" || calls != 3 {
+		t.Fatalf("status=%d body=%q calls=%d", resp.StatusCode, body, calls)
+	}
+	// Exponential: ~[5ms,10ms] then ~[10ms,20ms]
+	if len(delays) != 2 || delays[0] > 10*time.Millisecond || delays[1] < 10*time.Millisecond || delays[1] > 20*time.Millisecond {
+		t.Fatalf("unexpected backoff delays %v", delays)
+	}
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := Do(srv.Client(), req, fastPolicy(4, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+func TestDoRespectsTimeBudget(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	p := fastPolicy(5, nil)
+	p.MaxElapsed = 5 * time.Second
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := Do(srv.Client(), req, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 429 || calls != 1 {
+		t.Fatalf("expected to give up immediately; status=%d calls=%d", resp.StatusCode, calls)
+	}
+}
+
+func TestDoStopsOnContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := fastPolicy(5, nil)
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	_, err := Do(srv.Client(), req, p)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestZeroPolicyDoesNotRetry(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := Do(srv.Client(), req, Policy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestDelayFromHeaders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+		ok     bool
+	}{
+		{"none", nil, 0, false},
+		{"retry-after seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second, true},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(7 * time.Second).Format(http.TimeFormat)}, 7 * time.Second, true},
+		{"retry-after-ms", map[string]string{"retry-after-ms": "250"}, 250 * time.Millisecond, true},
+		{"openai tokens exhausted", map[string]string{"x-ratelimit-remaining-tokens": "0", "x-ratelimit-reset-tokens": "1m30s", "x-ratelimit-remaining-requests": "10", "x-ratelimit-reset-requests": "5s"}, 90 * time.Second, true},
+		{"openai not exhausted", map[string]string{"x-ratelimit-remaining-requests": "10", "x-ratelimit-reset-requests": "5s"}, 0, false},
+		{"anthropic reset", map[string]string{"anthropic-ratelimit-requests-remaining": "0", "anthropic-ratelimit-requests-reset": now.Add(12 * time.Second).Format(time.RFC3339)}, 12 * time.Second, true},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		for k, v := range c.header {
+			h.Set(k, v)
+		}
+		got, ok := delayFromHeaders(h, now)
+		if ok != c.ok || got != c.want {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package split
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/config"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/openai"
+	"github.com/diesi/aic/internal/tokens"
+)
+
+// minUnitTokens is the least diff shown per unit when the prompt must be cut.
+const minUnitTokens = 48
+
+// Propose asks the model configured in cfg to group units into commits. Unit
+// contents are filtered and redacted like the regular suggestions prompt and
+// cut to fit the model's context window.
+func Propose(ctx context.Context, cfg commit.Config, apiKey string, units []Unit) (Plan, error) {
+	if len(units) == 0 {
+		return Plan{}, errors.New("no staged changes to split")
+	}
+	if config.Bool(config.EnvAICMock) {
+		return mockPlan(units), nil
+	}
+	p, err := cfg.NewProvider(apiKey)
+	if err != nil {
+		return Plan{}, err
+	}
+	system := planPrompt(cfg)
+	budget := tokens.Budget{Window: commit.ContextWindow(cfg, cfg.Provider, cfg.Model), Output: tokens.PlanOutput(len(units))}
+	perUnit := max((budget.Input()-tokens.Estimate(system))/len(units), minUnitTokens)
+	user := "Change units:\n\n" + renderUnits(cfg, units, perUnit)
+
+	temp := float32(0.2)
+	req := openai.ChatCompletionRequest{
+		Model:       cfg.Model,
+		Messages:    []openai.Message{{Role: "system", Content: system}, {Role: "user", Content: user}},
+		MaxTokens:   budget.Output,
+		N:           1,
+		Temperature: &temp,
+	}
+	if config.Bool(config.EnvAICDebug) {
+		fmt.Fprintf(os.Stderr, "[aic][debug] split tokens: input~%d (%d units), output<=%d, window %d\n", tokens.Estimate(system)+tokens.Estimate(user), len(units), req.MaxTokens, budget.Window)
+	}
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return Plan{}, errors.New("no choices returned")
+	}
+	groups, err := decodePlan(resp.Choices[0])
+	if err != nil {
+		if config.Bool(config.EnvAICDebug) && resp.Raw != "" {
+			err = fmt.Errorf("%w\n\nRaw Response:\n%s", err, resp.Raw)
+		}
+		return Plan{}, err
+	}
+	plan, err := normalize(units, groups)
+	if err != nil {
+		return Plan{}, err
+	}
+	// Like suggestions, plan messages must follow the commit rules: fix what
+	// can be fixed and have the provider rewrite the rest.
+	for i, g := range plan.Groups {
+		if plan.Groups[i].Message, err = commit.SuggestFix(ctx, cfg, apiKey, g.Message); err != nil {
+			return Plan{}, fmt.Errorf("commit %d of the split plan: %w", i+1, err)
+		}
+	}
+	return plan, nil
+}
+
+// planPrompt returns the system prompt asking for a JSON grouping.
+func planPrompt(cfg commit.Config) string {
+	format := `{"commits": [{"message": "...", "units": [1, 2]}]}`
+	message := "a commit subject (" + cfg.HeaderRules() + ")"
+	if cfg.Body {
+		format = `{"commits": [{"message": "...", "body": ["..."], "units": [1, 2]}]}`
+		message += " and 1-3 short body bullets without bullet markers"
+	}
+	prompt := "You split staged Git changes into atomic commits. Each change unit (a hunk or a whole file) has a numeric id. " +
+		"Group the units by logical change (e.g. a refactor, a bug fix, a docs tweak) and write for each group " + message + ". " +
+		"Keep units that depend on each other in the same group, prefer few groups, and order the groups so every commit builds on the previous ones. " +
+		"Assign every unit to exactly one group. Output only JSON in this form, with no code fences or commentary: " + format
+	if cfg.SystemAddition != "" {
+		prompt += " Additional user instructions: " + cfg.SystemAddition
+	}
+	return prompt
+}
+
+// renderUnits lists the units for the prompt, each cut to about limit tokens.
+// Binary files and files excluded by the diff filter show only their stat
+// line; secrets are redacted.
+func renderUnits(cfg commit.Config, units []Unit, limit int) string {
+	filter := cfg.DiffFilter()
+	var b strings.Builder
+	for _, u := range units {
+		fmt.Fprintf(&b, "### Unit %d: %s\n", u.ID, u.Label())
+		var text string
+		if fd := u.fileDiff(); fd.Binary() {
+			text = fd.StatLine()
+			if old, cur, err := git.BlobSizes(u.Header); err == nil {
+				text += fmt.Sprintf(", %d -> %d bytes", old, cur)
+			}
+		} else if filter.Excluded(u.Path) {
+			text = fd.StatLine()
+		} else {
+			text, _ = cfg.Redactor.Diff(u.Header + u.Hunk)
+			// The header repeats the path; keep only the change itself.
+			if !u.Whole {
+				text = strings.TrimPrefix(text, u.Header)
+			}
+		}
+		if r := []rune(text); len(r) > tokens.Chars(limit) {
+			text = string(r[:tokens.Chars(limit)]) + "\n[... unit truncated]"
+		}
+		b.WriteString(strings.TrimRight(text, "\n") + "\n\n")
+	}
+	return b.String()
+}
+
+// planGroup is one commit as returned by the model.
+type planGroup struct {
+	Message string   `json:"message"`
+	Body    []string `json:"body"`
+	Units   []int    `json:"units"`
+}
+
+// decodePlan parses the model's JSON answer, ignoring code fences and chatter
+// around the object.
+func decodePlan(s string) ([]planGroup, error) {
+	start, end := strings.IndexByte(s, '{'), strings.LastIndexByte(s, '}')
+	if start < 0 || end < start {
+		return nil, errors.New("split plan is not JSON")
+	}
+	var plan struct {
+		Commits []planGroup `json:"commits"`
+	}
+	if err := json.Unmarshal([]byte(s[start:end+1]), &plan); err != nil {
+		return nil, fmt.Errorf("cannot parse split plan: %w", err)
+	}
+	return plan.Commits, nil
+}
+
+// normalize turns the model's groups into a valid plan: unknown and repeated
+// unit ids are dropped, units the model left out join a group holding another
+// hunk of their file (or the last group), and empty groups are removed.
+func normalize(units []Unit, groups []planGroup) (Plan, error) {
+	plan := Plan{Units: units}
+	owner := map[int]int{} // unit id -> group index
+	fileGroup := map[int]int{}
+	for _, g := range groups {
+		msg := strings.TrimSpace(g.Message)
+		if msg == "" {
+			continue
+		}
+		if len(g.Body) > 0 {
+			msg = commit.FormatMessage(msg, g.Body)
+		}
+		var ids []int
+		for _, id := range g.Units {
+			if id < 1 || id > len(units) {
+				continue
+			}
+			if _, taken := owner[id]; taken {
+				continue
+			}
+			owner[id] = len(plan.Groups)
+			if _, ok := fileGroup[units[id-1].file]; !ok {
+				fileGroup[units[id-1].file] = len(plan.Groups)
+			}
+			ids = append(ids, id)
+		}
+		if len(ids) > 0 {
+			plan.Groups = append(plan.Groups, Group{Message: msg, Units: ids})
+		}
+	}
+	if len(plan.Groups) == 0 {
+		return Plan{}, errors.New("split plan has no usable commits")
+	}
+	for _, u := range units {
+		if _, ok := owner[u.ID]; ok {
+			continue
+		}
+		g, ok := fileGroup[u.file]
+		if !ok {
+			g = len(plan.Groups) - 1
+		}
+		plan.Groups[g].Units = append(plan.Groups[g].Units, u.ID)
+	}
+	for i := range plan.Groups {
+		sort.Ints(plan.Groups[i].Units)
+	}
+	return plan, nil
+}
+
+// mockPlan (AIC_MOCK=1) commits each file separately.
+func mockPlan(units []Unit) Plan {
+	plan := Plan{Units: units}
+	for _, u := range units {
+		if n := len(plan.Groups); n > 0 && units[plan.Groups[n-1].Units[0]-1].file == u.file {
+			plan.Groups[n-1].Units = append(plan.Groups[n-1].Units, u.ID)
+			continue
+		}
+		plan.Groups = append(plan.Groups, Group{Message: "chore: update " + u.Path, Units: []int{u.ID}})
+	}
+	return plan
+}
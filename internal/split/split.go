@@ -0,0 +1,226 @@
+// Package split plans how to break staged changes into several atomic commits
+// and applies such a plan one commit at a time.
+package split
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/diesi/aic/internal/git"
+)
+
+// Unit is the smallest piece of the staged changes a plan can assign to a
+// commit: one hunk of a modified file, or a whole file when it cannot be
+// split (added, deleted, binary or mode-only changes).
+type Unit struct {
+	ID   int
+	Path string
+	// Header is the file's diff header ("diff --git", "---", "+++", ...).
+	Header string
+	// Hunk is the unit's hunk; for whole-file units it holds all hunks.
+	Hunk string
+	// Index and Count number the hunk within its file (0 and 1 for whole files).
+	Index, Count int
+	// Whole is set for units covering an entire file.
+	Whole bool
+	// file is the unit's position in the diff, for grouping hunks of one file.
+	file int
+}
+
+// Label describes the unit for display, e.g. "main.go (hunk 2/3)".
+func (u Unit) Label() string {
+	if u.Whole || u.Count == 1 {
+		return u.Path
+	}
+	return fmt.Sprintf("%s (hunk %d/%d)", u.Path, u.Index+1, u.Count)
+}
+
+// fileDiff returns the part of the file's diff the unit covers.
+func (u Unit) fileDiff() git.FileDiff {
+	fd := git.FileDiff{Path: u.Path, Header: u.Header}
+	if u.Hunk != "" {
+		fd.Hunks = []string{u.Hunk}
+	}
+	return fd
+}
+
+// Units splits a patch from git.StagedPatch into units, numbered from 1.
+func Units(patch string) []Unit {
+	var units []Unit
+	for fi, f := range git.SplitDiff(patch) {
+		if !splittable(f) {
+			units = append(units, Unit{ID: len(units) + 1, Path: f.Path, Header: f.Header, Hunk: strings.Join(f.Hunks, ""), Count: 1, Whole: true, file: fi})
+			continue
+		}
+		for i, h := range f.Hunks {
+			units = append(units, Unit{ID: len(units) + 1, Path: f.Path, Header: f.Header, Hunk: h, Index: i, Count: len(f.Hunks), file: fi})
+		}
+	}
+	return units
+}
+
+// splittable reports whether the hunks of f can be applied independently:
+// only plain modifications of text files qualify.
+func splittable(f git.FileDiff) bool {
+	if len(f.Hunks) < 2 {
+		return false
+	}
+	for _, marker := range []string{"\nnew file mode", "\ndeleted file mode", "\nold mode", "\nGIT binary patch", "\nBinary files"} {
+		if strings.Contains("\n"+f.Header, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// Group is one planned commit.
+type Group struct {
+	Message string
+	// Units lists the unit ids committed together, in diff order.
+	Units []int
+}
+
+// Plan assigns every unit to exactly one group; groups are committed in order.
+type Plan struct {
+	Units  []Unit
+	Groups []Group
+}
+
+// Unit returns the unit with id.
+func (p Plan) Unit(id int) Unit { return p.Units[id-1] }
+
+// hunkHeader matches "@@ -old[,n] +new[,n] @@ context".
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)`)
+
+// hunkRange is the parsed header of a zero-context hunk.
+type hunkRange struct {
+	oldStart, oldLen, newStart, newLen int
+	rest                               string // text after the closing "@@"
+}
+
+func parseHunk(h string) (hunkRange, string, bool) {
+	header, body, _ := strings.Cut(h, "\n")
+	m := hunkHeader.FindStringSubmatch(header)
+	if m == nil {
+		return hunkRange{}, "", false
+	}
+	num := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return hunkRange{oldStart: num(m[1]), oldLen: num(m[2]), newStart: num(m[3]), newLen: num(m[4]), rest: m[5]}, body, true
+}
+
+// delta is the number of lines the hunk adds to the file.
+func (r hunkRange) delta() int { return r.newLen - r.oldLen }
+
+func (r hunkRange) String() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@%s", r.oldStart, r.oldLen, r.newStart, r.newLen, r.rest)
+}
+
+// Patch returns the patch for group g, to be applied to an index that already
+// holds the units in applied. Hunk line numbers are shifted by the earlier
+// hunks of the same file that were applied before, since the hunks of a
+// zero-context diff are positioned by line number alone.
+func (p Plan) Patch(g int, applied map[int]bool) (string, error) {
+	var b strings.Builder
+	ids := p.Groups[g].Units
+	for i := 0; i < len(ids); {
+		first := p.Unit(ids[i])
+		// Collect this group's units of the same file.
+		j := i
+		for j < len(ids) && p.Unit(ids[j]).file == first.file {
+			j++
+		}
+		b.WriteString(first.Header)
+		if first.Whole {
+			b.WriteString(first.Hunk)
+			i = j
+			continue
+		}
+		own := 0 // lines added by this patch's earlier hunks of the file
+		for _, id := range ids[i:j] {
+			u := p.Unit(id)
+			r, body, ok := parseHunk(u.Hunk)
+			if !ok {
+				return "", fmt.Errorf("cannot parse hunk %d of %s", u.Index+1, u.Path)
+			}
+			shift := 0
+			for _, other := range p.Units {
+				if other.file == u.file && other.Index < u.Index && applied[other.ID] {
+					if or, _, ok := parseHunk(other.Hunk); ok {
+						shift += or.delta()
+					}
+				}
+			}
+			r.oldStart += shift
+			switch {
+			case r.oldLen == 0: // pure addition after line oldStart
+				r.newStart = r.oldStart + own + 1
+			case r.newLen == 0: // pure deletion; new position is the line before
+				r.newStart = r.oldStart + own - 1
+			default:
+				r.newStart = r.oldStart + own
+			}
+			own += r.delta()
+			b.WriteString(r.String() + "\n" + body)
+		}
+		i = j
+	}
+	return b.String(), nil
+}
+
+// Apply commits the groups of p in order: the index is reset to HEAD, then
+// each group's units are staged with git apply --cached and committed with
+// commit. On any failure the original index is restored (commits already
+// made are kept) and the error names the failing group. It returns the
+// number of commits made.
+func Apply(p Plan, commit func(msg string) error) (int, error) {
+	orig, err := git.WriteTree()
+	if err != nil {
+		return 0, fmt.Errorf("cannot snapshot the index (resolve conflicts first): %w", err)
+	}
+	restore := func(err error) error {
+		if rerr := git.ReadTree(orig); rerr != nil {
+			return fmt.Errorf("%w (restoring the index failed too: %v; run git read-tree %s)", err, rerr, orig)
+		}
+		return err
+	}
+	if err := git.ResetIndex(); err != nil {
+		return 0, restore(err)
+	}
+	applied := map[int]bool{}
+	for g, group := range p.Groups {
+		patch, err := p.Patch(g, applied)
+		if err == nil {
+			err = git.ApplyCached(patch)
+		}
+		if err != nil {
+			return g, restore(fmt.Errorf("commit %d of %d (%s): %w", g+1, len(p.Groups), firstLine(group.Message), err))
+		}
+		if err := commit(group.Message); err != nil {
+			return g, restore(fmt.Errorf("commit %d of %d (%s): %w", g+1, len(p.Groups), firstLine(group.Message), err))
+		}
+		for _, id := range group.Units {
+			applied[id] = true
+		}
+	}
+	// Once every unit is committed the index matches the original; if not,
+	// put back whatever was left staged.
+	if tree, err := git.WriteTree(); err != nil || tree != orig {
+		if err := git.ReadTree(orig); err != nil {
+			return len(p.Groups), err
+		}
+	}
+	return len(p.Groups), nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,233 @@
+package split
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diesi/aic/internal/commit"
+	"github.com/diesi/aic/internal/git"
+	"github.com/diesi/aic/internal/gittest"
+	"github.com/diesi/aic/internal/lint"
+)
+
+// tempRepo creates a repo in a temp dir, changes into it and commits files.
+func tempRepo(t *testing.T, files map[string]string) {
+	t.Helper()
+	gittest.Repo(t)
+	gittest.Write(t, files)
+	gittest.Run(t, "add", "-A")
+	gittest.Run(t, "commit", "-q", "-m", "initial")
+}
+
+func lines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+// stageChanges stages a file with three separate hunks (a change, an
+// insertion and a deletion) plus a new file, and returns the staged tree.
+func stageChanges(t *testing.T) (units []Unit, tree string) {
+	t.Helper()
+	tempRepo(t, map[string]string{"a.txt": lines(1, 40)})
+	content := strings.Replace(lines(1, 40), lines(3, 3), "changed three\n", 1)
+	content = strings.Replace(content, lines(20, 20), lines(20, 20)+"inserted one\ninserted two\n", 1)
+	content = strings.Replace(content, lines(35, 36), "", 1)
+	gittest.Write(t, map[string]string{"a.txt": content, "new.txt": "brand new\n"})
+	gittest.Run(t, "add", "-A")
+	patch, err := git.StagedPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err = git.WriteTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Units(patch), tree
+}
+
+func TestUnits(t *testing.T) {
+	units, _ := stageChanges(t)
+	var labels []string
+	for _, u := range units {
+		labels = append(labels, u.Label())
+	}
+	want := []string{"a.txt (hunk 1/3)", "a.txt (hunk 2/3)", "a.txt (hunk 3/3)", "new.txt"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Fatalf("labels = %q, want %q", labels, want)
+	}
+	if !units[3].Whole {
+		t.Fatalf("new file should be a whole-file unit")
+	}
+}
+
+func TestRenderUnitsSummarizesBinaryFiles(t *testing.T) {
+	blob := func(n int) string { return strings.Repeat("\x00\x01\xff", n/3) }
+	tempRepo(t, map[string]string{"logo.bin": blob(300)})
+	gittest.Write(t, map[string]string{"logo.bin": blob(501), "a.txt": "text\n"})
+	gittest.Run(t, "add", "-A")
+	patch, err := git.StagedPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderUnits(commit.Config{}, Units(patch), 1000)
+	if !strings.Contains(got, "logo.bin: binary, 300 -> 501 bytes") || strings.Contains(got, "GIT binary patch") {
+		t.Fatalf("binary unit not summarized:\n%s", got)
+	}
+	if !strings.Contains(got, "+text") {
+		t.Fatalf("text unit lost its diff:\n%s", got)
+	}
+}
+
+func TestApplySplitsHunksOfOneFile(t *testing.T) {
+	units, tree := stageChanges(t)
+	// Commit the hunks out of order: the later insertion goes first, the
+	// deletion and the change before it second.
+	plan := Plan{Units: units, Groups: []Group{
+		{Message: "feat: insert lines", Units: []int{2, 4}},
+		{Message: "fix: change and drop lines\n\n- drop two lines", Units: []int{1, 3}},
+	}}
+	var messages []string
+	n, err := Apply(plan, func(msg string) error {
+		messages = append(messages, msg)
+		return git.Commit(msg)
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if n != 2 || len(messages) != 2 {
+		t.Fatalf("made %d commits (%d messages), want 2", n, len(messages))
+	}
+	if got, _ := git.WriteTree(); got != tree {
+		t.Fatalf("final index %s differs from the staged tree %s", got, tree)
+	}
+	if got := gittest.Run(t, "rev-parse", "HEAD^{tree}"); got != tree {
+		t.Fatalf("HEAD tree = %s, want %s", got, tree)
+	}
+	first := gittest.Run(t, "show", "--format=%s", "HEAD~1")
+	if !strings.Contains(first, "+inserted one") || !strings.Contains(first, "new.txt") || strings.Contains(first, "changed three") {
+		t.Fatalf("first commit has the wrong changes:\n%s", first)
+	}
+	if body := gittest.Run(t, "log", "-1", "--format=%B"); !strings.Contains(body, "- drop two lines") {
+		t.Fatalf("second commit lost its body:\n%s", body)
+	}
+}
+
+func TestApplyRestoresIndexOnFailure(t *testing.T) {
+	units, tree := stageChanges(t)
+	plan := Plan{Units: units, Groups: []Group{
+		{Message: "feat: one", Units: []int{1}},
+		{Message: "feat: rest", Units: []int{2, 3, 4}},
+	}}
+	calls := 0
+	n, err := Apply(plan, func(msg string) error {
+		if calls++; calls == 2 {
+			return errors.New("hook rejected")
+		}
+		return git.Commit(msg)
+	})
+	if err == nil || !strings.Contains(err.Error(), "commit 2 of 2 (feat: rest)") {
+		t.Fatalf("err = %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("made %d commits, want 1", n)
+	}
+	if got, _ := git.WriteTree(); got != tree {
+		t.Fatalf("index %s was not restored to %s", got, tree)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	units := []Unit{
+		{ID: 1, Path: "a.go", file: 0, Index: 0, Count: 2},
+		{ID: 2, Path: "a.go", file: 0, Index: 1, Count: 2},
+		{ID: 3, Path: "b.go", file: 1, Whole: true, Count: 1},
+		{ID: 4, Path: "c.go", file: 2, Whole: true, Count: 1},
+	}
+	plan, err := normalize(units, []planGroup{
+		{Message: "feat: b", Units: []int{3, 1, 9}},
+		{Message: " ", Units: []int{4}},
+		{Message: "fix: c", Body: []string{"why"}, Units: []int{3, 4, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Group{
+		{Message: "feat: b", Units: []int{1, 2, 3}},
+		{Message: "fix: c\n\n- why", Units: []int{4}},
+	}
+	if !reflect.DeepEqual(plan.Groups, want) {
+		t.Fatalf("groups = %+v, want %+v", plan.Groups, want)
+	}
+	if _, err := normalize(units, []planGroup{{Message: "feat: x", Units: []int{7}}}); err == nil {
+		t.Fatalf("expected error for a plan without usable groups")
+	}
+}
+
+func TestDecodePlan(t *testing.T) {
+	groups, err := decodePlan("Here you go:\n```json\n{\"commits\": [{\"message\": \"feat: x\", \"units\": [1, 2]}]}\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Message != "feat: x" || !reflect.DeepEqual(groups[0].Units, []int{1, 2}) {
+		t.Fatalf("groups = %+v", groups)
+	}
+	if _, err := decodePlan("no json here"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestMockPlan(t *testing.T) {
+	t.Setenv("AIC_MOCK", "1")
+	units, _ := stageChanges(t)
+	plan, err := Propose(context.Background(), commit.Config{}, "", units)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Groups) != 2 || plan.Groups[0].Message != "chore: update a.txt" || !reflect.DeepEqual(plan.Groups[0].Units, []int{1, 2, 3}) {
+		t.Fatalf("plan = %+v", plan.Groups)
+	}
+}
+
+func TestProposeFollowsCommitRules(t *testing.T) {
+	units, _ := stageChanges(t)
+	var system string
+	var chats int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		chats++
+		content := "ops: add new file"
+		if chats == 1 {
+			system = body.Messages[0]["content"]
+			content = `{"commits": [{"message": "Fix: Change lines.", "units": [1, 2, 3]}, {"message": "feature: add new file", "units": [4]}]}`
+		}
+		reply, _ := json.Marshal(map[string]any{"message": map[string]string{"role": "assistant", "content": content}, "done": true})
+		w.Write(reply)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	cfg := commit.Config{Provider: "ollama", Model: "llama3", Lint: lint.Rules{Types: []string{"fix", "ops"}, MaxLength: 50}}
+	plan, err := Propose(context.Background(), cfg, "", units)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(system, "(fix|ops)") || !strings.Contains(system, "<=50 chars") {
+		t.Fatalf("plan prompt ignores the configured rules:\n%s", system)
+	}
+	if len(plan.Groups) != 2 || plan.Groups[0].Message != "fix: change lines" || plan.Groups[1].Message != "ops: add new file" {
+		t.Fatalf("plan messages should be fixed to the rules: %+v", plan.Groups)
+	}
+}
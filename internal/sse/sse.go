@@ -0,0 +1,70 @@
+// Package sse reads text/event-stream response bodies as used by the
+// providers' streaming chat APIs.
+package sse
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Event is a single server-sent event.
+type Event struct {
+	Name string // value of the "event:" field (empty if not set)
+	Data string // data lines joined with "\n"
+}
+
+// Done may be returned by the Read callback to stop reading without error
+// (e.g., on OpenAI's "data: [DONE]" terminator).
+var Done = errors.New("sse: done")
+
+// Read parses events from r and calls fn for each one that carries data.
+// It returns nil at EOF or when fn returns Done; any other error from fn or
+// from the underlying reader is returned as-is.
+func Read(r io.Reader, fn func(Event) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var name string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			name = ""
+			return nil
+		}
+		ev := Event{Name: name, Data: strings.Join(data, "\n")}
+		name, data = "", nil
+		return fn(ev)
+	}
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			if err := dispatch(); err != nil {
+				if errors.Is(err, Done) {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment / keep-alive
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	// Flush a trailing event that wasn't followed by a blank line.
+	if err := dispatch(); err != nil && !errors.Is(err, Done) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package sse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	in := ": keep-alive\n\nevent: delta\ndata: {\"a\":1}\n\ndata: line1\ndata: line2\n\ndata: [DONE]\n\ndata: ignored\n\n"
+	var got []Event
+	err := Read(strings.NewReader(in), func(ev Event) error {
+		if ev.Data == "[DONE]" {
+			return Done
+		}
+		got = append(got, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d: %#v", len(got), got)
+	}
+	if got[0].Name != "delta" || got[0].Data != `{"a":1}` {
+		t.Fatalf("unexpected first event: %#v", got[0])
+	}
+	if got[1].Name != "" || got[1].Data != "line1\nline2" {
+		t.Fatalf("unexpected second event: %#v", got[1])
+	}
+}
+
+func TestReadTrailingEventWithoutBlankLine(t *testing.T) {
+	var got []string
+	err := Read(strings.NewReader("data: last"), func(ev Event) error {
+		got = append(got, ev.Data)
+		return nil
+	})
+	if err != nil || len(got) != 1 || got[0] != "last" {
+		t.Fatalf("unexpected result: %v %#v", err, got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package tokens provides rough token estimates and model context windows
+// for sizing prompts without a tokenizer.
+package tokens
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken approximates tokenizer output for code and English text.
+const charsPerToken = 4
+
+// defaultContextWindow is assumed for models missing from the table.
+const defaultContextWindow = 8192
+
+// contextWindows maps model name prefixes to their context window in tokens.
+// The longest matching prefix wins.
+var contextWindows = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"gpt-5":         400000,
+	"o1":            200000,
+	"o3":            200000,
+	"o4-mini":       200000,
+	"claude-2":      100000,
+	"claude-3":      200000,
+	"claude-sonnet": 200000,
+	"claude-opus":   200000,
+	"claude-haiku":  200000,
+	"gemini-1.0":    32768,
+	"gemini-1.5":    1048576,
+	"gemini-2":      1048576,
+}
+
+// Estimate returns the approximate number of tokens in s.
+func Estimate(s string) int {
+	n := utf8.RuneCountInString(s)
+	return (n + charsPerToken - 1) / charsPerToken
+}
+
+// Chars converts a token count into the approximate number of characters.
+func Chars(tokens int) int { return tokens * charsPerToken }
+
+// ContextWindow returns the context window of model in tokens, or a
+// conservative default for unknown models.
+func ContextWindow(model string) int { return Overrides(nil).ContextWindow(model) }
+
+// Overrides maps model names or prefixes to context windows in tokens (e.g.
+// "context_windows" in .aic.json). They take precedence over the built-in
+// table; the key "*" replaces the default used for unknown models.
+type Overrides map[string]int
+
+// ContextWindow returns the context window of model, consulting o before the
+// built-in table. The longest matching prefix wins within each.
+func (o Overrides) ContextWindow(model string) int {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if w, ok := lookup(o, model); ok {
+		return w
+	}
+	if w, ok := lookup(contextWindows, model); ok {
+		return w
+	}
+	if w := o["*"]; w > 0 {
+		return w
+	}
+	return defaultContextWindow
+}
+
+func lookup(table map[string]int, model string) (int, bool) {
+	best, window := -1, 0
+	for prefix, w := range table {
+		p := strings.ToLower(prefix)
+		if w > 0 && p != "*" && strings.HasPrefix(model, p) && len(p) > best {
+			best, window = len(p), w
+		}
+	}
+	return window, best >= 0
+}
+
+// Output token budgets for the requests aic makes.
+const (
+	// SummaryOutput bounds each per-chunk diff summary.
+	SummaryOutput = 384
+	// ReduceOutput bounds the merged summary of several chunks.
+	ReduceOutput = 768
+	// InstructionsOutput bounds the style instructions written by analyze.
+	InstructionsOutput = 280
+
+	minSuggestionOutput  = 256
+	perSuggestionOutput  = 40
+	baseSuggestionOutput = 64
+
+	// Body-mode suggestions carry a subject plus a few bullets as JSON.
+	minBodySuggestionOutput = 512
+	perBodySuggestionOutput = 200
+
+	// Split plans list one message per group plus every unit id.
+	minPlanOutput  = 512
+	perPlanUnit    = 24
+	basePlanOutput = 256
+)
+
+// SuggestionOutput returns the output budget for a request asking for n
+// suggestions. Providers that answer with one message listing every
+// suggestion need room for all of them, so the budget grows with n.
+func SuggestionOutput(n int) int {
+	out := baseSuggestionOutput + perSuggestionOutput*n
+	if out < minSuggestionOutput {
+		out = minSuggestionOutput
+	}
+	return out
+}
+
+// BodySuggestionOutput is SuggestionOutput for suggestions with a body.
+func BodySuggestionOutput(n int) int {
+	out := baseSuggestionOutput + perBodySuggestionOutput*n
+	if out < minBodySuggestionOutput {
+		out = minBodySuggestionOutput
+	}
+	return out
+}
+
+// PlanOutput returns the output budget for a split plan over n change
+// units: a few groups with a message each plus every unit id.
+func PlanOutput(n int) int {
+	out := basePlanOutput + perPlanUnit*n
+	if out < minPlanOutput {
+		out = minPlanOutput
+	}
+	return out
+}
+
+// Budget splits a model's context window between the prompt and the output.
+type Budget struct {
+	Window int
+	Output int
+}
+
+// Input returns the tokens left for the prompt after reserving the output and
+// a safety margin for estimation error and message framing.
+func (b Budget) Input() int {
+	n := b.Window - b.Output
+	n -= n / 10
+	if n < 0 {
+		return 0
+	}
+	return n
+}
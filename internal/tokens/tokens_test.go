@@ -0,0 +1,64 @@
+package tokens
+
+import "testing"
+
+func TestEstimate(t *testing.T) {
+	cases := map[string]int{"": 0, "abc": 1, "abcd": 1, "abcde": 2, "汉字汉字汉": 2}
+	for in, want := range cases {
+		if got := Estimate(in); got != want {
+			t.Errorf("Estimate(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestContextWindowLongestPrefix(t *testing.T) {
+	cases := map[string]int{
+		"gpt-4":                    8192,
+		"gpt-4-0613":               8192,
+		"gpt-4o-mini":              128000,
+		"gpt-4-turbo-preview":      128000,
+		"GPT-4.1-mini":             1047576,
+		"claude-sonnet-4-20250514": 200000,
+		"some-local-model":         defaultContextWindow,
+		"":                         defaultContextWindow,
+	}
+	for model, want := range cases {
+		if got := ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestOverridesContextWindow(t *testing.T) {
+	o := Overrides{"gpt-4o": 32000, "llama3": 8192, "*": 4096}
+	cases := map[string]int{
+		"gpt-4o-mini":      32000,
+		"gpt-4.1":          1047576, // built-in table still applies
+		"llama3.1:8b":      8192,
+		"some-local-model": 4096,
+	}
+	for model, want := range cases {
+		if got := o.ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestSuggestionOutputGrowsWithCount(t *testing.T) {
+	if got := SuggestionOutput(1); got != minSuggestionOutput {
+		t.Fatalf("SuggestionOutput(1) = %d, want the %d floor", got, minSuggestionOutput)
+	}
+	if SuggestionOutput(10) <= SuggestionOutput(5) {
+		t.Fatalf("expected more output tokens for more suggestions")
+	}
+}
+
+func TestBudgetInput(t *testing.T) {
+	b := Budget{Window: 8192, Output: 256}
+	if in := b.Input(); in <= 0 || in >= 8192-256 {
+		t.Fatalf("Input() = %d, want a margin below %d", in, 8192-256)
+	}
+	if in := (Budget{Window: 100, Output: 200}).Input(); in != 0 {
+		t.Fatalf("Input() = %d for an overfull budget, want 0", in)
+	}
+}